@@ -0,0 +1,98 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the decoded sample cache, which saves going back to
+// the disk (and the decoder) for every bark.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps decoded samples in memory, throwing out the least recently
+// played ones once it's over its limit.
+type Cache struct {
+	// Limit is the most memory (in bytes) the samples may take up.
+	Limit int64
+	// lru has the most recently used entry at the front.
+	lru *list.List
+	entries map[*Sound]*list.Element
+	size int64
+	hits, misses, evictions int64
+	sync.Mutex
+}
+
+// cacheEntry is one decoded sample.
+type cacheEntry struct {
+	sound *Sound
+	pcm []int32
+}
+
+// CacheStats is a snapshot of how the cache is doing.
+type CacheStats struct {
+	// Entries is the number of samples in the cache.
+	Entries int
+	// Bytes is the memory they take up, out of Limit.
+	Bytes, Limit int64
+	// Hits and Misses count lookups (plays and preloads) that did and
+	// didn't find their sample.
+	Hits, Misses int64
+	// Evictions counts samples thrown out to make room.
+	Evictions int64
+}
+
+// NewCache makes an empty cache holding up to limit bytes of samples.
+func NewCache(limit int64) *Cache {
+	return &Cache{ Limit: limit, lru: list.New(),
+		entries: make(map[*Sound]*list.Element) }
+}
+
+// WithCache keeps decoded samples in the given cache.
+func WithCache(c *Cache) Option {
+	return func(o *options) { o.cache = c }
+}
+
+// get looks up a sample's PCM, returning nil if it isn't cached.
+func (c *Cache) get(s *Sound) []int32 {
+	c.Lock()
+	defer c.Unlock()
+	elem, ok := c.entries[s]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).pcm
+}
+
+// put adds a sample's PCM, evicting older ones until it fits.  Samples
+// bigger than the whole cache aren't kept.
+func (c *Cache) put(s *Sound, pcm []int32) {
+	c.Lock()
+	defer c.Unlock()
+	size := int64(len(pcm))*4
+	if _, ok := c.entries[s]; ok || size > c.Limit { return }
+	for c.size+size > c.Limit {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.sound)
+		c.size -= int64(len(entry.pcm))*4
+		c.evictions++
+	}
+	c.entries[s] = c.lru.PushFront(&cacheEntry{ s, pcm })
+	c.size += size
+}
+
+// Stats reports how the cache is doing.
+func (c *Cache) Stats() CacheStats {
+	c.Lock()
+	defer c.Unlock()
+	return CacheStats{ len(c.entries), c.size, c.Limit, c.hits, c.misses,
+		c.evictions }
+}
@@ -0,0 +1,34 @@
+// Test routines for the decoded sample cache.
+
+package woofie
+
+import (
+	"testing"
+)
+
+// TestCacheEviction checks that the least recently played sample goes
+// first and that the stats add up.
+func TestCacheEviction(t *testing.T) {
+	cache := NewCache(100)
+	a, b, c := &Sound{}, &Sound{}, &Sound{}
+	cache.put(a, make([]int32, 10))
+	cache.put(b, make([]int32, 10))
+	if cache.get(a) == nil { t.Error("Expected a to be cached") }
+
+	// 40 bytes more only fits if b (the least recently used) goes.
+	cache.put(c, make([]int32, 10))
+	if cache.get(b) != nil { t.Error("Expected b to be evicted") }
+	if cache.get(a) == nil || cache.get(c) == nil {
+		t.Error("Expected a and c to be cached")
+	}
+
+	// Too big to ever fit.
+	cache.put(b, make([]int32, 26))
+	if cache.get(b) != nil { t.Error("Expected b not to be cached") }
+
+	stats := cache.Stats()
+	expected := CacheStats{ 2, 80, 100, 3, 2, 1 }
+	if stats != expected {
+		t.Errorf("Expected stats %v, got %v", expected, stats)
+	}
+}
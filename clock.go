@@ -0,0 +1,203 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the clock and randomness the Woofer and Sounds run
+// on, so tests and simulations can swap in their own.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits.  The real one is the wall clock.
+type Clock interface {
+	// Now is the current time.
+	Now() time.Time
+	// Sleep waits for d to pass.
+	Sleep(d time.Duration)
+	// After sends the time on the channel once d has passed.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f in its own goroutine once d has passed, unless
+	// stop is called first.  stop reports whether it was in time.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// ManualClock only moves when told to, which lets tests and simulations run
+// hours of barking in no time.
+type ManualClock struct {
+	now time.Time
+	waiters []manualWaiter
+	sync.Mutex
+}
+
+// manualWaiter is somebody sleeping on a ManualClock, or a function waiting
+// to be called.
+type manualWaiter struct {
+	when time.Time
+	ch chan time.Time
+	f func()
+}
+
+// NewManualClock creates a clock stopped at start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{ now: start }
+}
+
+// Now implements Clock.
+func (c *ManualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+// After implements Clock.  The channel fires when Advance or Set moves the
+// clock past now+d.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters,
+			manualWaiter{ c.now.Add(d), ch, nil })
+	}
+	return ch
+}
+
+// AfterFunc implements Clock.  f gets called when Advance or Set moves the
+// clock past now+d.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	if d <= 0 {
+		go f()
+		return func() bool { return false }
+	}
+	c.Lock()
+	defer c.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, manualWaiter{ c.now.Add(d), ch, f })
+	return func() bool {
+		c.Lock()
+		defer c.Unlock()
+		for i, w := range c.waiters {
+			if w.ch == ch {
+				c.waiters = append(c.waiters[:i],
+					c.waiters[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Sleep implements Clock by blocking until somebody advances the clock.
+func (c *ManualClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t (which should not be in its past) and wakes up
+// any sleepers whose time has come.
+func (c *ManualClock) Set(t time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = t
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.when.After(t) {
+			waiting = append(waiting, w)
+		} else if w.f != nil {
+			go w.f()
+		} else {
+			w.ch <- t
+		}
+	}
+	c.waiters = waiting
+}
+
+// Rand is the randomness the virtual dog uses to make up its mind.
+type Rand interface {
+	// Float32 returns a number in [0.0, 1.0).
+	Float32() float32
+	// Intn returns a number in [0, n).
+	Intn(n int) int
+}
+
+// lockedRand makes a math/rand source safe to share between goroutines.
+type lockedRand struct {
+	r *rand.Rand
+	sync.Mutex
+}
+
+// NewRand creates a goroutine-safe Rand from a seed.  The same seed always
+// gives the same sequence.
+func NewRand(seed int64) Rand {
+	return &lockedRand{ r: rand.New(rand.NewSource(seed)) }
+}
+
+func (l *lockedRand) Float32() float32 {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Float32()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Intn(n)
+}
+
+// Option customizes a Woofer or Sounds as it's built.
+type Option func(*options)
+
+// options is what the Options fill in.
+type options struct {
+	clock Clock
+	rand Rand
+	sink Sink
+	cache *Cache
+	normalizer *Normalizer
+	trim *float64
+	flat bool
+}
+
+// WithClock runs on the given clock instead of the wall clock.
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// WithRand uses the given randomness instead of a time-seeded one.
+func WithRand(r Rand) Option {
+	return func(o *options) { o.rand = r }
+}
+
+// buildOptions applies opts over the defaults.
+func buildOptions(opts []Option) options {
+	ret := options{}
+	for _, opt := range opts {
+		opt(&ret)
+	}
+	if ret.clock == nil { ret.clock = realClock{} }
+	if ret.rand == nil { ret.rand = NewRand(time.Now().UnixNano()) }
+	if ret.sink == nil { ret.sink = DefaultSink }
+	return ret
+}
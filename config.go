@@ -0,0 +1,81 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the config file loader.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Config is the contents of a config file.  The file is a JSON object whose
+// keys are command-line option names without the dashes, e.g.
+//    { "woofdir": "/srv/woofs", "scoring": "window:window=30,max=5" }
+// Options from the file act as defaults; the command line still wins.
+//
+// A few keys hold more than an option's worth of setup instead:
+//    "profiles": custom personality profiles (see Profiles.Load)
+//    "zones": per-zone sensitivity and scoring (see Zones.Load)
+type Config struct {
+	// Options holds the raw JSON value of each option in the file.
+	Options map[string]json.RawMessage
+	// Profiles holds the raw custom profiles by name.
+	Profiles map[string]json.RawMessage
+	// Zones holds the raw zone setups by name.
+	Zones map[string]json.RawMessage
+}
+
+// LoadConfig reads and parses a config file.
+func LoadConfig(path string) (*Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil { return nil, err }
+	ret := Config{}
+	err = json.Unmarshal(buf, &ret.Options)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Bad config file %s: %s",
+			path, err.Error()))
+	}
+	err = ret.section("profiles", &ret.Profiles)
+	if err != nil { return nil, err }
+	err = ret.section("zones", &ret.Zones)
+	if err != nil { return nil, err }
+	return &ret, nil
+}
+
+// ApplyOptions stores each option from the file through the matching
+// pointer in opts (e.g. "horizon" -> *int).  Options the program doesn't
+// know about are an error, so typos don't go unnoticed.
+func (c *Config) ApplyOptions(opts map[string]interface{}) error {
+	for name, raw := range c.Options {
+		ptr, ok := opts[name]
+		if !ok {
+			return errors.New(fmt.Sprintf("Unknown option in " +
+				"config: %s", name))
+		}
+		err := json.Unmarshal(raw, ptr)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad value for %s: %s",
+				name, err.Error()))
+		}
+	}
+	return nil
+}
+
+// section pulls a setup section out of the options and parses it into ptr.
+func (c *Config) section(name string, ptr interface{}) error {
+	raw, ok := c.Options[name]
+	if !ok { return nil }
+	delete(c.Options, name)
+	err := json.Unmarshal(raw, ptr)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Bad %s in config: %s", name,
+			err.Error()))
+	}
+	return nil
+}
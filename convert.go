@@ -0,0 +1,281 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the sample rate and channel conversion that turns
+// every sample into the one format the output wants.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// The resampling qualities, from cheapest to best.
+const (
+	QualityNearest = "nearest"
+	QualityLinear = "linear"
+	QualityCubic = "cubic"
+	QualitySinc = "sinc"
+)
+
+// sincWidth is how many input frames either side the sinc resampler looks
+// at (when not downsampling).
+const sincWidth = 16
+
+// kernel is a resampling filter: the weight of an input frame d frames away
+// from where we're sampling, and how far either side it goes.
+type kernel struct {
+	width int
+	weight func(d float64) float64
+}
+
+// newKernel picks the filter for a quality.  cutoff is the fraction of the
+// input's bandwidth that survives (below 1 when downsampling).
+func newKernel(quality string, cutoff float64) (kernel, error) {
+	switch quality {
+		case QualityNearest:
+			return kernel{ 1, func(d float64) float64 {
+				if d > -0.5 && d <= 0.5 { return 1 }
+				return 0
+			} }, nil
+		case QualityLinear:
+			return kernel{ 1, func(d float64) float64 {
+				return math.Max(0, 1 - math.Abs(d))
+			} }, nil
+		case QualityCubic, "":
+			// Catmull-Rom.
+			return kernel{ 2, func(d float64) float64 {
+				d = math.Abs(d)
+				if d < 1 { return 1.5*d*d*d - 2.5*d*d + 1 }
+				if d < 2 { return -0.5*d*d*d + 2.5*d*d - 4*d + 2 }
+				return 0
+			} }, nil
+		case QualitySinc:
+			// Hann-windowed sinc, stretched to filter out what won't
+			// fit below the new rate.
+			width := int(math.Ceil(sincWidth / cutoff))
+			return kernel{ width, func(d float64) float64 {
+				if math.Abs(d) >= float64(width) { return 0 }
+				window := 0.5 + 0.5*math.Cos(math.Pi*d/float64(width))
+				x := math.Pi * d * cutoff
+				if x == 0 { return cutoff * window }
+				return cutoff * math.Sin(x) / x * window
+			} }, nil
+	}
+	return kernel{}, errors.New(fmt.Sprintf("Bad resampling quality: %s",
+		quality))
+}
+
+// mixChannels converts interleaved frames from one channel count to another.
+// Mono goes to every output channel and anything goes to mono as the
+// average; otherwise the channels map across in order, extra inputs folding
+// onto the outputs and extra outputs staying silent.
+func mixChannels(in []float64, from, to int) []float64 {
+	if from == to { return in }
+	frames := len(in)/from
+	out := make([]float64, frames*to)
+	for i := 0; i < frames; i++ {
+		src := in[i*from:(i+1)*from]
+		dst := out[i*to:(i+1)*to]
+		switch {
+			case from == 1:
+				for ch := range dst { dst[ch] = src[0] }
+			case to == 1:
+				for _, v := range src { dst[0] += v }
+				dst[0] /= float64(from)
+			default:
+				counts := make([]int, to)
+				for ch, v := range src {
+					dst[ch%to] += v
+					counts[ch%to]++
+				}
+				for ch := range dst {
+					if counts[ch] > 1 { dst[ch] /= float64(counts[ch]) }
+				}
+		}
+	}
+	return out
+}
+
+// resampler converts a stream of interleaved frames from one rate to
+// another, carrying what it needs from one chunk to the next.
+type resampler struct {
+	from, to, channels int
+	kernel kernel
+	// in is the input frames still needed, starting kernel.width frames
+	// of silence before the first real one.
+	in []float64
+	// pos is where the next output frame comes from, in frames into in.
+	pos float64
+	// inFrames and outFrames count the real frames in and out, so flush
+	// knows how many outputs are still owed.
+	inFrames, outFrames int64
+}
+
+// newResampler makes a resampler between two rates.
+func newResampler(from, to, channels int, quality string) (*resampler,
+		error) {
+	k, err := newKernel(quality, math.Min(1, float64(to)/float64(from)))
+	if err != nil { return nil, err }
+	r := resampler{ from: from, to: to, channels: channels, kernel: k }
+	r.in = make([]float64, k.width*channels)
+	r.pos = float64(k.width)
+	return &r, nil
+}
+
+// write adds input frames and returns the output frames they make.
+func (r *resampler) write(buf []float64) []float64 {
+	r.in = append(r.in, buf...)
+	r.inFrames += int64(len(buf)/r.channels)
+	return r.run(-1)
+}
+
+// flush pads the input with silence to get out the last of the output.
+func (r *resampler) flush() []float64 {
+	owed := r.inFrames*int64(r.to)/int64(r.from) - r.outFrames
+	r.in = append(r.in, make([]float64, (r.kernel.width+1)*r.channels)...)
+	return r.run(owed)
+}
+
+// run makes as many output frames as the input allows, up to max (if it
+// isn't negative), and drops the input frames nobody needs any more.
+func (r *resampler) run(max int64) []float64 {
+	step := float64(r.from) / float64(r.to)
+	frames := len(r.in)/r.channels
+	width := r.kernel.width
+	out := make([]float64, 0)
+	for n := int64(0); max < 0 || n < max; n++ {
+		base := int(math.Floor(r.pos))
+		if base + width >= frames { break }
+		for ch := 0; ch < r.channels; ch++ {
+			v := 0.0
+			for i := base-width+1; i <= base+width; i++ {
+				v += r.in[i*r.channels+ch] * r.kernel.weight(r.pos -
+					float64(i))
+			}
+			out = append(out, v)
+		}
+		r.pos += step
+		r.outFrames++
+	}
+	drop := int(math.Floor(r.pos)) - width + 1
+	if drop > frames { drop = frames }
+	if drop > 0 {
+		r.in = append([]float64{}, r.in[drop*r.channels:]...)
+		r.pos -= float64(drop)
+	}
+	return out
+}
+
+// ConvertSink converts everything written to it to one rate and channel
+// count before passing it on, so the output never has to change format.
+type ConvertSink struct {
+	// Out is where the converted audio goes.
+	Out Sink
+	// Rate and Channels are the output format; 0 leaves that side of it
+	// as it comes.
+	Rate, Channels int
+	// Quality is the resampling quality (one of the Quality* constants).
+	Quality string
+	// rate and channels are the format coming in.
+	rate, channels int
+	resampler *resampler
+	sync.Mutex
+}
+
+// NewConvertSink wraps out in a converter to the given format.
+func NewConvertSink(out Sink, rate, channels int,
+		quality string) (*ConvertSink, error) {
+	_, err := newKernel(quality, 1)
+	if err != nil { return nil, err }
+	if rate < 0 || channels < 0 {
+		return nil, errors.New(fmt.Sprintf("Bad output format: " +
+			"%d-channel %dHz", channels, rate))
+	}
+	return &ConvertSink{ Out: out, Rate: rate, Channels: channels,
+		Quality: quality }, nil
+}
+
+// output is the format the output gets for a given input.
+func (c *ConvertSink) output(rate, channels int) (int, int) {
+	if c.Rate != 0 { rate = c.Rate }
+	if c.Channels != 0 { channels = c.Channels }
+	return rate, channels
+}
+
+// Write implements Sink.
+func (c *ConvertSink) Write(rate, channels int, buf []int32) error {
+	c.Lock()
+	defer c.Unlock()
+	if rate != c.rate || channels != c.channels {
+		// A new format: finish off the old one first.
+		err := c.drain()
+		if err != nil { return err }
+		c.rate, c.channels = rate, channels
+		outRate, outChannels := c.output(rate, channels)
+		if outRate != rate {
+			c.resampler, err = newResampler(rate, outRate,
+				outChannels, c.Quality)
+			if err != nil { return err }
+		}
+	}
+	outRate, outChannels := c.output(rate, channels)
+	if c.resampler == nil && outChannels == channels {
+		return c.Out.Write(rate, channels, buf)
+	}
+	in := make([]float64, len(buf))
+	for i, v := range buf {
+		in[i] = float64(v)
+	}
+	out := mixChannels(in, channels, outChannels)
+	if c.resampler != nil { out = c.resampler.write(out) }
+	if len(out) == 0 { return nil }
+	return c.Out.Write(outRate, outChannels, toPCM(out))
+}
+
+// drain writes out whatever the resampler is holding on to and forgets the
+// input format.  The caller must hold the lock.
+func (c *ConvertSink) drain() error {
+	r := c.resampler
+	c.resampler, c.rate, c.channels = nil, 0, 0
+	if r == nil { return nil }
+	out := r.flush()
+	if len(out) == 0 { return nil }
+	return c.Out.Write(r.to, r.channels, toPCM(out))
+}
+
+// Flush implements Sink.  Each sample is resampled on its own, so the end of
+// one doesn't smear into the start of the next.
+func (c *ConvertSink) Flush() error {
+	c.Lock()
+	err := c.drain()
+	c.Unlock()
+	if err != nil { return err }
+	return c.Out.Flush()
+}
+
+// Close implements Sink.
+func (c *ConvertSink) Close() error {
+	c.Lock()
+	err := c.drain()
+	c.Unlock()
+	closeErr := c.Out.Close()
+	if err != nil { return err }
+	return closeErr
+}
+
+// toPCM rounds samples back to 32 bits, clipping at the limits.
+func toPCM(in []float64) []int32 {
+	ret := make([]int32, len(in))
+	for i, v := range in {
+		v = math.Max(math.Min(math.Round(v), math.MaxInt32),
+			math.MinInt32)
+		ret[i] = int32(v)
+	}
+	return ret
+}
@@ -0,0 +1,107 @@
+// Test routines for the sample rate and channel conversion.
+
+package woofie
+
+import (
+	"math"
+	"testing"
+)
+
+// recordSink keeps everything written to it.
+type recordSink struct {
+	rate, channels int
+	pcm []int32
+	flushes int
+}
+
+func (r *recordSink) Write(rate, channels int, buf []int32) error {
+	r.rate, r.channels = rate, channels
+	r.pcm = append(r.pcm, buf...)
+	return nil
+}
+
+func (r *recordSink) Flush() error { r.flushes++; return nil }
+func (r *recordSink) Close() error { return nil }
+
+// TestMixChannels checks up- and down-mixing.
+func TestMixChannels(t *testing.T) {
+	tests := []struct {
+		from, to int
+		in, expected []float64
+	}{
+		{ 1, 2, []float64{ 1, 2 }, []float64{ 1, 1, 2, 2 } },
+		{ 2, 1, []float64{ 1, 3, 2, 4 }, []float64{ 2, 3 } },
+		{ 2, 4, []float64{ 1, 2 }, []float64{ 1, 2, 0, 0 } },
+		{ 6, 2, []float64{ 1, 2, 3, 4, 5, 6 }, []float64{ 3, 4 } },
+	}
+	for _, test := range tests {
+		out := mixChannels(test.in, test.from, test.to)
+		if len(out) != len(test.expected) {
+			t.Fatalf("%d->%d: expected %v, got %v", test.from, test.to,
+				test.expected, out)
+		}
+		for i := range out {
+			if out[i] != test.expected[i] {
+				t.Errorf("%d->%d: expected %v, got %v", test.from,
+					test.to, test.expected, out)
+				break
+			}
+		}
+	}
+}
+
+// TestConvertSink resamples a sine wave in uneven chunks at each quality
+// and checks it comes out the right length and (away from the ends) still
+// the same wave.
+func TestConvertSink(t *testing.T) {
+	pcm := make([]int32, 2205)
+	for i := range pcm {
+		pcm[i] = int32(math.Sin(2*math.Pi*float64(i)*100/22050) *
+			(1 << 30))
+	}
+	for _, quality := range []string{ QualityNearest, QualityLinear,
+			QualityCubic, QualitySinc } {
+		out := recordSink{}
+		sink, err := NewConvertSink(&out, 48000, 2, quality)
+		if err != nil { t.Fatal(err) }
+		for i := 0; i < len(pcm); i += 1000 {
+			end := i + 1000
+			if end > len(pcm) { end = len(pcm) }
+			err = sink.Write(22050, 1, pcm[i:end])
+			if err != nil { t.Fatal(err) }
+		}
+		err = sink.Flush()
+		if err != nil { t.Fatal(err) }
+		if out.rate != 48000 || out.channels != 2 || out.flushes != 1 {
+			t.Fatalf("%s: got %d-channel %dHz, %d flushes", quality,
+				out.channels, out.rate, out.flushes)
+		}
+		if len(out.pcm) != 4800*2 {
+			t.Fatal(quality, ": expected 9600 samples, got ",
+				len(out.pcm))
+		}
+		for i := 100; i < 4700; i++ {
+			expected := math.Sin(2*math.Pi*float64(i)*100/48000) *
+				(1 << 30)
+			if out.pcm[i*2] != out.pcm[i*2+1] ||
+					math.Abs(float64(out.pcm[i*2]) - expected) >
+					(1 << 25) {
+				t.Fatalf("%s: frame %d: expected %.0f, got %d/%d",
+					quality, i, expected, out.pcm[i*2],
+					out.pcm[i*2+1])
+			}
+		}
+	}
+
+	// The output's format is left alone.
+	out := recordSink{}
+	sink, err := NewConvertSink(&out, 22050, 0, QualityCubic)
+	if err != nil { t.Fatal(err) }
+	err = sink.Write(22050, 1, pcm)
+	if err != nil { t.Fatal(err) }
+	if out.rate != 22050 || out.channels != 1 || len(out.pcm) != len(pcm) {
+		t.Error("Expected the sample to pass straight through")
+	}
+	_, err = NewConvertSink(&out, 48000, 2, "bogus")
+	if err == nil { t.Error("Expected a bad quality to fail") }
+}
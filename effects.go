@@ -0,0 +1,249 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the effect chain (filters, EQ and reverb) that makes
+// close-miked recordings sound like a dog somewhere in particular.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The EQ bands: shelves below bassFreq and above trebleFreq, and a peak at
+// midFreq.
+const (
+	bassFreq = 200.0
+	midFreq = 1000.0
+	trebleFreq = 4000.0
+)
+
+// Effects is the chain applied to a bark: high-pass, low-pass, EQ and then
+// reverb.  Zero settings are left out.
+type Effects struct {
+	// Preset is the preset it started from.
+	Preset string
+	// HighPass and LowPass are the filter cutoffs in Hz.
+	HighPass, LowPass float64
+	// Bass, Mid and Treble are the EQ gains in dB.
+	Bass, Mid, Treble float64
+	// Reverb is how much of the output is reverb, from 0 to 1.
+	Reverb float64
+	// Room is how long the reverb takes to die away (by 60dB), in secs.
+	Room float64
+}
+
+// EffectPresets are the places a dog can be.
+var EffectPresets = map[string]Effects{
+	"none": { Preset: "none" },
+	// Muffled through a door, with a bit of hallway.
+	"behind-door": { Preset: "behind-door", LowPass: 1000, Bass: 4,
+		Treble: -8, Reverb: 0.2, Room: 0.4 },
+	// Boomy and echoey.
+	"garage": { Preset: "garage", HighPass: 60, Mid: 2, Reverb: 0.4,
+		Room: 1.5 },
+	// Out in the open, a little thin and with hardly any echo.
+	"open-yard": { Preset: "open-yard", HighPass: 120, Treble: -2,
+		Reverb: 0.08, Room: 0.2 },
+}
+
+// effectsre splits an effects spec into preset and settings.
+var effectsre = regexp.MustCompile("^\\s*([a-z-]*)\\s*(?::(.*))?$")
+
+// NewEffects builds an effect chain from a spec of the form
+// preset:key=val,key=val (e.g. "behind-door:lowpass=800" or just "garage").
+// The settings are highpass, lowpass, bass, mid, treble, reverb and room.
+// An empty spec (or "none") gives nil, for no effects.
+func NewEffects(spec string) (*Effects, error) {
+	matches := effectsre.FindStringSubmatch(spec)
+	if matches == nil {
+		return nil, errors.New(fmt.Sprintf("Bad effects spec: %s", spec))
+	}
+	name := matches[1]
+	if name == "" { name = "none" }
+	ret, ok := EffectPresets[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown effects preset: %s " +
+			"(try %s)", name, strings.Join(EffectPresetNames(), ", ")))
+	}
+	settings, err := parseSettings(matches[2])
+	if err != nil { return nil, err }
+	err = settings.apply(map[string]*float64{
+		"highpass": &ret.HighPass, "lowpass": &ret.LowPass,
+		"bass": &ret.Bass, "mid": &ret.Mid, "treble": &ret.Treble,
+		"reverb": &ret.Reverb, "room": &ret.Room })
+	if err != nil { return nil, err }
+	if ret.HighPass < 0 || ret.LowPass < 0 || ret.Reverb < 0 ||
+			ret.Reverb > 1 || ret.Room < 0 ||
+			(ret.Reverb > 0 && ret.Room == 0) {
+		return nil, errors.New(fmt.Sprintf("Bad effects settings: %s",
+			spec))
+	}
+	if ret == (Effects{ Preset: ret.Preset }) { return nil, nil }
+	return &ret, nil
+}
+
+// EffectPresetNames lists the presets in order.
+func EffectPresetNames() []string {
+	names := make([]string, 0)
+	for name := range EffectPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply runs interleaved audio through the chain, returning it with room
+// on the end for the reverb to ring out.
+func (e *Effects) apply(pcm []float64, rate, channels int) []float64 {
+	out := append([]float64{}, pcm...)
+	filters := make([]biquad, 0)
+	if e.HighPass > 0 { filters = append(filters, highPass(rate, e.HighPass)) }
+	if e.LowPass > 0 { filters = append(filters, lowPass(rate, e.LowPass)) }
+	if e.Bass != 0 {
+		filters = append(filters, lowShelf(rate, bassFreq, e.Bass))
+	}
+	if e.Mid != 0 {
+		filters = append(filters, peaking(rate, midFreq, e.Mid))
+	}
+	if e.Treble != 0 {
+		filters = append(filters, highShelf(rate, trebleFreq, e.Treble))
+	}
+	for _, f := range filters {
+		f.run(out, channels)
+	}
+	if e.Reverb > 0 {
+		tail := int(e.Room * float64(rate))
+		out = append(out, make([]float64, tail*channels)...)
+		reverb(out, rate, channels, e.Reverb, e.Room)
+	}
+	return out
+}
+
+// biquad is a second-order filter (see the RBJ Audio EQ Cookbook), with its
+// coefficients already divided by a0.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// newBiquad normalizes the coefficients.
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{ b0/a0, b1/a0, b2/a0, a1/a0, a2/a0 }
+}
+
+// angles works out the cookbook's w0 terms for a frequency, which is kept
+// below Nyquist.
+func angles(rate int, freq float64) (float64, float64) {
+	freq = math.Min(freq, 0.45*float64(rate))
+	w0 := 2 * math.Pi * freq / float64(rate)
+	return math.Cos(w0), math.Sin(w0)
+}
+
+// lowPass cuts above freq.
+func lowPass(rate int, freq float64) biquad {
+	cos, sin := angles(rate, freq)
+	alpha := sin / math.Sqrt2
+	return newBiquad((1-cos)/2, 1-cos, (1-cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+// highPass cuts below freq.
+func highPass(rate int, freq float64) biquad {
+	cos, sin := angles(rate, freq)
+	alpha := sin / math.Sqrt2
+	return newBiquad((1+cos)/2, -(1+cos), (1+cos)/2, 1+alpha, -2*cos,
+		1-alpha)
+}
+
+// peaking boosts or cuts around freq by db, an octave or so wide.
+func peaking(rate int, freq, db float64) biquad {
+	cos, sin := angles(rate, freq)
+	a := math.Pow(10, db/40)
+	alpha := sin / 2
+	return newBiquad(1+alpha*a, -2*cos, 1-alpha*a, 1+alpha/a, -2*cos,
+		1-alpha/a)
+}
+
+// lowShelf boosts or cuts below freq by db.
+func lowShelf(rate int, freq, db float64) biquad {
+	cos, sin := angles(rate, freq)
+	a := math.Pow(10, db/40)
+	k := 2 * math.Sqrt(a) * sin / math.Sqrt2
+	return newBiquad(a*((a+1) - (a-1)*cos + k), 2*a*((a-1) - (a+1)*cos),
+		a*((a+1) - (a-1)*cos - k), (a+1) + (a-1)*cos + k,
+		-2*((a-1) + (a+1)*cos), (a+1) + (a-1)*cos - k)
+}
+
+// highShelf boosts or cuts above freq by db.
+func highShelf(rate int, freq, db float64) biquad {
+	cos, sin := angles(rate, freq)
+	a := math.Pow(10, db/40)
+	k := 2 * math.Sqrt(a) * sin / math.Sqrt2
+	return newBiquad(a*((a+1) + (a-1)*cos + k), -2*a*((a-1) + (a+1)*cos),
+		a*((a+1) + (a-1)*cos - k), (a+1) - (a-1)*cos + k,
+		2*((a-1) - (a+1)*cos), (a+1) - (a-1)*cos - k)
+}
+
+// run filters interleaved audio in place, each channel separately.
+func (b biquad) run(pcm []float64, channels int) {
+	for ch := 0; ch < channels; ch++ {
+		var x1, x2, y1, y2 float64
+		for i := ch; i < len(pcm); i += channels {
+			x := pcm[i]
+			y := b.b0*x + b.b1*x1 + b.b2*x2 - b.a1*y1 - b.a2*y2
+			x2, x1, y2, y1 = x1, x, y1, y
+			pcm[i] = y
+		}
+	}
+}
+
+// The Schroeder reverb's comb and allpass delays in ms.  Each channel gets
+// them stretched a little so stereo doesn't come out mono.
+var combDelays = []float64{ 29.7, 37.1, 41.1, 43.7 }
+var allpassDelays = []float64{ 5.0, 1.7 }
+
+// reverb adds a Schroeder reverb (parallel combs into series allpasses) to
+// interleaved audio in place, wet being how much of the output it makes up
+// and room how long it takes to die away by 60dB.
+func reverb(pcm []float64, rate, channels int, wet, room float64) {
+	frames := len(pcm)/channels
+	for ch := 0; ch < channels; ch++ {
+		spread := 1 + 0.02*float64(ch)
+		dry := make([]float64, frames)
+		for i := range dry {
+			dry[i] = pcm[i*channels+ch]
+		}
+		wetSig := make([]float64, frames)
+		for _, ms := range combDelays {
+			delay := int(ms * spread * float64(rate) / 1000)
+			if delay < 1 { delay = 1 }
+			gain := math.Pow(10, -3*float64(delay)/float64(rate)/room)
+			line := make([]float64, delay)
+			for i := range dry {
+				y := line[i%delay]
+				line[i%delay] = dry[i] + y*gain
+				wetSig[i] += y / float64(len(combDelays))
+			}
+		}
+		for _, ms := range allpassDelays {
+			delay := int(ms * spread * float64(rate) / 1000)
+			if delay < 1 { delay = 1 }
+			line := make([]float64, delay)
+			for i := range wetSig {
+				d := line[i%delay]
+				v := wetSig[i] + 0.7*d
+				line[i%delay] = v
+				wetSig[i] = d - 0.7*v
+			}
+		}
+		for i := range dry {
+			pcm[i*channels+ch] = dry[i]*(1-wet) + wetSig[i]*wet
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Test routines for the effect chain.
+
+package woofie
+
+import (
+	"math"
+	"testing"
+)
+
+// TestNewEffects checks presets, overrides and bad specs.
+func TestNewEffects(t *testing.T) {
+	e, err := NewEffects("behind-door:lowpass=800")
+	if err != nil { t.Fatal(err) }
+	expected := EffectPresets["behind-door"]
+	expected.LowPass = 800
+	if *e != expected { t.Error("Wrong effects: ", *e) }
+	for _, spec := range []string{ "", "none", " " } {
+		e, err = NewEffects(spec)
+		if err != nil || e != nil {
+			t.Errorf("%q: expected no effects, got %v, %v", spec, e,
+				err)
+		}
+	}
+	e, err = NewEffects(":highpass=100")
+	if err != nil || e == nil || e.HighPass != 100 {
+		t.Error("Expected a bare high-pass, got ", e, err)
+	}
+	for _, spec := range []string{ "kennel", "garage:reverb=2",
+			"none:reverb=0.5", "garage:echo=1" } {
+		_, err = NewEffects(spec)
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+}
+
+// level is the RMS of a stretch of audio.
+func level(pcm []float64) float64 {
+	sum := 0.0
+	for _, v := range pcm {
+		sum += v*v
+	}
+	return math.Sqrt(sum / float64(len(pcm)))
+}
+
+// sine is a mono tone at 48kHz.
+func sine(freq float64, frames int) []float64 {
+	pcm := make([]float64, frames)
+	for i := range pcm {
+		pcm[i] = math.Sin(2*math.Pi*freq*float64(i)/48000)
+	}
+	return pcm
+}
+
+// TestFilters checks the filters pass what they should and cut what they
+// shouldn't.
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		effects Effects
+		freq, gain float64
+	}{
+		{ Effects{ LowPass: 1000 }, 100, 1 },
+		{ Effects{ LowPass: 1000 }, 10000, 0.01 },
+		{ Effects{ HighPass: 1000 }, 100, 0.01 },
+		{ Effects{ HighPass: 1000 }, 10000, 1 },
+		{ Effects{ Bass: 6 }, 50, 2 },
+		{ Effects{ Treble: -6 }, 15000, 0.5 },
+		{ Effects{ Mid: 6 }, 1000, 2 },
+	}
+	for _, test := range tests {
+		out := test.effects.apply(sine(test.freq, 48000), 48000, 1)
+		gain := level(out[24000:]) / level(sine(test.freq, 24000))
+		if math.Abs(math.Log2(gain / test.gain)) > 0.15 &&
+				!(test.gain < 0.1 && gain < 0.1) {
+			t.Errorf("%+v at %gHz: expected gain %g, got %g",
+				test.effects, test.freq, test.gain, gain)
+		}
+	}
+}
+
+// TestReverb checks an impulse rings on after it, for about as long as the
+// room says.
+func TestReverb(t *testing.T) {
+	pcm := make([]float64, 2*48)
+	pcm[0], pcm[1] = 1, 1
+	e := Effects{ Reverb: 0.5, Room: 0.5 }
+	out := e.apply(pcm, 48000, 2)
+	if len(out) != len(pcm) + 2*24000 {
+		t.Fatal("Expected half a second of tail, got ", len(out))
+	}
+	if out[0] != 0.5 { t.Error("Expected half the impulse dry, got ", out[0]) }
+	early := level(out[2*4800:2*9600])
+	late := level(out[2*19200:2*24000])
+	if early == 0 || late > early/20 {
+		t.Errorf("Expected the reverb to die away, got %g then %g",
+			early, late)
+	}
+	if out[2*4800] == out[2*4800+1] {
+		t.Error("Expected the channels to differ")
+	}
+}
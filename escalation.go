@@ -0,0 +1,149 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements bark escalation: a dog starts with a growl and works
+// its way up if the intruder sticks around, calming back down once things
+// have been quiet for a while.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Level is one step on the escalation ladder.
+type Level struct {
+	// Name is what the level is called in logs and status.
+	Name string
+	// Sounds is the sound set (subdirectory of the woofdir) to play from;
+	// if it's empty or missing, any sound will do.
+	Sounds string
+	// Volume is the playback volume in % (0 means 100).
+	Volume int
+	// Duration is how long to bark in secs (0 means --resolution).
+	Duration int
+}
+
+// Gain is the level's volume as a multiplier.
+func (l Level) Gain() float32 {
+	if l.Volume == 0 { return 1.0 }
+	return float32(l.Volume) / 100.0
+}
+
+// DefaultLevels is the escalation ladder if nobody asks for another one.
+const DefaultLevels = "growl,bark,big-dog,frenzy"
+
+// Escalation tracks which level the dog is at.
+type Escalation struct {
+	// Levels is the ladder, calmest first.
+	Levels []Level
+	// Window is how soon after the last trigger another one has to come
+	// to move up a level.
+	Window time.Duration
+	// Decay is how long it takes to calm down by one level.
+	Decay time.Duration
+	level int
+	last time.Time
+}
+
+// NewEscalation builds an escalation ladder from a --levels spec and an
+// --escalation spec.  levels is a comma-separated list of
+// name[:sounds[:volume[:duration]]], where sounds defaults to the name; e.g.
+// "growl::60,bark,frenzy:frenzy:100:30".  settings is "window=secs,decay=secs".
+func NewEscalation(levels, settings string) (*Escalation, error) {
+	regexpInit()
+	ret := Escalation{ Window: time.Minute, Decay: 5*time.Minute }
+	for _, spec := range sepre.Split(strings.TrimSpace(levels), -1) {
+		parts := strings.Split(spec, ":")
+		if len(parts) > 4 || parts[0] == "" {
+			return nil, errors.New(fmt.Sprintf("Bad level: %s",
+				spec))
+		}
+		level := Level{ Name: parts[0], Sounds: parts[0] }
+		if len(parts) > 1 && parts[1] != "" { level.Sounds = parts[1] }
+		var err error
+		if len(parts) > 2 && parts[2] != "" {
+			level.Volume, err = strconv.Atoi(parts[2])
+			if err != nil || level.Volume < 0 {
+				return nil, errors.New(fmt.Sprintf(
+					"Bad volume in level: %s", spec))
+			}
+		}
+		if len(parts) > 3 && parts[3] != "" {
+			level.Duration, err = strconv.Atoi(parts[3])
+			if err != nil || level.Duration < 0 {
+				return nil, errors.New(fmt.Sprintf(
+					"Bad duration in level: %s", spec))
+			}
+		}
+		ret.Levels = append(ret.Levels, level)
+	}
+	s, err := parseSettings(settings)
+	if err != nil { return nil, err }
+	window := ret.Window.Seconds()
+	decay := ret.Decay.Seconds()
+	err = s.apply(map[string]*float64{ "window": &window, "decay": &decay })
+	if err != nil { return nil, err }
+	if window < 0 || decay <= 0 {
+		return nil, errors.New("Window can't be negative and decay " +
+			"must be positive")
+	}
+	ret.Window = time.Duration(window * float64(time.Second))
+	ret.Decay = time.Duration(decay * float64(time.Second))
+	return &ret, nil
+}
+
+// current works out the level index as of now, after calming down.
+func (e *Escalation) current(now time.Time) int {
+	if e.last.IsZero() { return 0 }
+	calm := int(now.Sub(e.last) / e.Decay)
+	if calm >= e.level { return 0 }
+	return e.level - calm
+}
+
+// Current is the level the dog is at now.
+func (e *Escalation) Current(now time.Time) Level {
+	return e.Levels[e.current(now)]
+}
+
+// next works out the level index a trigger at now would move the dog to:
+// up one if it came hot on the heels of the last one.
+func (e *Escalation) next(now time.Time) int {
+	level := e.current(now)
+	if !e.last.IsZero() && now.Sub(e.last) <= e.Window &&
+			level+1 < len(e.Levels) {
+		level++
+	}
+	return level
+}
+
+// Next is the level a trigger at now would leave the dog at, without
+// recording the trigger.
+func (e *Escalation) Next(now time.Time) Level {
+	return e.Levels[e.next(now)]
+}
+
+// Trigger records a trigger at now, moving up a level if it came hot on the
+// heels of the last one, and returns the resulting level.
+func (e *Escalation) Trigger(now time.Time) Level {
+	e.level = e.next(now)
+	e.last = now
+	return e.Levels[e.level]
+}
+
+// String describes the ladder in the same syntax NewEscalation takes.
+func (e *Escalation) String() string {
+	levels := make([]string, len(e.Levels))
+	for i, l := range e.Levels {
+		levels[i] = fmt.Sprintf("%s:%s:%d:%d", l.Name, l.Sounds,
+			l.Volume, l.Duration)
+	}
+	return fmt.Sprintf("%s window=%g,decay=%g", strings.Join(levels, ","),
+		e.Window.Seconds(), e.Decay.Seconds())
+}
@@ -0,0 +1,87 @@
+// Test routines for bark escalation.
+
+package woofie
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewEscalation checks the level and settings parsing.
+func TestNewEscalation(t *testing.T) {
+	e, err := NewEscalation("growl::60, bark ,frenzy:loud:100:30",
+		"window=30")
+	if err != nil { t.Fatal(err) }
+	expected := "growl:growl:60:0,bark:bark:0:0,frenzy:loud:100:30 " +
+		"window=30,decay=300"
+	if e.String() != expected {
+		t.Error("Expected ", expected, ", got ", e.String())
+	}
+	if e.Levels[0].Gain() != 0.6 || e.Levels[1].Gain() != 1.0 {
+		t.Error("Wrong gains: ", e.Levels)
+	}
+	bad := [][]string{ { "", "" }, { "a:b:c", "" }, { "a:b:1:2:3", "" },
+		{ "a", "decay=0" }, { "a", "speed=1" } }
+	for _, spec := range bad {
+		_, err := NewEscalation(spec[0], spec[1])
+		if err == nil { t.Error("Expected error parsing ", spec) }
+	}
+}
+
+// TestEscalation walks the dog up the ladder and lets it calm down again.
+func TestEscalation(t *testing.T) {
+	e, err := NewEscalation(DefaultLevels, "window=60,decay=300")
+	if err != nil { t.Fatal(err) }
+	now := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	steps := []struct {
+		after time.Duration
+		level string
+	}{
+		{ 0, "growl" },
+		{ 30*time.Second, "bark" },
+		{ 30*time.Second, "big-dog" },
+		{ 30*time.Second, "frenzy" },
+		// Can't get any angrier than frenzy.
+		{ 30*time.Second, "frenzy" },
+		// Five minutes calms down one level, and this trigger came
+		// too late to escalate.
+		{ 5*time.Minute, "big-dog" },
+		{ 30*time.Second, "frenzy" },
+		// Twenty minutes is enough to calm all the way down.
+		{ 20*time.Minute, "growl" },
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		level := e.Trigger(now)
+		if level.Name != step.level {
+			t.Errorf("Step %d: expected %s, got %s", i, step.level,
+				level.Name)
+		}
+	}
+	if e.Current(now.Add(10*time.Minute)).Name != "growl" {
+		t.Error("Didn't calm down")
+	}
+}
+
+// TestEscalationSuppressed checks only barks move the dog up the ladder, so
+// triggers it's too tired for don't leave it in a frenzy.
+func TestEscalationSuppressed(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	p := woofer.Parameters()
+	p.Scoring = "window:window=30,max=2"
+	err := woofer.SetParameters(p)
+	if err != nil { t.Fatal(err) }
+	// A fresh cycle, then one more bark before the window fills up.
+	for i, want := range []bool{ true, true, false, false, false, false } {
+		got, _ := woofer.WoofOnFrom(Sensor{})
+		if got != want {
+			t.Errorf("Trigger %d: expected %v, got %v", i, want, got)
+		}
+		clock.Advance(30*time.Second)
+	}
+	// Counting the tired triggers would have got it to frenzy.
+	if level := woofer.Status().Level; level != "bark" {
+		t.Error("Expected two barks to reach bark, got ", level)
+	}
+}
@@ -0,0 +1,107 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the event bus that lets watchers (e.g. the gRPC
+// WatchEvents call) follow along with what the virtual dog is doing.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"sync"
+	"time"
+)
+
+// The various kinds of event the Woofer publishes.
+const (
+	EventOn = "on"
+	EventOff = "off"
+	EventAuthorized = "authorized"
+	EventSuppressed = "suppressed"
+	EventPlay = "play"
+	EventError = "error"
+	EventSchedule = "schedule"
+	EventParameters = "parameters"
+	EventProfile = "profile"
+	EventArm = "arm"
+	EventInterrupted = "interrupted"
+	EventVolume = "volume"
+	EventSpeakerDown = "speaker-down"
+	EventSpeakerUp = "speaker-up"
+)
+
+// Sensor identifies whatever tripped a trigger.  All of it is optional; HTTP
+// triggers only know what the query string says, and UDP ones only know who
+// sent the packet.
+type Sensor struct {
+	// ID is a unique name for the sensor (e.g. "front-door").
+	ID string
+	// Zone is the area the sensor watches (e.g. "porch").
+	Zone string
+	// Kind is the sensor type (e.g. "pir").
+	Kind string
+	// Labels is any extra free-form metadata.
+	Labels map[string]string
+}
+
+// Event is one thing that happened to the virtual dog.
+type Event struct {
+	// Time is when it happened.
+	Time time.Time
+	// Kind is one of the Event* constants.
+	Kind string
+	// Message is a human-readable description.
+	Message string
+	// Sensor is the sensor responsible, if any.
+	Sensor Sensor
+	// Score is the fatigue score at the time, if relevant.
+	Score int
+	// Level is the escalation level at the time, if relevant.
+	Level string
+}
+
+// EventBus fans events out to any number of subscribers.  Slow subscribers
+// lose events rather than holding up the Woofer.
+type EventBus struct {
+	subs map[chan Event]bool
+	sync.Mutex
+}
+
+// NewEventBus creates an empty bus.
+func NewEventBus() *EventBus {
+	return &EventBus{ subs: make(map[chan Event]bool) }
+}
+
+// Subscribe returns a channel that receives every event published from now
+// on.  depth is how many events may queue up before they get dropped.
+func (b *EventBus) Subscribe(depth int) chan Event {
+	ch := make(chan Event, depth)
+	b.Lock()
+	b.subs[ch] = true
+	b.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel from Subscribe and closes it.
+func (b *EventBus) Unsubscribe(ch chan Event) {
+	b.Lock()
+	if b.subs[ch] {
+		delete(b.subs, ch)
+		close(ch)
+	}
+	b.Unlock()
+}
+
+// Publish hands an event to every subscriber without blocking.
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() { e.Time = time.Now() }
+	b.Lock()
+	defer b.Unlock()
+	for ch := range b.subs {
+		select {
+			case ch <- e:
+			default:
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the FLAC decoder.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"github.com/mewkiz/flac"
+)
+
+// flacDecoder decodes FLAC files a frame at a time.
+type flacDecoder struct {
+	stream *flac.Stream
+	info Info
+	// shift scales the samples up to 32 bits.
+	shift uint
+}
+
+// openFlac starts decoding a FLAC file.
+func openFlac(path string) (decoder, error) {
+	stream, err := flac.Open(path)
+	if err != nil { return nil, err }
+	info := Info{ "flac", int(stream.Info.SampleRate),
+		int(stream.Info.NChannels), int64(stream.Info.NSamples) }
+	return &flacDecoder{ stream, info,
+		uint(32 - int(stream.Info.BitsPerSample)) }, nil
+}
+
+// Info implements decoder.
+func (d *flacDecoder) Info() Info {
+	return d.info
+}
+
+// Read implements decoder, returning one FLAC frame's worth.
+func (d *flacDecoder) Read() ([]int32, error) {
+	frame, err := d.stream.ParseNext()
+	if err != nil { return nil, err }
+	channels := len(frame.Subframes)
+	n := int(frame.BlockSize)
+	buf := make([]int32, n*channels)
+	for ch, sub := range frame.Subframes {
+		for i := 0; i < n && i < len(sub.Samples); i++ {
+			buf[i*channels+ch] = sub.Samples[i] << d.shift
+		}
+	}
+	return buf, nil
+}
+
+// Close implements decoder.
+func (d *flacDecoder) Close() error {
+	return d.stream.Close()
+}
@@ -0,0 +1,117 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the sample formats: figuring out what a file is from
+// its contents and getting PCM out of it.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Info is what we know about a sample, whatever format it's in.
+type Info struct {
+	// Format is the name of the format (e.g. "flac", "mp3").
+	Format string
+	// SampleRate is the number of frames per second.
+	SampleRate int
+	// NChannels is the number of channels.
+	NChannels int
+	// NSamples is the length in frames (0 if unknown).
+	NSamples int64
+}
+
+// Duration is how long the sample plays for (0 if unknown).
+func (i Info) Duration() float64 {
+	if i.SampleRate == 0 { return 0 }
+	return float64(i.NSamples) / float64(i.SampleRate)
+}
+
+// decoder gets PCM out of a sample file.
+type decoder interface {
+	// Info describes the sample.
+	Info() Info
+	// Read returns the next batch of interleaved samples, scaled to the
+	// full 32 bits, or io.EOF at the end.
+	Read() ([]int32, error)
+	// Close closes the file.
+	Close() error
+}
+
+// format is one kind of sample file.
+type format struct {
+	// name is what Info calls it.
+	name string
+	// detect reports whether the start of a file looks like this format.
+	detect func(header []byte) bool
+	// open starts decoding a file.
+	open func(path string) (decoder, error)
+}
+
+// formats is every format we can play, in the order they're tried.
+var formats = []format{
+	{ "flac", func(h []byte) bool { return bytes.HasPrefix(h,
+		[]byte("fLaC")) }, openFlac },
+	{ "wav", func(h []byte) bool { return len(h) >= 12 &&
+		string(h[0:4]) == "RIFF" && string(h[8:12]) == "WAVE" },
+		openWav },
+	{ "vorbis", func(h []byte) bool { return bytes.HasPrefix(h,
+		[]byte("OggS")) && bytes.Contains(h, []byte("\x01vorbis")) },
+		openVorbis },
+	{ "opus", func(h []byte) bool { return bytes.HasPrefix(h,
+		[]byte("OggS")) && bytes.Contains(h, []byte("OpusHead")) },
+		openOpus },
+	{ "mp3", detectMp3, openMp3 },
+}
+
+// headerSize is how much of a file detection gets to look at.
+const headerSize = 64
+
+// detectFormat works out what a file is from its first few bytes, returning
+// nil if it isn't anything we know.
+func detectFormat(path string) (*format, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF { return nil, err }
+	for i := range formats {
+		if formats[i].detect(header[:n]) { return &formats[i], nil }
+	}
+	return nil, nil
+}
+
+// detectMp3 spots an ID3 tag or an MPEG audio frame header.
+func detectMp3(h []byte) bool {
+	if bytes.HasPrefix(h, []byte("ID3")) { return true }
+	return len(h) >= 2 && h[0] == 0xff && h[1]&0xe0 == 0xe0
+}
+
+// openSample starts decoding a file of a known format.
+func openSample(path string, f *format) (decoder, error) {
+	dec, err := f.open(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Bad %s file %s: %s",
+			f.name, path, err.Error()))
+	}
+	return dec, nil
+}
+
+// floatToPCM converts samples in -1..1 to full-scale 32-bit ones.
+func floatToPCM(in []float32) []int32 {
+	ret := make([]int32, len(in))
+	for i, v := range in {
+		f := math.Max(math.Min(float64(v), 1.0), -1.0)
+		ret[i] = int32(f * math.MaxInt32)
+	}
+	return ret
+}
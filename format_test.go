@@ -0,0 +1,400 @@
+// Test routines for the sample formats.
+
+package woofie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDetectFormat checks each format is recognized by its contents and
+// that anything else isn't.
+func TestDetectFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	tests := map[string]string{
+		"fLaC\x00\x00\x00\x22": "flac",
+		"RIFF\x24\x00\x00\x00WAVEfmt ": "wav",
+		"OggS\x00\x02\x00\x00\x01vorbis": "vorbis",
+		"OggS\x00\x02\x00\x00OpusHead": "opus",
+		"ID3\x04\x00": "mp3",
+		"\xff\xfb\x90\x64": "mp3",
+		"Just a README": "",
+	}
+	i := 0
+	for header, expected := range tests {
+		// The names are no help on purpose.
+		path := filepath.Join(dir, "sample" + string('a' + rune(i)))
+		i++
+		err = ioutil.WriteFile(path, []byte(header), 0644)
+		if err != nil { t.Fatal(err) }
+		f, err := detectFormat(path)
+		if err != nil { t.Fatal(err) }
+		name := ""
+		if f != nil { name = f.name }
+		if name != expected {
+			t.Errorf("%q: expected %q, got %q", header, expected, name)
+		}
+	}
+}
+
+// TestWavDecode records a WAV with the WAV sink and plays it back.
+func TestWavDecode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bark")
+	sink, err := NewWavSink(path)
+	if err != nil { t.Fatal(err) }
+	pcm := make([]int32, 3000)
+	for i := range pcm {
+		pcm[i] = int32(i - 1500) << 16
+	}
+	err = sink.Write(8000, 2, pcm)
+	if err != nil { t.Fatal(err) }
+	err = sink.Close()
+	if err != nil { t.Fatal(err) }
+
+	sound, err := NewSample(path)
+	if err != nil { t.Fatal(err) }
+	info := sound.Info()
+	if info != (Info{ "wav", 8000, 2, 1500 }) {
+		t.Fatal("Wrong info: ", sound)
+	}
+	got := make([]int32, 0)
+	err = sound.decode(func(buf []int32) error {
+		got = append(got, buf...)
+		return nil
+	})
+	if err != nil { t.Fatal(err) }
+	if len(got) != len(pcm) {
+		t.Fatal("Expected ", len(pcm), " samples, got ", len(got))
+	}
+	for i := range pcm {
+		if got[i] != pcm[i] {
+			t.Fatalf("Sample %d: expected %d, got %d", i, pcm[i],
+				got[i])
+		}
+	}
+}
+
+// decodeAll decodes a whole sample.
+func decodeAll(t *testing.T, sound *Sound) []int32 {
+	got := make([]int32, 0)
+	err := sound.decode(func(buf []int32) error {
+		got = append(got, buf...)
+		return nil
+	})
+	if err != nil { t.Fatal(err) }
+	return got
+}
+
+// writeFixture writes a test sample into dir.
+func writeFixture(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, data, 0644)
+	if err != nil { t.Fatal(err) }
+	return path
+}
+
+// bitWriter packs bits most significant first (as FLAC wants), or least
+// significant first (as Vorbis does) if lsb is set.
+type bitWriter struct {
+	buf []byte
+	n uint
+	lsb bool
+}
+
+func (w *bitWriter) write(v uint64, bits uint) {
+	for i := uint(0); i < bits; i++ {
+		bit := v >> (bits - 1 - i) & 1
+		if w.lsb { bit = v >> i & 1 }
+		if w.n%8 == 0 { w.buf = append(w.buf, 0) }
+		if w.lsb {
+			w.buf[len(w.buf)-1] |= byte(bit << (w.n%8))
+		} else {
+			w.buf[len(w.buf)-1] |= byte(bit << (7 - w.n%8))
+		}
+		w.n++
+	}
+}
+
+// crc is an MSB-first CRC of the given width and polynomial.
+func crc(data []byte, width uint, poly uint32) uint32 {
+	var c uint32
+	top := uint32(1) << (width - 1)
+	mask := uint32(1) << width - 1
+	for _, b := range data {
+		c ^= uint32(b) << (width - 8)
+		for i := 0; i < 8; i++ {
+			if c&top != 0 {
+				c = (c << 1) ^ poly
+			} else {
+				c <<= 1
+			}
+			c &= mask
+		}
+	}
+	return c
+}
+
+// flacFixture makes a 16-bit 8kHz FLAC file of pcm (interleaved, in the top
+// 16 bits), in verbatim frames of up to 1152 frames.
+func flacFixture(channels int, pcm []int32) []byte {
+	frames := len(pcm)/channels
+	w := &bitWriter{}
+	w.write(0x664c6143, 32)
+	// STREAMINFO, the last metadata block.
+	w.write(1, 1)
+	w.write(0, 7)
+	w.write(34, 24)
+	w.write(1152, 16)
+	w.write(1152, 16)
+	w.write(0, 24)
+	w.write(0, 24)
+	w.write(8000, 20)
+	w.write(uint64(channels-1), 3)
+	w.write(15, 5)
+	w.write(uint64(frames), 36)
+	w.write(0, 64)
+	w.write(0, 64)
+	out := w.buf
+	for n, start := 0, 0; start < frames; n, start = n+1, start+1152 {
+		size := frames - start
+		if size > 1152 { size = 1152 }
+		f := &bitWriter{}
+		f.write(0xfff8, 16)
+		// Block size in 16 bits at the end, 8kHz, independent
+		// channels, 16 bits.
+		f.write(7, 4)
+		f.write(4, 4)
+		f.write(uint64(channels-1), 4)
+		f.write(4, 3)
+		f.write(0, 1)
+		f.write(uint64(n), 8)
+		f.write(uint64(size-1), 16)
+		f.write(uint64(crc(f.buf, 8, 0x07)), 8)
+		for ch := 0; ch < channels; ch++ {
+			f.write(2, 8)
+			for i := start; i < start+size; i++ {
+				f.write(uint64(uint16(pcm[i*channels+ch] >> 16)), 16)
+			}
+		}
+		f.write(uint64(crc(f.buf, 16, 0x8005)), 16)
+		out = append(out, f.buf...)
+	}
+	return out
+}
+
+// oggPage makes an Ogg page holding whole packets.
+func oggPage(flags byte, granule int64, seq uint32, packets ...[]byte) []byte {
+	page := []byte("OggS\x00")
+	page = append(page, flags)
+	page = binary.LittleEndian.AppendUint64(page, uint64(granule))
+	page = binary.LittleEndian.AppendUint32(page, 1)
+	page = binary.LittleEndian.AppendUint32(page, seq)
+	page = binary.LittleEndian.AppendUint32(page, 0)
+	lacing := []byte{}
+	body := []byte{}
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		body = append(body, p...)
+	}
+	page = append(page, byte(len(lacing)))
+	page = append(append(page, lacing...), body...)
+	// The Ogg CRC doesn't reflect, unlike most 32-bit ones.
+	binary.LittleEndian.PutUint32(page[22:], crc(page, 32, 0x04c11db7))
+	return page
+}
+
+// vorbisFixture makes an 8kHz Ogg Vorbis file of frames of silence, as the
+// simplest stream there is: one mode of 256-frame blocks, with every floor
+// unused.
+func vorbisFixture(channels int, frames int64) []byte {
+	id := []byte("\x01vorbis\x00\x00\x00\x00")
+	id = append(id, byte(channels))
+	id = binary.LittleEndian.AppendUint32(id, 8000)
+	id = append(id, make([]byte, 12)...)
+	id = append(id, 0xb8, 1)
+	comment := []byte("\x03vorbis\x06\x00\x00\x00woofie\x00\x00\x00\x00\x01")
+	w := &bitWriter{ lsb: true }
+	// One codebook of two one-bit entries.
+	w.write(0, 8)
+	w.write(0x564342, 24)
+	w.write(1, 16)
+	w.write(2, 24)
+	w.write(0, 2)
+	w.write(0, 10)
+	w.write(0, 4)
+	// No time domain transforms.
+	w.write(0, 6)
+	w.write(0, 16)
+	// A type 1 floor with one partition of one point, in a class with
+	// no books.
+	w.write(0, 6)
+	w.write(1, 16)
+	w.write(1, 5)
+	w.write(0, 4)
+	w.write(0, 3)
+	w.write(0, 2)
+	w.write(0, 8)
+	w.write(0, 2)
+	w.write(8, 4)
+	w.write(128, 8)
+	// A type 0 residue of nothing.
+	w.write(0, 6)
+	w.write(0, 16)
+	w.write(0, 72)
+	w.write(0, 6)
+	w.write(0, 8)
+	w.write(0, 4)
+	// One mapping with one submap.
+	w.write(0, 6)
+	w.write(0, 16)
+	w.write(0, 4)
+	w.write(0, 24)
+	// One mode, short blocks.
+	w.write(0, 6)
+	w.write(0, 41)
+	w.write(1, 1)
+	setup := append([]byte("\x05vorbis"), w.buf...)
+
+	out := oggPage(2, 0, 0, id)
+	out = append(out, oggPage(0, 0, 1, comment, setup)...)
+	// Each block after the first is 128 frames more.
+	packets := [][]byte{}
+	for i := int64(0); i <= (frames+127)/128; i++ {
+		packets = append(packets, []byte{ 0 })
+	}
+	return append(out, oggPage(4, frames, 2, packets...)...)
+}
+
+// opusFixture makes an Ogg Opus header and packets of silence (each a 20ms
+// frame of nothing), ending at the given granule position.
+func opusFixture(channels int, preSkip uint16, granule int64) []byte {
+	head := []byte("OpusHead\x01")
+	head = append(head, byte(channels))
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	head = append(head, 0, 0, 0)
+	tags := []byte("OpusTags\x06\x00\x00\x00woofie\x00\x00\x00\x00")
+	out := oggPage(2, 0, 0, head)
+	out = append(out, oggPage(0, 0, 1, tags)...)
+	packets := [][]byte{}
+	for i := int64(0); i < (granule+959)/960; i++ {
+		packets = append(packets, []byte{ 0xf8 })
+	}
+	return append(out, oggPage(4, granule, 2, packets...)...)
+}
+
+// mp3Fixture makes an MP3 of silent 128kbps 44.1kHz mono frames (1152 frames
+// of audio apiece).
+func mp3Fixture(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{ 0xff, 0xfb, 0x90, 0xc0 })
+	return bytes.Repeat(frame, n)
+}
+
+// TestFlacDecode checks a FLAC file's format and samples come through.
+func TestFlacDecode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	pcm := make([]int32, 3000*2)
+	for i := range pcm {
+		pcm[i] = int32(i*7 - 20000) << 16
+	}
+	sound, err := NewSample(writeFixture(t, dir, "bark",
+		flacFixture(2, pcm)))
+	if err != nil { t.Fatal(err) }
+	if sound.Info() != (Info{ "flac", 8000, 2, 3000 }) {
+		t.Fatal("Wrong info: ", sound.Info())
+	}
+	got := decodeAll(t, sound)
+	if len(got) != len(pcm) {
+		t.Fatal("Expected ", len(pcm), " samples, got ", len(got))
+	}
+	for i := range pcm {
+		if got[i] != pcm[i] {
+			t.Fatalf("Sample %d: expected %d, got %d", i, pcm[i],
+				got[i])
+		}
+	}
+}
+
+// TestVorbisDecode checks an Ogg Vorbis file's format and length come
+// through.
+func TestVorbisDecode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	sound, err := NewSample(writeFixture(t, dir, "bark",
+		vorbisFixture(2, 1000)))
+	if err != nil { t.Fatal(err) }
+	if sound.Info() != (Info{ "vorbis", 8000, 2, 1000 }) {
+		t.Fatal("Wrong info: ", sound.Info())
+	}
+	got := decodeAll(t, sound)
+	if len(got) != 1000*2 {
+		t.Fatal("Expected 2000 samples, got ", len(got))
+	}
+	for i, v := range got {
+		if v != 0 { t.Fatalf("Sample %d: expected silence, got %d", i, v) }
+	}
+}
+
+// TestOpusInfo checks the channels come from the OpusHead and the length
+// from the last granule position, less the pre-skip.
+func TestOpusInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := writeFixture(t, dir, "bark", opusFixture(2, 312, 48312))
+	f, err := detectFormat(path)
+	if err != nil { t.Fatal(err) }
+	if f == nil || f.name != "opus" { t.Fatal("Expected opus, got ", f) }
+	file, err := os.Open(path)
+	if err != nil { t.Fatal(err) }
+	defer file.Close()
+	info, err := opusInfo(file)
+	if err != nil { t.Fatal(err) }
+	if info != (Info{ "opus", 48000, 2, 48000 }) {
+		t.Error("Wrong info: ", info)
+	}
+
+	path = writeFixture(t, dir, "empty", opusFixture(0, 312, 48312))
+	file, err = os.Open(path)
+	if err != nil { t.Fatal(err) }
+	defer file.Close()
+	_, err = opusInfo(file)
+	if err == nil { t.Error("Expected an error for no channels") }
+}
+
+// TestMp3Decode checks an MP3 comes out as stereo whatever it was recorded
+// as, a frame's worth per four bytes of decoded audio.
+func TestMp3Decode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	sound, err := NewSample(writeFixture(t, dir, "bark", mp3Fixture(10)))
+	if err != nil { t.Fatal(err) }
+	if sound.Info() != (Info{ "mp3", 44100, 2, 11520 }) {
+		t.Fatal("Wrong info: ", sound.Info())
+	}
+	got := decodeAll(t, sound)
+	if len(got) != 11520*2 {
+		t.Fatal("Expected 23040 samples, got ", len(got))
+	}
+	for i, v := range got {
+		if v != 0 { t.Fatalf("Sample %d: expected silence, got %d", i, v) }
+	}
+}
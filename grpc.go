@@ -0,0 +1,363 @@
+// Woofie gRPC trigger.  Serves the typed control and event API described in
+// woofiepb/woofie.proto, optionally over TLS (with client certificates if a
+// CA is given).
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"github.com/wjblack/woofie/woofiepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"time"
+)
+
+// GrpcWoofTrigger holds the port and (optional) TLS setup for the trigger.
+type GrpcWoofTrigger struct {
+	port int
+	creds credentials.TransportCredentials
+}
+
+// NewGrpcWoofTrigger sets up the gRPC server.  If cert and key are empty the
+// server runs in plaintext; if ca is also given, clients must present a
+// certificate signed by it.
+func NewGrpcWoofTrigger(port int, cert, key, ca string) (*GrpcWoofTrigger,
+		error) {
+	ret := GrpcWoofTrigger{ port, nil }
+	if cert == "" && key == "" {
+		if ca != "" {
+			return nil, errors.New("TLS CA given without cert/key")
+		}
+		return &ret, nil
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil { return nil, err }
+	config := tls.Config{ Certificates: []tls.Certificate{ pair } }
+	if ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil { return nil, err }
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(fmt.Sprintf(
+				"No certificates found in %s", ca))
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	ret.creds = credentials.NewTLS(&config)
+	return &ret, nil
+}
+
+// Server builds a gRPC server wired up to the woofer, ready to Serve().
+func (wt GrpcWoofTrigger) Server(woofer *Woofer) *grpc.Server {
+	opts := []grpc.ServerOption{}
+	if wt.creds != nil {
+		opts = append(opts, grpc.Creds(wt.creds))
+	}
+	srv := grpc.NewServer(opts...)
+	woofiepb.RegisterWoofieServer(srv, &grpcWoofServer{ woofer: woofer })
+	return srv
+}
+
+// MainLoop starts up a listener to talk with the woofer thread and starts
+// processing requests as configured.
+func (wt GrpcWoofTrigger) MainLoop(logger *log.Logger, woofer *Woofer) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", wt.port))
+	if err != nil { return err }
+	logger.Printf("gRPC listening on port %d (tls=%t)\n", wt.port,
+		wt.creds != nil)
+	err = wt.Server(woofer).Serve(lis)
+	if err != nil {
+		logger.Printf("Critical error: %s\n", err.Error())
+	}
+	return err
+}
+
+// grpcWoofServer implements woofiepb.WoofieServer on top of a Woofer.
+type grpcWoofServer struct {
+	woofiepb.UnimplementedWoofieServer
+	woofer *Woofer
+}
+
+// Trigger turns the bark cycle on or off.
+func (gs *grpcWoofServer) Trigger(ctx context.Context,
+		req *woofiepb.TriggerRequest) (*woofiepb.TriggerReply, error) {
+	sensor := sensorFromPb(req.GetSensor())
+	ret := woofiepb.TriggerReply{}
+	if req.GetOn() {
+		logger.Println(requestLine(true, sensor))
+		authorized, score := gs.woofer.WoofOnFrom(sensor)
+		ret.Authorized = authorized
+		ret.Score = int32(score)
+	} else {
+		logger.Println(requestLine(false, sensor))
+		gs.woofer.WoofOffFrom(sensor)
+	}
+	ret.Status = statusToPb(gs.woofer.Status())
+	return &ret, nil
+}
+
+// GetStatus reports the Woofer's status.
+func (gs *grpcWoofServer) GetStatus(ctx context.Context,
+		req *woofiepb.StatusRequest) (*woofiepb.Status, error) {
+	return statusToPb(gs.woofer.Status()), nil
+}
+
+// WatchEvents streams events until the client goes away.
+func (gs *grpcWoofServer) WatchEvents(req *woofiepb.WatchRequest,
+		stream woofiepb.Woofie_WatchEventsServer) error {
+	kinds := make(map[string]bool)
+	for _, kind := range req.GetKinds() {
+		kinds[kind] = true
+	}
+	events := gs.woofer.Events.Subscribe(64)
+	defer gs.woofer.Events.Unsubscribe(events)
+	for {
+		select {
+			case <-stream.Context().Done():
+				return nil
+			case e := <-events:
+				if len(kinds) != 0 && !kinds[e.Kind] { continue }
+				err := stream.Send(eventToPb(e))
+				if err != nil { return err }
+		}
+	}
+}
+
+// UpdateParameters changes whichever parameters were set in the request.
+func (gs *grpcWoofServer) UpdateParameters(ctx context.Context,
+		req *woofiepb.Parameters) (*woofiepb.Parameters, error) {
+	p := gs.woofer.Parameters()
+	if req.Resolution != nil { p.Resolution = int(req.GetResolution()) }
+	if req.Horizon != nil { p.Horizon = int(req.GetHorizon()) }
+	if req.Score != nil { p.Score = int(req.GetScore()) }
+	if req.Factor != nil { p.Factor = int(req.GetFactor()) }
+	if req.Scoring != nil { p.Scoring = req.GetScoring() }
+	err := gs.woofer.SetParameters(p)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return parametersToPb(gs.woofer.Parameters()), nil
+}
+
+// SetSchedule parses and installs a new quiet schedule.  An empty string
+// means never be quiet.
+func (gs *grpcWoofServer) SetSchedule(ctx context.Context,
+		req *woofiepb.ScheduleRequest) (*woofiepb.ScheduleReply, error) {
+	schedule := &Schedules{}
+	if req.GetSchedule() != "" {
+		var err error
+		schedule, err = NewSchedules(req.GetSchedule())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument,
+				err.Error())
+		}
+	}
+	gs.woofer.SetSchedule(schedule)
+	return &woofiepb.ScheduleReply{ Schedule: schedule.Dump() }, nil
+}
+
+// PlaySound plays a sample right away.
+func (gs *grpcWoofServer) PlaySound(ctx context.Context,
+		req *woofiepb.PlaySoundRequest) (*woofiepb.PlaySoundReply,
+		error) {
+	name, err := gs.woofer.PlaySound(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &woofiepb.PlaySoundReply{ Name: name }, nil
+}
+
+// SetProfile switches personalities.
+func (gs *grpcWoofServer) SetProfile(ctx context.Context,
+		req *woofiepb.SetProfileRequest) (*woofiepb.Profile, error) {
+	err := gs.woofer.SetProfile(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	gs.woofer.Lock()
+	defer gs.woofer.Unlock()
+	return profileToPb(gs.woofer.Profiles[req.GetName()]), nil
+}
+
+// ListProfiles lists the available personalities.
+func (gs *grpcWoofServer) ListProfiles(ctx context.Context,
+		req *woofiepb.ListProfilesRequest) (*woofiepb.ProfileList,
+		error) {
+	gs.woofer.Lock()
+	defer gs.woofer.Unlock()
+	ret := woofiepb.ProfileList{}
+	for _, name := range gs.woofer.Profiles.Names() {
+		ret.Profiles = append(ret.Profiles,
+			profileToPb(gs.woofer.Profiles[name]))
+	}
+	return &ret, nil
+}
+
+// SetArmed arms, disarms or snoozes.
+func (gs *grpcWoofServer) SetArmed(ctx context.Context,
+		req *woofiepb.ArmRequest) (*woofiepb.Status, error) {
+	if req.GetSnoozeMinutes() < 0 {
+		return nil, status.Error(codes.InvalidArgument,
+			"Negative snooze")
+	}
+	if req.GetSnoozeMinutes() > 0 {
+		gs.woofer.Snooze(time.Duration(req.GetSnoozeMinutes()) *
+			time.Minute)
+	} else if req.GetArmed() {
+		gs.woofer.Arm()
+	} else {
+		gs.woofer.Disarm()
+	}
+	return statusToPb(gs.woofer.Status()), nil
+}
+
+// SetVolume changes whichever parts of the volume setup were set in the
+// request.
+func (gs *grpcWoofServer) SetVolume(ctx context.Context,
+		req *woofiepb.VolumeRequest) (*woofiepb.Volume, error) {
+	v := gs.woofer.Status().Volume
+	var err error
+	if req.Master != nil { v.Master = int(req.GetMaster()) }
+	if req.Curve != nil { v.Curve, err = ParseVolumeCurve(req.GetCurve()) }
+	if err == nil && req.Sets != nil {
+		v.Sets, err = ParseSetVolumes(req.GetSets())
+	}
+	if err == nil { err = gs.woofer.SetVolume(v) }
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s := gs.woofer.Status()
+	return volumeToPb(s.Volume, s.CurrentVolume), nil
+}
+
+// sensorFromPb converts the wire sensor into ours.
+func sensorFromPb(s *woofiepb.Sensor) Sensor {
+	if s == nil { return Sensor{} }
+	return Sensor{ s.GetId(), s.GetZone(), s.GetKind(), s.GetLabels() }
+}
+
+// sensorToPb converts our sensor into the wire version.
+func sensorToPb(s Sensor) *woofiepb.Sensor {
+	return &woofiepb.Sensor{ Id: s.ID, Zone: s.Zone, Kind: s.Kind,
+		Labels: s.Labels }
+}
+
+// timeToPb converts a time, leaving zero times unset.
+func timeToPb(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() { return nil }
+	return timestamppb.New(t)
+}
+
+// parametersToPb converts the bark parameters.
+func parametersToPb(p Parameters) *woofiepb.Parameters {
+	resolution := int32(p.Resolution)
+	horizon := int32(p.Horizon)
+	score := int32(p.Score)
+	factor := int32(p.Factor)
+	scoring := p.Scoring
+	return &woofiepb.Parameters{ Resolution: &resolution,
+		Horizon: &horizon, Score: &score, Factor: &factor,
+		Scoring: &scoring }
+}
+
+// statusToPb converts a status snapshot.
+func statusToPb(s Status) *woofiepb.Status {
+	zones := make([]*woofiepb.ZoneStatus, 0)
+	for _, zone := range s.Zones {
+		zones = append(zones, &woofiepb.ZoneStatus{
+			Name: zone.Name,
+			Score: int32(zone.Score),
+			LogSize: int32(zone.LogSize),
+			LastBark: timeToPb(zone.LastBark),
+			Sensitivity: zone.Sensitivity,
+		})
+	}
+	trimmed := make(map[string]float64)
+	for name, d := range s.Trimmed {
+		trimmed[name] = d.Seconds()
+	}
+	return &woofiepb.Status{
+		Barking: s.Barking,
+		Quiet: s.Quiet,
+		WoofUntil: timeToPb(s.WoofUntil),
+		LastBark: timeToPb(s.LastBark),
+		LogSize: int32(s.LogSize),
+		Score: int32(s.Score),
+		Parameters: parametersToPb(s.Parameters),
+		Schedule: s.Schedule,
+		Sounds: s.Sounds,
+		Level: s.Level,
+		Profile: s.Profile,
+		Zones: zones,
+		Armed: s.Armed,
+		SnoozeUntil: timeToPb(s.SnoozeUntil),
+		Cache: cacheStatsToPb(s.Cache),
+		Volume: volumeToPb(s.Volume, s.CurrentVolume),
+		Trimmed: trimmed,
+		SpeakerDown: s.SpeakerDown,
+	}
+}
+
+// volumeToPb converts the volume setup to the wire format.
+func volumeToPb(v Volume, current int) *woofiepb.Volume {
+	sets := make(map[string]int32)
+	for set, volume := range v.Sets {
+		sets[set] = int32(volume)
+	}
+	return &woofiepb.Volume{ Master: int32(v.Master),
+		Curve: v.CurveString(), Sets: sets, Current: int32(current) }
+}
+
+// cacheStatsToPb converts the cache stats, leaving them out if there's no
+// cache.
+func cacheStatsToPb(c CacheStats) *woofiepb.CacheStats {
+	if c.Limit == 0 { return nil }
+	return &woofiepb.CacheStats{
+		Entries: int32(c.Entries),
+		Bytes: c.Bytes,
+		Limit: c.Limit,
+		Hits: c.Hits,
+		Misses: c.Misses,
+		Evictions: c.Evictions,
+	}
+}
+
+// profileToPb converts a personality profile.
+func profileToPb(p Profile) *woofiepb.Profile {
+	return &woofiepb.Profile{
+		Name: p.Name,
+		Scoring: p.Scoring,
+		Factor: int32(p.Factor),
+		Delay: p.Delay,
+		PauseMin: p.PauseMin,
+		PauseMax: p.PauseMax,
+		Levels: p.Levels,
+		Escalation: p.Escalation,
+		Sounds: p.Sounds,
+	}
+}
+
+// eventToPb converts an event.
+func eventToPb(e Event) *woofiepb.Event {
+	return &woofiepb.Event{
+		Time: timeToPb(e.Time),
+		Kind: e.Kind,
+		Message: e.Message,
+		Sensor: sensorToPb(e.Sensor),
+		Score: int32(e.Score),
+		Level: e.Level,
+	}
+}
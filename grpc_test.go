@@ -0,0 +1,107 @@
+// Test routines for the gRPC trigger.
+
+package woofie
+
+import (
+	"github.com/wjblack/woofie/woofiepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// grpcTestClient fires up a gRPC server on an in-memory listener and returns
+// a client connected to it.
+func grpcTestClient(t *testing.T) (woofiepb.WoofieClient, func()) {
+	sounds := &Sounds{ rand: NewRand(1) }
+	schedule := &Schedules{}
+	woofer := NewWoofer(sounds, schedule,
+		log.New(ioutil.Discard, "", 0), 15, 30, 150, 5)
+	trig, err := NewGrpcWoofTrigger(0, "", "", "")
+	if err != nil { t.Fatal(err) }
+	lis := bufconn.Listen(1 << 16)
+	srv := trig.Server(woofer)
+	go srv.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(
+			func(ctx context.Context, s string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil { t.Fatal(err) }
+	return woofiepb.NewWoofieClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+// TestGrpc runs a trigger, a parameter update and a schedule change through
+// the API and checks that status and events follow along.
+func TestGrpc(t *testing.T) {
+	client, done := grpcTestClient(t)
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := client.WatchEvents(ctx,
+		&woofiepb.WatchRequest{ Kinds: []string{ EventOff } })
+	if err != nil { t.Fatal(err) }
+
+	// Wait for the watcher to get subscribed before triggering.
+	time.Sleep(100*time.Millisecond)
+	sensor := &woofiepb.Sensor{ Id: "front-door", Zone: "porch" }
+	reply, err := client.Trigger(ctx,
+		&woofiepb.TriggerRequest{ On: true, Sensor: sensor })
+	if err != nil { t.Fatal(err) }
+	if !reply.GetAuthorized() || !reply.GetStatus().GetBarking() {
+		t.Error("Expected a fresh bark cycle, got ", reply)
+	}
+	_, err = client.Trigger(ctx,
+		&woofiepb.TriggerRequest{ On: false, Sensor: sensor })
+	if err != nil { t.Fatal(err) }
+	e, err := events.Recv()
+	if err != nil { t.Fatal(err) }
+	if e.GetKind() != EventOff || e.GetSensor().GetId() != "front-door" {
+		t.Error("Unexpected event ", e)
+	}
+
+	score := int32(99)
+	params, err := client.UpdateParameters(ctx,
+		&woofiepb.Parameters{ Score: &score })
+	if err != nil { t.Fatal(err) }
+	if params.GetScore() != 99 || params.GetHorizon() != 30 {
+		t.Error("Unexpected parameters ", params)
+	}
+	factor := int32(101)
+	_, err = client.UpdateParameters(ctx,
+		&woofiepb.Parameters{ Factor: &factor })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("Expected InvalidArgument for factor=101, got ", err)
+	}
+
+	sched, err := client.SetSchedule(ctx,
+		&woofiepb.ScheduleRequest{ Schedule: "6=12-17" })
+	if err != nil { t.Fatal(err) }
+	if sched.GetSchedule() != " Saturday: 12:00-17:00\n" {
+		t.Error("Unexpected schedule ", sched.GetSchedule())
+	}
+	_, err = client.SetSchedule(ctx,
+		&woofiepb.ScheduleRequest{ Schedule: "8=12-17" })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("Expected InvalidArgument for a bad schedule, got ",
+			err)
+	}
+
+	_, err = client.PlaySound(ctx,
+		&woofiepb.PlaySoundRequest{ Name: "nope.flac" })
+	if status.Code(err) != codes.NotFound {
+		t.Error("Expected NotFound for a missing sound, got ", err)
+	}
+}
@@ -1,15 +1,23 @@
 // Woofie HTTP trigger.  Assumes a unicast HTTP request of the form:
-//    http://$ip:$port/$path/<on|off>
+//    http://$ip:$port/$path/<on|off>[?sensor=$id&zone=$zone]
+// or, to tell the dog to stand down for a while:
+//    http://$ip:$port/$path/<arm|disarm|snooze[?minutes=$n]>
+// or to turn it up or down:
+//    http://$ip:$port/$path/volume[?master=$pct&curve=$curve&sets=$sets]
 
 // (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
 
 package woofie
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // HttpWoofTrigger holds the basic path and port info for the trigger assembly.
@@ -32,15 +40,47 @@ func NewHttpWoofTrigger(path string, port int) (*HttpWoofTrigger, error) {
 func (wt HttpWoofTrigger) MainLoop(logger *log.Logger, woofer *Woofer) error {
 	http.HandleFunc(wt.path, func(w http.ResponseWriter, r *http.Request) {
 		cmd := strings.TrimPrefix(r.URL.Path, wt.path)
+		query := r.URL.Query()
+		sensor := Sensor{ ID: query.Get("sensor"),
+			Zone: query.Get("zone"), Kind: "http" }
 		switch cmd {
 			case "on":
-				woofer.WoofOn()
+				logger.Println(requestLine(true, sensor))
+				woofer.WoofOnFrom(sensor)
 				fmt.Fprintf(w, "OK")
-				logger.Println("Received on request")
 			case "off":
-				woofer.WoofOff()
+				logger.Println(requestLine(false, sensor))
+				woofer.WoofOffFrom(sensor)
 				fmt.Fprintf(w, "OK")
-				logger.Println("Received off request")
+			case "arm":
+				woofer.Arm()
+				fmt.Fprintf(w, "OK")
+			case "disarm":
+				woofer.Disarm()
+				fmt.Fprintf(w, "OK")
+			case "snooze":
+				minutes := 60
+				if query.Get("minutes") != "" {
+					var err error
+					minutes, err = strconv.Atoi(
+						query.Get("minutes"))
+					if err != nil || minutes <= 0 {
+						fmt.Fprintf(w, "ERROR: Bad " +
+							"minutes '%s'",
+							query.Get("minutes"))
+						return
+					}
+				}
+				woofer.Snooze(time.Duration(minutes)*time.Minute)
+				fmt.Fprintf(w, "OK")
+			case "volume":
+				err := setVolume(woofer, query)
+				if err != nil {
+					fmt.Fprintf(w, "ERROR: %s", err.Error())
+					return
+				}
+				fmt.Fprintf(w, "OK %s",
+					woofer.Status().Volume.String())
 			default:
 				fmt.Fprintf(w, "ERROR: Unrecognized command '%s'", cmd)
 		}
@@ -51,3 +91,27 @@ func (wt HttpWoofTrigger) MainLoop(logger *log.Logger, woofer *Woofer) error {
 	}
 	return err
 }
+
+// setVolume changes whichever parts of the volume setup are in the query
+// (any of master, curve and sets, as for the matching options).
+func setVolume(woofer *Woofer, query url.Values) error {
+	v := woofer.Status().Volume
+	var err error
+	if _, ok := query["master"]; ok {
+		v.Master, err = strconv.Atoi(query.Get("master"))
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad master '%s'",
+				query.Get("master")))
+		}
+	}
+	if _, ok := query["curve"]; ok {
+		v.Curve, err = ParseVolumeCurve(query.Get("curve"))
+		if err != nil { return err }
+	}
+	if _, ok := query["sets"]; ok {
+		v.Sets, err = ParseSetVolumes(query.Get("sets"))
+		if err != nil { return err }
+	}
+	if len(query) == 0 { return nil }
+	return woofer.SetVolume(v)
+}
@@ -0,0 +1,195 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements loudness normalization, so samples from different
+// sources all come out about as loud, and the limiter that keeps the boosted
+// ones from clipping.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The loudness analysis measures blockLength blocks and ignores any quieter
+// than gateDB (silence between barks shouldn't make a sample seem quiet).
+const (
+	blockLength = 0.1
+	gateDB = -70.0
+)
+
+// maxBoost is the most a quiet sample gets turned up, in dB, so a nearly
+// silent one doesn't become a blast of hiss.
+const maxBoost = 20.0
+
+// releaseTime is how long the limiter takes to let go after a peak.
+const releaseTime = 50*time.Millisecond
+
+// Loudness is how loud a sample is.
+type Loudness struct {
+	// RMS is the average level of the sample's audible parts, in dBFS.
+	RMS float64 `json:"rms"`
+	// Peak is the level of its loudest sample, in dBFS.
+	Peak float64 `json:"peak"`
+}
+
+// Normalizer brings every sample to the same loudness.
+type Normalizer struct {
+	// Target is the RMS level to bring the samples to, in dBFS.
+	Target float64
+	// Ceiling is the level the limiter holds peaks to, in dBFS.
+	Ceiling float64
+	// CachePath is a file to keep the analysis in between runs, so the
+	// samples don't all have to be decoded at startup ("" for none).
+	CachePath string
+}
+
+// WithNormalizer analyzes the samples as they're loaded and evens out their
+// loudness when they play.
+func WithNormalizer(n *Normalizer) Option {
+	return func(o *options) { o.normalizer = n }
+}
+
+// loudnessEntry is a cached analysis, good as long as the file is unchanged.
+type loudnessEntry struct {
+	Size int64 `json:"size"`
+	Modified time.Time `json:"modified"`
+	Loudness
+}
+
+// analyze measures the samples' loudness, reading what it can from the
+// cache file and writing back what it had to work out.
+func (s *Sounds) analyze(n *Normalizer) error {
+	cache := make(map[string]loudnessEntry)
+	if n.CachePath != "" {
+		buf, err := ioutil.ReadFile(n.CachePath)
+		if err == nil { err = json.Unmarshal(buf, &cache) }
+		if err != nil && !os.IsNotExist(err) {
+			logger.Printf("Ignoring loudness cache %s: %s\n",
+				n.CachePath, err.Error())
+		}
+	}
+	dirty := false
+	for _, sound := range s.Samples {
+		path, err := filepath.Abs(sound.filepath)
+		if err != nil { return err }
+		stat, err := os.Stat(path)
+		if err != nil { return err }
+		entry, ok := cache[path]
+		if !ok || entry.Size != stat.Size() ||
+				!entry.Modified.Equal(stat.ModTime()) {
+			loudness, err := sound.measure()
+			if err != nil { return err }
+			entry = loudnessEntry{ stat.Size(), stat.ModTime(),
+				loudness }
+			cache[path] = entry
+			dirty = true
+		}
+		sound.loudness = entry.Loudness
+		sound.normalizer = n
+	}
+	if n.CachePath == "" || !dirty { return nil }
+	buf, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil { return err }
+	return ioutil.WriteFile(n.CachePath, buf, 0644)
+}
+
+// measure decodes the sample and works out its loudness.
+func (s *Sound) measure() (Loudness, error) {
+	block := int(blockLength * float64(s.metadata.SampleRate)) *
+		s.metadata.NChannels
+	if block < 1 { block = 1 }
+	gate := math.Pow(10, gateDB/10)
+	var power, total, peak float64
+	var blocks, n int
+	err := s.decode(func(buf []int32) error {
+		for _, v := range buf {
+			x := float64(v) / -math.MinInt32
+			peak = math.Max(peak, math.Abs(x))
+			power += x*x
+			n++
+			if n == block {
+				if power/float64(n) > gate {
+					total += power/float64(n)
+					blocks++
+				}
+				power, n = 0, 0
+			}
+		}
+		return nil
+	})
+	if err != nil { return Loudness{}, err }
+	if n > 0 && power/float64(n) > gate {
+		total += power/float64(n)
+		blocks++
+	}
+	// Silence comes out at the gate rather than -Inf, which JSON can't do.
+	ret := Loudness{ gateDB, gateDB }
+	if blocks > 0 { ret.RMS = 10*math.Log10(total/float64(blocks)) }
+	if peak > 0 { ret.Peak = math.Max(20*math.Log10(peak), gateDB) }
+	return ret, nil
+}
+
+// gain is what the sample gets multiplied by to hit the target (1 without
+// a Normalizer or for silence).
+func (s *Sound) gain() float64 {
+	if s.normalizer == nil || s.loudness.RMS <= gateDB { return 1 }
+	db := math.Min(s.normalizer.Target - s.loudness.RMS, maxBoost)
+	return math.Pow(10, db/20)
+}
+
+// limiter holds peaks down to a ceiling, clamping straight away and easing
+// off afterwards so it doesn't pump.
+type limiter struct {
+	// ceiling is the highest a sample can go.
+	ceiling float64
+	// release is how much of the gain reduction is left after a frame.
+	release float64
+	// gain is the current gain reduction (1 for none).
+	gain float64
+}
+
+// newLimiter makes a limiter at ceiling dBFS for audio at rate.
+func newLimiter(ceiling float64, rate int) *limiter {
+	release := math.Exp(-1 / (releaseTime.Seconds() * float64(rate)))
+	return &limiter{ math.Pow(10, ceiling/20) * -math.MinInt32, release,
+		1 }
+}
+
+// run limits interleaved audio in place.
+func (l *limiter) run(buf []float64, channels int) {
+	for i := 0; i+channels <= len(buf); i += channels {
+		peak := 0.0
+		for _, v := range buf[i:i+channels] {
+			peak = math.Max(peak, math.Abs(v))
+		}
+		want := 1.0
+		if peak > l.ceiling { want = l.ceiling / peak }
+		if want < l.gain {
+			l.gain = want
+		} else {
+			l.gain = want + (l.gain - want)*l.release
+		}
+		for ch := i; ch < i+channels; ch++ {
+			buf[ch] *= l.gain
+		}
+	}
+}
+
+// scale is the package-level scale with the limiter on the end instead of
+// clipping.
+func (l *limiter) scale(buf []int32, channels int, gain float32) []int32 {
+	in := make([]float64, len(buf))
+	for i, v := range buf {
+		in[i] = float64(v) * float64(gain)
+	}
+	l.run(in, channels)
+	return toPCM(in)
+}
@@ -0,0 +1,102 @@
+// Test routines for loudness normalization.
+
+package woofie
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMeasure checks a steady half-scale tone measures -6dBFS.
+func TestMeasure(t *testing.T) {
+	loudness, err := cachedSound(1).measure()
+	if err != nil { t.Fatal(err) }
+	if math.Abs(loudness.RMS + 6.02) > 0.01 ||
+			math.Abs(loudness.Peak + 6.02) > 0.01 {
+		t.Error("Expected -6dBFS, got ", loudness)
+	}
+}
+
+// TestNormalize analyzes a loud and a quiet sample and checks both are
+// brought to the target, and that the analysis comes back from the cache.
+func TestNormalize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	woofs := filepath.Join(dir, "woofs")
+	err = os.Mkdir(woofs, 0755)
+	if err != nil { t.Fatal(err) }
+	for name, level := range map[string]int32{ "loud": 1 << 30,
+			"quiet": 1 << 26 } {
+		sink, err := NewWavSink(filepath.Join(woofs, name + ".wav"))
+		if err != nil { t.Fatal(err) }
+		pcm := make([]int32, 8000)
+		for i := range pcm {
+			pcm[i] = level
+		}
+		err = sink.Write(8000, 1, pcm)
+		if err == nil { err = sink.Close() }
+		if err != nil { t.Fatal(err) }
+	}
+	n := &Normalizer{ -20, -1, filepath.Join(dir, "loudness.json") }
+	sounds, err := NewSounds(woofs, WithNormalizer(n))
+	if err != nil { t.Fatal(err) }
+	for _, sound := range sounds.Samples {
+		rms := sound.Loudness().RMS + 20*math.Log10(sound.gain())
+		if math.Abs(rms + 20) > 0.01 {
+			t.Errorf("%s: expected -20dBFS, got %g", sound.Name(), rms)
+		}
+	}
+
+	// Doctor the cache to prove it's used.
+	buf, err := ioutil.ReadFile(n.CachePath)
+	if err != nil { t.Fatal(err) }
+	cache := make(map[string]loudnessEntry)
+	err = json.Unmarshal(buf, &cache)
+	if err != nil { t.Fatal(err) }
+	if len(cache) != len(sounds.Samples) {
+		t.Fatal("Expected every sample cached, got ", len(cache))
+	}
+	for path, entry := range cache {
+		entry.RMS = -30
+		cache[path] = entry
+	}
+	buf, _ = json.Marshal(cache)
+	err = ioutil.WriteFile(n.CachePath, buf, 0644)
+	if err != nil { t.Fatal(err) }
+	sounds, err = NewSounds(woofs, WithNormalizer(n))
+	if err != nil { t.Fatal(err) }
+	for _, sound := range sounds.Samples {
+		if sound.Loudness().RMS != -30 {
+			t.Errorf("%s: expected the cached loudness, got %g",
+				sound.Name(), sound.Loudness().RMS)
+		}
+	}
+}
+
+// TestLimiter checks peaks are held to the ceiling and the gain comes back
+// afterwards.
+func TestLimiter(t *testing.T) {
+	l := newLimiter(-6, 1000)
+	buf := make([]float64, 1000)
+	for i := range buf {
+		buf[i] = 1 << 29
+	}
+	buf[10] = 1 << 31
+	l.run(buf, 1)
+	ceiling := math.Pow(10, -6.0/20) * (1 << 31)
+	for i, v := range buf {
+		if v > ceiling + 1 {
+			t.Fatalf("Sample %d is %g, over the ceiling %g", i, v,
+				ceiling)
+		}
+	}
+	if math.Abs(buf[999] - (1 << 29)) > 1 {
+		t.Error("Expected the gain to recover, got ", buf[999])
+	}
+	if buf[11] >= 1 << 29 { t.Error("Expected the gain to release slowly") }
+}
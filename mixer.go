@@ -0,0 +1,210 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the mixer, which plays several samples at once so it
+// sounds like more than one dog, each placed where its trigger came from.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Placement is where a voice sounds from.
+type Placement struct {
+	// Pan is where it sits in stereo, from -1 (left) to 1 (right).
+	Pan float64
+	// Channel routes it to one channel of a multichannel card instead
+	// (counting from 1; 0 to pan it).
+	Channel int
+}
+
+// Voice is one dog in the mix.
+type Voice struct {
+	// Sound is what it barks.
+	Sound *Sound
+	// Gain is how loud (1.0 being as recorded).
+	Gain float32
+	// Offset is how long after the start of the mix it starts.
+	Offset time.Duration
+	// Placement is where it sounds from.
+	Placement Placement
+	// Variant is how it's varied from the recording.
+	Variant Variant
+	// Effects is what it's run through (nil for nothing).
+	Effects *Effects
+}
+
+// Mixer mixes voices together into one output format.
+type Mixer struct {
+	// Rate is the output rate (0 to use the first voice's).
+	Rate int
+	// Channels is the number of output channels (0 to use the first
+	// voice's).
+	Channels int
+	// Quality is how voices at other rates get resampled.
+	Quality string
+}
+
+// NewMixer makes a mixer for the given output format.
+func NewMixer(rate, channels int, quality string) (*Mixer, error) {
+	_, err := newKernel(quality, 1)
+	if err != nil { return nil, err }
+	if rate < 0 || channels < 0 {
+		return nil, errors.New(fmt.Sprintf("Bad mixer format: " +
+			"%d-channel %dHz", channels, rate))
+	}
+	return &Mixer{ rate, channels, quality }, nil
+}
+
+// Validate checks a placement fits the mixer's output.  Without a channel
+// count of its own, any channel goes (see place).
+func (m *Mixer) Validate(p Placement) error {
+	if p.Pan < -1 || p.Pan > 1 {
+		return errors.New(fmt.Sprintf("Invalid pan: %g", p.Pan))
+	}
+	if p.Channel < 0 || (m.Channels != 0 && p.Channel > m.Channels) {
+		return errors.New(fmt.Sprintf("No channel %d in %d-channel " +
+			"output", p.Channel, m.Channels))
+	}
+	return nil
+}
+
+// Play mixes the voices and plays them on out.  Like Sound.PlayContext, it
+// fades out and returns ErrInterrupted if ctx is done before the end.
+func (m *Mixer) Play(ctx context.Context, out Sink, voices []Voice,
+		fade time.Duration) error {
+	if len(voices) == 0 { return nil }
+	mix, rate, channels, err := m.render(ctx, voices)
+	if err != nil { return err }
+
+	// Nothing's been played yet, so there's nothing to fade.
+	f := newFader(ctx, out, rate, channels, fade)
+	if ctx.Err() != nil { return f.finish(ErrInterrupted) }
+	for i := 0; i < len(mix) && err == nil; i += chunkFrames*channels {
+		end := i + chunkFrames*channels
+		if end > len(mix) { end = len(mix) }
+		err = f.write(toPCM(mix[i:end]))
+	}
+	return f.finish(err)
+}
+
+// render mixes the voices in memory, returning the mix and its rate and
+// channel count.  It gives up early (with what it has) if ctx is done.
+func (m *Mixer) render(ctx context.Context,
+		voices []Voice) ([]float64, int, int, error) {
+	rate := m.Rate
+	if rate == 0 { rate = voices[0].Sound.metadata.SampleRate }
+	channels := m.Channels
+	if channels == 0 { channels = voices[0].Sound.metadata.NChannels }
+
+	// Barks are short, so just mix the lot in memory.
+	mix := make([]float64, 0)
+	for _, voice := range voices {
+		if ctx.Err() != nil { break }
+		pcm, err := voice.Sound.load(rate, m.Quality)
+		if err != nil { return nil, 0, 0, err }
+		pcm, err = voice.Variant.apply(pcm, rate,
+			voice.Sound.metadata.NChannels, m.Quality)
+		if err != nil { return nil, 0, 0, err }
+		if voice.Effects != nil {
+			pcm = voice.Effects.apply(pcm, rate,
+				voice.Sound.metadata.NChannels)
+		}
+		gain := float64(voice.Gain) * voice.Variant.gain() *
+			voice.Sound.gain()
+		pcm = place(pcm, voice.Sound.metadata.NChannels, channels,
+			voice.Placement)
+		start := int(voice.Offset.Seconds() * float64(rate))*channels
+		if end := start + len(pcm); end > len(mix) {
+			mix = append(mix, make([]float64, end - len(mix))...)
+		}
+		for i, v := range pcm {
+			mix[start+i] += v * gain
+		}
+	}
+
+	// Normalized samples can be turned up past full scale, and dogs
+	// barking at once add up, so the limiter has the last word.
+	if n := voices[0].Sound.normalizer; n != nil {
+		newLimiter(n.Ceiling, rate).run(mix, channels)
+	}
+	return mix, rate, channels, nil
+}
+
+// load decodes the whole sample at the given rate.
+func (s *Sound) load(rate int, quality string) ([]float64, error) {
+	pcm := make([]float64, 0,
+		int(s.metadata.NSamples)*s.metadata.NChannels)
+	err := s.decodeTrimmed(func(buf []int32) error {
+		for _, v := range buf {
+			pcm = append(pcm, float64(v))
+		}
+		return nil
+	})
+	if err != nil { return nil, err }
+	if rate == s.metadata.SampleRate { return pcm, nil }
+	r, err := newResampler(s.metadata.SampleRate, rate,
+		s.metadata.NChannels, quality)
+	if err != nil { return nil, err }
+	pcm = r.write(pcm)
+	return append(pcm, r.flush()...), nil
+}
+
+// place puts a voice with from channels where it belongs in the output.
+// Routed to a channel, it's mixed down to mono and goes only there.  Panned,
+// a mono voice is spread across the first two channels keeping its power
+// the same, and anything else is mixed as usual and has its balance shifted.
+// A channel the output doesn't have (zones can change after they're
+// checked) is logged and ignored.
+func place(in []float64, from, to int, p Placement) []float64 {
+	if p.Channel > to {
+		logger.Printf("No channel %d of %d to play on, playing in the " +
+			"middle\n", p.Channel, to)
+		p = Placement{}
+	}
+	if p.Channel > 0 {
+		mono := mixChannels(in, from, 1)
+		out := make([]float64, len(mono)*to)
+		for i, v := range mono {
+			out[i*to+p.Channel-1] = v
+		}
+		return out
+	}
+	if to < 2 { return mixChannels(in, from, to) }
+	left, right := 1.0, 1.0
+	if from == 1 {
+		angle := (p.Pan + 1) * math.Pi / 4
+		left, right = math.Cos(angle), math.Sin(angle)
+		out := make([]float64, len(in)*to)
+		for i, v := range in {
+			out[i*to] = v * left
+			out[i*to+1] = v * right
+		}
+		return out
+	}
+	if p.Pan > 0 { left = 1 - p.Pan }
+	if p.Pan < 0 { right = 1 + p.Pan }
+	out := mixChannels(in, from, to)
+	for i := 0; i < len(out); i += to {
+		out[i] *= left
+		out[i+1] *= right
+	}
+	return out
+}
+
+// voiceNames lists what the voices are barking, for the logs.
+func voiceNames(voices []Voice) string {
+	names := make([]string, len(voices))
+	for i, voice := range voices {
+		names[i] = voice.Sound.Name()
+	}
+	return strings.Join(names, " + ")
+}
@@ -0,0 +1,101 @@
+// Test routines for the mixer.
+
+package woofie
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"math"
+	"testing"
+	"time"
+)
+
+// maxRand always wants as much as it can get.
+type maxRand float32
+
+func (r maxRand) Float32() float32 { return float32(r) }
+func (r maxRand) Intn(n int) int { return n-1 }
+
+// TestPlace checks panning and channel routing.
+func TestPlace(t *testing.T) {
+	out := place([]float64{ 1 }, 1, 2, Placement{ Pan: -1 })
+	if math.Abs(out[0] - 1) > 1e-9 || math.Abs(out[1]) > 1e-9 {
+		t.Error("Expected hard left, got ", out)
+	}
+	out = place([]float64{ 1 }, 1, 2, Placement{})
+	if math.Abs(out[0] - math.Sqrt2/2) > 1e-9 ||
+			math.Abs(out[1] - math.Sqrt2/2) > 1e-9 {
+		t.Error("Expected the middle at equal power, got ", out)
+	}
+	out = place([]float64{ 2, 4 }, 2, 2, Placement{ Pan: 0.5 })
+	if out[0] != 1 || out[1] != 4 {
+		t.Error("Expected the balance shifted right, got ", out)
+	}
+	out = place([]float64{ 2, 4 }, 2, 4, Placement{ Channel: 3 })
+	if out[0] != 0 || out[1] != 0 || out[2] != 3 || out[3] != 0 {
+		t.Error("Expected mono on channel 3, got ", out)
+	}
+	logger = log.New(ioutil.Discard, "", 0)
+	out = place([]float64{ 2, 4 }, 2, 2, Placement{ Channel: 3 })
+	if len(out) != 2 || out[0] != 2 || out[1] != 4 {
+		t.Error("Expected a missing channel ignored, got ", out)
+	}
+}
+
+// TestMixerPlay mixes two voices, one starting late, and checks they add
+// up where they overlap.
+func TestMixerPlay(t *testing.T) {
+	mixer, err := NewMixer(0, 4, QualityCubic)
+	if err != nil { t.Fatal(err) }
+	if mixer.Validate(Placement{ Channel: 5 }) == nil {
+		t.Error("Expected channel 5 of 4 to be invalid")
+	}
+	out := recordSink{}
+	err = mixer.Play(context.Background(), &out, []Voice{
+		{ cachedSound(1), 0.5, 0, Placement{ Channel: 1 }, Variant{}, nil },
+		{ cachedSound(1), 0.25, 500*time.Millisecond,
+			Placement{ Channel: 1 }, Variant{}, nil },
+	}, 0)
+	if err != nil { t.Fatal(err) }
+	if out.rate != 1000 || out.channels != 4 || len(out.pcm) != 1500*4 {
+		t.Fatalf("Expected 1500 4-channel frames at 1000Hz, got %d " +
+			"%d-channel at %dHz", len(out.pcm)/4, out.channels, out.rate)
+	}
+	expected := []int32{ 1 << 29, 3 << 28, 1 << 28 }
+	for i, frame := range []int{ 0, 700, 1200 } {
+		if out.pcm[frame*4] != expected[i] || out.pcm[frame*4+1] != 0 {
+			t.Errorf("Frame %d: expected %d, got %v", frame,
+				expected[i], out.pcm[frame*4:frame*4+4])
+		}
+	}
+}
+
+// TestWooferVoices checks the extra dogs join in later, quieter and off to
+// the side.
+func TestWooferVoices(t *testing.T) {
+	w := testWoofer(realClock{}, maxRand(0.75), 0)
+	w.WoofSamples.Samples = []*Sound{ cachedSound(1) }
+	w.Voices = 3
+	w.Mixer = nil
+	voices, err := w.voices(nil, 1.0, Zone{ Pan: -1 })
+	if err != nil { t.Fatal(err) }
+	if len(voices) != 1 {
+		t.Error("Expected one dog without a mixer, got ", len(voices))
+	}
+	w.Mixer, _ = NewMixer(0, 0, QualityCubic)
+	voices, err = w.voices(nil, 1.0, Zone{ Pan: -1 })
+	if err != nil { t.Fatal(err) }
+	if len(voices) != 3 { t.Fatal("Expected 3 dogs, got ", len(voices)) }
+	if voices[0].Gain != 1.0 || voices[0].Offset != 0 ||
+			voices[0].Placement.Pan != -1 {
+		t.Error("Expected the first dog as asked, got ", voices[0])
+	}
+	for _, voice := range voices[1:] {
+		if math.Abs(float64(voice.Gain) - 0.9) > 1e-6 ||
+				voice.Offset != 375*time.Millisecond ||
+				voice.Placement.Pan != -0.75 {
+			t.Error("Wrong extra dog: ", voice)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the MP3 decoder.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"github.com/hajimehoshi/go-mp3"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// mp3Decoder decodes MP3 files, which always come out as 16-bit stereo.
+type mp3Decoder struct {
+	file *os.File
+	decoder *mp3.Decoder
+	info Info
+}
+
+// openMp3 starts decoding an MP3 file.
+func openMp3(path string) (decoder, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	dec, err := mp3.NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	info := Info{ "mp3", dec.SampleRate(), 2, dec.Length() / 4 }
+	return &mp3Decoder{ f, dec, info }, nil
+}
+
+// Info implements decoder.
+func (d *mp3Decoder) Info() Info {
+	return d.info
+}
+
+// Read implements decoder.
+func (d *mp3Decoder) Read() ([]int32, error) {
+	raw := make([]byte, chunkFrames*4)
+	n, err := io.ReadFull(d.decoder, raw)
+	if err == io.ErrUnexpectedEOF { err = nil }
+	if n < 2 {
+		if err == nil { err = io.EOF }
+		return nil, err
+	}
+	ret := make([]int32, n/2)
+	for i := range ret {
+		ret[i] = int32(int16(binary.LittleEndian.Uint16(raw[i*2:]))) << 16
+	}
+	return ret, nil
+}
+
+// Close implements decoder.
+func (d *mp3Decoder) Close() error {
+	return d.file.Close()
+}
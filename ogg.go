@@ -0,0 +1,132 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the Ogg Vorbis and Opus decoders.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"github.com/jfreymuth/oggvorbis"
+	"gopkg.in/hraban/opus.v2"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// opusRate is the rate Opus always decodes at.
+const opusRate = 48000
+
+// vorbisDecoder decodes Ogg Vorbis files.
+type vorbisDecoder struct {
+	file *os.File
+	reader *oggvorbis.Reader
+	info Info
+}
+
+// openVorbis starts decoding an Ogg Vorbis file.
+func openVorbis(path string) (decoder, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	reader, err := oggvorbis.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	info := Info{ "vorbis", reader.SampleRate(), reader.Channels(),
+		reader.Length() }
+	return &vorbisDecoder{ f, reader, info }, nil
+}
+
+// Info implements decoder.
+func (d *vorbisDecoder) Info() Info {
+	return d.info
+}
+
+// Read implements decoder.
+func (d *vorbisDecoder) Read() ([]int32, error) {
+	buf := make([]float32, chunkFrames*d.info.NChannels)
+	n, err := d.reader.Read(buf)
+	if n > 0 { return floatToPCM(buf[:n]), nil }
+	if err == nil { err = io.EOF }
+	return nil, err
+}
+
+// Close implements decoder.
+func (d *vorbisDecoder) Close() error {
+	return d.file.Close()
+}
+
+// opusDecoder decodes Ogg Opus files.
+type opusDecoder struct {
+	file *os.File
+	stream *opus.Stream
+	info Info
+}
+
+// openOpus starts decoding an Ogg Opus file.  libopusfile doesn't tell us the
+// channels or length up front, so we dig them out of the Ogg pages ourselves.
+func openOpus(path string) (decoder, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	info, err := opusInfo(f)
+	if err == nil { _, err = f.Seek(0, io.SeekStart) }
+	var stream *opus.Stream
+	if err == nil { stream, err = opus.NewStream(f) }
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &opusDecoder{ f, stream, info }, nil
+}
+
+// opusInfo reads the channel count from the OpusHead packet and the length
+// from the granule position of the last page, less the pre-skip.
+func opusInfo(f *os.File) (Info, error) {
+	info := Info{ Format: "opus", SampleRate: opusRate }
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF { return info, err }
+	i := bytes.Index(header[:n], []byte("OpusHead"))
+	if i < 0 || i+12 > n { return info, errors.New("No OpusHead") }
+	info.NChannels = int(header[i+9])
+	preSkip := int64(binary.LittleEndian.Uint16(header[i+10:]))
+	if info.NChannels == 0 { return info, errors.New("No channels") }
+
+	stat, err := f.Stat()
+	if err != nil { return info, err }
+	tail := int64(65536)
+	if tail > stat.Size() { tail = stat.Size() }
+	buf := make([]byte, tail)
+	_, err = f.ReadAt(buf, stat.Size()-tail)
+	if err != nil && err != io.EOF { return info, err }
+	i = bytes.LastIndex(buf, []byte("OggS"))
+	if i >= 0 && i+14 <= len(buf) {
+		granule := int64(binary.LittleEndian.Uint64(buf[i+6:]))
+		if granule > preSkip { info.NSamples = granule - preSkip }
+	}
+	return info, nil
+}
+
+// Info implements decoder.
+func (d *opusDecoder) Info() Info {
+	return d.info
+}
+
+// Read implements decoder.
+func (d *opusDecoder) Read() ([]int32, error) {
+	buf := make([]float32, chunkFrames*d.info.NChannels)
+	n, err := d.stream.ReadFloat32(buf)
+	if n > 0 { return floatToPCM(buf[:n*d.info.NChannels]), nil }
+	if err == nil { err = io.EOF }
+	return nil, err
+}
+
+// Close implements decoder.
+func (d *opusDecoder) Close() error {
+	d.stream.Close()
+	return d.file.Close()
+}
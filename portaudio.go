@@ -0,0 +1,289 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the portaudio sink, which keeps a stream open between
+// barks instead of setting one up for every sample.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"github.com/gordonklaus/portaudio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commonRates are the rates ListDevices tries each device at.
+var commonRates = []int{ 8000, 11025, 16000, 22050, 32000, 44100, 48000,
+	88200, 96000, 192000 }
+
+// PortaudioSink is a long-lived portaudio output stream.  The stream stays open
+// for as long as the samples keep coming in the same format; a sample with a
+// different rate or channel count reopens it.
+type PortaudioSink struct {
+	// FramesPerBuffer is the size of the stream's buffer in frames.
+	FramesPerBuffer int
+	// Latency is the output latency to ask for (0 for the device's
+	// default).
+	Latency time.Duration
+	// Device picks the output device by index or by part of its name (""
+	// for the default).
+	Device string
+	// Fallback plays on the default device when Device can't be found,
+	// instead of failing.
+	Fallback bool
+	rate, channels int
+	// broken is set when a write fails, until a stream opens again.
+	broken bool
+	stream *portaudio.Stream
+	// current is the stream too, for Reopen to abort without the main
+	// lock.  It's guarded by abortLock, which close also takes, so a
+	// stream is never aborted once it's been freed.
+	current *portaudio.Stream
+	abortLock sync.Mutex
+	// buffer is what the stream plays from, filled up to pending samples.
+	buffer []int32
+	pending int
+	sync.Mutex
+}
+
+// NewPortaudioSink sets up a sink with the given buffer size and latency.
+// The stream isn't opened until there's something to play.
+func NewPortaudioSink(framesPerBuffer int,
+		latency time.Duration) *PortaudioSink {
+	return &PortaudioSink{ FramesPerBuffer: framesPerBuffer,
+		Latency: latency }
+}
+
+// open opens the stream for a format.  The caller must hold the lock.
+func (o *PortaudioSink) open(rate, channels int) error {
+	if o.FramesPerBuffer <= 0 {
+		return errors.New(fmt.Sprintf("Invalid buffer size: %d",
+			o.FramesPerBuffer))
+	}
+	dev, err := o.device()
+	if err != nil { return err }
+	params := portaudio.HighLatencyParameters(nil, dev)
+	params.Output.Channels = channels
+	if o.Latency != 0 { params.Output.Latency = o.Latency }
+	params.SampleRate = float64(rate)
+	params.FramesPerBuffer = o.FramesPerBuffer
+	o.buffer = make([]int32, o.FramesPerBuffer*channels)
+	o.pending = 0
+	stream, err := portaudio.OpenStream(params, &o.buffer)
+	if err != nil { return err }
+	err = stream.Start()
+	if err != nil {
+		stream.Close()
+		return err
+	}
+	logger.Printf("Opened %d-channel %dHz output on %s (%d frames, %s)\n",
+		channels, rate, dev.Name, o.FramesPerBuffer,
+		params.Output.Latency)
+	o.stream, o.rate, o.channels = stream, rate, channels
+	o.broken = false
+	o.abortLock.Lock()
+	o.current = stream
+	o.abortLock.Unlock()
+	return nil
+}
+
+// close shuts the stream, dropping anything not yet written.  The caller
+// must hold the lock.
+func (o *PortaudioSink) close() {
+	if o.stream == nil { return }
+	o.abortLock.Lock()
+	o.current = nil
+	o.abortLock.Unlock()
+	o.stream.Stop()
+	o.stream.Close()
+	o.stream = nil
+}
+
+// Write implements Sink, (re)opening the stream if need be.  It blocks while
+// the stream's buffer is full.
+func (o *PortaudioSink) Write(rate, channels int, buf []int32) error {
+	o.Lock()
+	defer o.Unlock()
+	if o.stream != nil && (o.rate != rate || o.channels != channels) {
+		o.flush()
+		o.close()
+	}
+	if o.stream == nil {
+		err := o.open(rate, channels)
+		if err != nil && o.broken {
+			// The device may have gone away and come back, and
+			// portaudio only looks for devices when it starts.
+			err = o.restart()
+			if err == nil { err = o.open(rate, channels) }
+		}
+		if err != nil { return err }
+	}
+	for len(buf) > 0 {
+		n := copy(o.buffer[o.pending:], buf)
+		o.pending += n
+		buf = buf[n:]
+		if o.pending == len(o.buffer) {
+			err := o.write()
+			if err != nil { return err }
+		}
+	}
+	return nil
+}
+
+// Flush implements Sink, padding the buffer out with silence.
+func (o *PortaudioSink) Flush() error {
+	o.Lock()
+	defer o.Unlock()
+	return o.flush()
+}
+
+// flush is Flush for callers already holding the lock.
+func (o *PortaudioSink) flush() error {
+	if o.stream == nil || o.pending == 0 { return nil }
+	for i := o.pending; i < len(o.buffer); i++ {
+		o.buffer[i] = 0
+	}
+	return o.write()
+}
+
+// write hands the full buffer to the stream.  An underflow just means we
+// were idle for a while, but anything else means the stream is broken, so
+// it's closed (dropping the buffer) and the error returned: the next Write
+// opens a fresh stream, looking for devices all over again if it can't.
+// The caller must hold the lock.
+func (o *PortaudioSink) write() error {
+	err := o.stream.Write()
+	o.pending = 0
+	if err == portaudio.OutputUnderflowed { err = nil }
+	if err != nil {
+		logger.Printf("Output error: %s, closing stream\n", err.Error())
+		o.close()
+		o.broken = true
+	}
+	return err
+}
+
+// Close implements Sink.
+func (o *PortaudioSink) Close() error {
+	o.Lock()
+	defer o.Unlock()
+	err := o.flush()
+	o.close()
+	return err
+}
+
+// Abort stops the stream dead, so a write stuck on it lets go (with an
+// error, which closes the stream).  It doesn't need the main lock.
+func (o *PortaudioSink) Abort() {
+	o.abortLock.Lock()
+	defer o.abortLock.Unlock()
+	if o.current != nil { o.current.Abort() }
+}
+
+// Reopen aborts the stream (even in the middle of a stuck write), looks for
+// devices all over again and reopens it, so a speaker that was unplugged and
+// plugged back in gets picked up.
+func (o *PortaudioSink) Reopen() error {
+	o.Abort()
+	o.Lock()
+	defer o.Unlock()
+	o.close()
+	o.pending = 0
+	err := o.restart()
+	if err != nil { return err }
+	if o.rate == 0 {
+		_, err = o.device()
+		return err
+	}
+	return o.open(o.rate, o.channels)
+}
+
+// restart starts portaudio over, so it looks for devices all over again.
+// The caller must hold the lock.
+func (o *PortaudioSink) restart() error {
+	portaudio.Terminate()
+	return portaudio.Initialize()
+}
+
+// Check makes sure the output device is there, so a missing one is caught
+// at startup rather than at the first bark.
+func (o *PortaudioSink) Check() error {
+	o.Lock()
+	defer o.Unlock()
+	_, err := o.device()
+	return err
+}
+
+// device finds the output device to open, falling back to the default one
+// if it's missing and Fallback is set.  The caller must hold the lock.
+func (o *PortaudioSink) device() (*portaudio.DeviceInfo, error) {
+	if o.Device == "" { return portaudio.DefaultOutputDevice() }
+	devices, err := portaudio.Devices()
+	if err != nil { return nil, err }
+	dev, err := matchDevice(devices, o.Device)
+	if err != nil && o.Fallback {
+		logger.Printf("%s, falling back to the default output\n",
+			err.Error())
+		return portaudio.DefaultOutputDevice()
+	}
+	return dev, err
+}
+
+// matchDevice picks the output device with the given index, or else the
+// first whose name contains spec (ignoring case).
+func matchDevice(devices []*portaudio.DeviceInfo,
+		spec string) (*portaudio.DeviceInfo, error) {
+	index, err := strconv.Atoi(spec)
+	for _, dev := range devices {
+		if dev.MaxOutputChannels == 0 { continue }
+		if err == nil && dev.Index == index { return dev, nil }
+		if err != nil && strings.Contains(strings.ToLower(dev.Name),
+				strings.ToLower(spec)) {
+			return dev, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("No output device matching '%s'",
+		spec))
+}
+
+// ListDevices prints the output devices, with the rates they can play 32-bit
+// stereo (or mono) at, the same format the sink opens.  Portaudio has to be initialized.
+func ListDevices(w io.Writer) error {
+	devices, err := portaudio.Devices()
+	if err != nil { return err }
+	def, _ := portaudio.DefaultOutputDevice()
+	for _, dev := range devices {
+		if dev.MaxOutputChannels == 0 { continue }
+		mark := " "
+		if def != nil && dev.Index == def.Index { mark = "*" }
+		host := ""
+		if dev.HostApi != nil {
+			host = fmt.Sprintf(" (%s)", dev.HostApi.Name)
+		}
+		fmt.Fprintf(w, "%s%3d: %s%s, %d channels, %0.0f Hz default\n", mark,
+			dev.Index, dev.Name, host, dev.MaxOutputChannels,
+			dev.DefaultSampleRate)
+		channels := dev.MaxOutputChannels
+		if channels > 2 { channels = 2 }
+		rates := make([]string, 0)
+		buf := make([]int32, 1024*channels)
+		for _, rate := range commonRates {
+			params := portaudio.HighLatencyParameters(nil, dev)
+			params.Output.Channels = channels
+			params.SampleRate = float64(rate)
+			params.FramesPerBuffer = 1024
+			if portaudio.IsFormatSupported(params, &buf) == nil {
+				rates = append(rates, strconv.Itoa(rate))
+			}
+		}
+		fmt.Fprintf(w, "      rates: %s\n", strings.Join(rates, ", "))
+	}
+	return nil
+}
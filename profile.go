@@ -0,0 +1,155 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements dog personality profiles, which bundle up the knobs
+// that make one dog behave differently from another.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Profile is a named dog personality.
+type Profile struct {
+	// Name is what the profile is called.
+	Name string `json:"-"`
+	// Scoring is the fatigue-scoring spec (see NewScorer).
+	Scoring string `json:"scoring"`
+	// Factor is the % chance of barking through fatigue.
+	Factor int `json:"factor"`
+	// Delay is how long (secs) the dog takes to react to a trigger.
+	Delay float64 `json:"delay"`
+	// PauseMin and PauseMax bound the random pause (secs) between barks.
+	PauseMin float64 `json:"pause_min"`
+	PauseMax float64 `json:"pause_max"`
+	// Levels is the escalation ladder (see NewEscalation).
+	Levels string `json:"levels"`
+	// Escalation is the escalation settings (see NewEscalation).
+	Escalation string `json:"escalation"`
+	// Sounds is the sound sets the dog prefers when its current level
+	// doesn't have a set of its own, most preferred first.
+	Sounds []string `json:"sounds"`
+}
+
+// Validate makes sure the profile's specs all parse and its numbers are
+// sane.
+func (p Profile) Validate() error {
+	_, err := NewScorer(p.Scoring, DefaultHorizon, DefaultScore)
+	if err != nil { return err }
+	_, err = NewEscalation(p.Levels, p.Escalation)
+	if err != nil { return err }
+	if p.Factor < 0 || p.Factor > 100 {
+		return errors.New(fmt.Sprintf("Invalid factor: %d", p.Factor))
+	}
+	if p.Delay < 0 || p.PauseMin < 0 || p.PauseMax < p.PauseMin {
+		return errors.New("Invalid delay or pause")
+	}
+	return nil
+}
+
+// Profiles is a set of profiles by name.
+type Profiles map[string]Profile
+
+// DefaultProfiles returns the built-in personalities.  "default" matches the
+// command-line defaults.
+func DefaultProfiles() Profiles {
+	return Profiles{
+		"default": Profile{ Name: "default", Scoring: "linear",
+			Factor: 5, Levels: DefaultLevels,
+			Escalation: "window=60,decay=300" },
+		// Barks readily, keeps at it and works itself up quickly.
+		"guard": Profile{ Name: "guard",
+			Scoring: "linear:limit=250", Factor: 10,
+			PauseMin: 0.2, PauseMax: 0.8,
+			Levels: "growl,bark,big-dog,frenzy",
+			Escalation: "window=120,decay=600",
+			Sounds: []string{ "big-dog", "bark" } },
+		// Jumps at everything but wears itself out fast.
+		"nervous": Profile{ Name: "nervous",
+			Scoring: "exponential:halflife=5,limit=120",
+			Factor: 20, PauseMax: 0.3,
+			Levels: "bark,frenzy",
+			Escalation: "window=90,decay=120",
+			Sounds: []string{ "yap", "bark" } },
+		// Takes its time, barks a little and goes back to sleep.
+		"lazy": Profile{ Name: "lazy",
+			Scoring: "window:window=60,max=3", Factor: 2,
+			Delay: 3, PauseMin: 1.5, PauseMax: 4,
+			Levels: "growl,bark",
+			Escalation: "window=20,decay=120",
+			Sounds: []string{ "growl" } },
+		// Excitable and yappy, but runs out of puff.
+		"puppy": Profile{ Name: "puppy",
+			Scoring: "bucket:capacity=6,rate=12", Factor: 15,
+			Delay: 0.5, PauseMin: 0.3, PauseMax: 1.5,
+			Levels: "yip:puppy,yap:puppy,bark",
+			Escalation: "window=45,decay=180",
+			Sounds: []string{ "puppy", "yap" } },
+	}
+}
+
+// Load adds custom profiles from the config file.  Each one starts as a copy
+// of the profile named by its "base" field ("default" if none) with any other
+// fields given replacing the base's, e.g.
+//    "profiles": { "grumpy": { "base": "lazy", "factor": 0 } }
+func (ps Profiles) Load(raw map[string]json.RawMessage) error {
+	// Do them in order so errors come out the same every time.
+	names := make([]string, 0)
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var base struct {
+			Base string `json:"base"`
+		}
+		err := json.Unmarshal(raw[name], &base)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad profile %s: %s",
+				name, err.Error()))
+		}
+		if base.Base == "" { base.Base = "default" }
+		profile, ok := ps[base.Base]
+		if !ok {
+			return errors.New(fmt.Sprintf("Profile %s: unknown " +
+				"base %s", name, base.Base))
+		}
+		profile.Sounds = append([]string{}, profile.Sounds...)
+		custom := struct {
+			Base string `json:"base"`
+			*Profile
+		}{ base.Base, &profile }
+		decoder := json.NewDecoder(bytes.NewReader(raw[name]))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&custom)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad profile %s: %s",
+				name, err.Error()))
+		}
+		profile.Name = name
+		err = profile.Validate()
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad profile %s: %s",
+				name, err.Error()))
+		}
+		ps[name] = profile
+	}
+	return nil
+}
+
+// Names lists the profiles in alphabetical order.
+func (ps Profiles) Names() []string {
+	ret := make([]string, 0)
+	for name := range ps {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
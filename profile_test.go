@@ -0,0 +1,84 @@
+// Test routines for personality profiles.
+
+package woofie
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestProfiles checks the built-ins and loads some custom ones from a config
+// file.
+func TestProfiles(t *testing.T) {
+	profiles := DefaultProfiles()
+	for _, name := range profiles.Names() {
+		if err := profiles[name].Validate(); err != nil {
+			t.Error("Built-in ", name, ": ", err)
+		}
+	}
+
+	f, err := ioutil.TempFile("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.Remove(f.Name())
+	f.WriteString(`{ "horizon": 20, "profiles": {
+		"grumpy": { "base": "lazy", "factor": 0, "sounds": [] },
+		"plain": { "delay": 1.5 } } }`)
+	f.Close()
+	config, err := LoadConfig(f.Name())
+	if err != nil { t.Fatal(err) }
+	if _, ok := config.Options["profiles"]; ok {
+		t.Error("Profiles left in with the options")
+	}
+	err = profiles.Load(config.Profiles)
+	if err != nil { t.Fatal(err) }
+	grumpy := profiles["grumpy"]
+	if grumpy.Factor != 0 || grumpy.Delay != 3 || len(grumpy.Sounds) != 0 {
+		t.Error("Bad grumpy profile: ", grumpy)
+	}
+	if len(profiles["lazy"].Sounds) != 1 {
+		t.Error("Loading grumpy clobbered lazy: ", profiles["lazy"])
+	}
+	if profiles["plain"].Scoring != "linear" ||
+			profiles["plain"].Delay != 1.5 {
+		t.Error("Bad plain profile: ", profiles["plain"])
+	}
+
+	bad := []string{ `{ "base": "wolf" }`, `{ "factr": 1 }`,
+		`{ "scoring": "bogus" }`, `{ "pause_min": 2, "pause_max": 1 }` }
+	for _, profile := range bad {
+		raw := map[string]json.RawMessage{
+			"bad": json.RawMessage(profile) }
+		err = profiles.Load(raw)
+		if err == nil { t.Error("Expected error loading ", profile) }
+	}
+}
+
+// TestWooferProfile switches personalities and checks the reaction delay.
+func TestWooferProfile(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	if err := woofer.SetProfile("wolf"); err == nil {
+		t.Error("Expected error on an unknown profile")
+	}
+	if err := woofer.SetProfile("lazy"); err != nil { t.Fatal(err) }
+	status := woofer.Status()
+	if status.Profile != "lazy" || status.Parameters.Factor != 2 ||
+			status.Level != "growl" {
+		t.Error("Profile not applied: ", status)
+	}
+	woofer.WoofOn()
+	if !woofer.WoofStart.Equal(start.Add(3*time.Second)) ||
+			!woofer.WoofUntil.Equal(start.Add(18*time.Second)) {
+		t.Error("Wrong reaction: ", woofer.WoofStart, woofer.WoofUntil)
+	}
+	// Already barking, so no more dithering.
+	clock.Advance(10*time.Second)
+	woofer.WoofOn()
+	if !woofer.WoofUntil.Equal(start.Add(25*time.Second)) {
+		t.Error("Wrong extension: ", woofer.WoofUntil)
+	}
+}
@@ -0,0 +1,394 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the RTP sink, which streams the barks to speakers
+// elsewhere on the network (unicast or multicast, as L16 or Opus), holding
+// back the quicker receivers so they all play together.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"gopkg.in/hraban/opus.v2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The RTP codecs.
+const (
+	CodecL16 = "l16"
+	CodecOpus = "opus"
+)
+
+// netRate is the rate the network sinks convert everything to.
+const netRate = 48000
+
+// netLatency is how far ahead of real time the network sinks run by default,
+// for the receivers to buffer.
+const netLatency = 200*time.Millisecond
+
+// maxPayload keeps RTP packets inside an Ethernet frame.
+const maxPayload = 1200
+
+// packetTime is how much audio goes in a packet (or an HTTP chunk) where
+// the size allows.
+const packetTime = 20*time.Millisecond
+
+// Receiver is somewhere an RTP stream goes.
+type Receiver struct {
+	// Addr is the receiver's address (or a multicast group).
+	Addr *net.UDPAddr
+	// Latency is how long the receiver takes to play what it's sent
+	// (network plus buffering), so the others can be held back to match.
+	Latency time.Duration
+}
+
+// ParseReceivers parses a list like "10.0.0.5:5004@40,10.0.0.6:5004@120"
+// (or just "239.255.0.1:5004"), where each address can carry its latency in
+// ms after an @.
+func ParseReceivers(spec string) ([]Receiver, error) {
+	ret := make([]Receiver, 0)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		receiver := Receiver{}
+		if at := strings.LastIndex(part, "@"); at >= 0 {
+			ms, err := strconv.Atoi(part[at+1:])
+			if err != nil || ms < 0 {
+				return nil, errors.New(fmt.Sprintf("Bad receiver " +
+					"latency: %s", part))
+			}
+			receiver.Latency = time.Duration(ms)*time.Millisecond
+			part = part[:at]
+		}
+		if part == "" { return nil, errors.New("Missing receiver") }
+		var err error
+		receiver.Addr, err = net.ResolveUDPAddr("udp", part)
+		if err != nil { return nil, err }
+		ret = append(ret, receiver)
+	}
+	return ret, nil
+}
+
+// pacer keeps a stream going out in real time, lead ahead of the clock.
+type pacer struct {
+	clock Clock
+	lead time.Duration
+	// start is when the current run of audio started, and sent how much
+	// of it has gone out since.
+	start time.Time
+	sent time.Duration
+}
+
+// begin reports whether the stream ran dry (or hasn't started), in which
+// case a new run starts now.
+func (p *pacer) begin() bool {
+	now := p.clock.Now()
+	if !p.start.IsZero() && !now.After(p.start.Add(p.sent)) {
+		return false
+	}
+	p.start, p.sent = now, 0
+	return true
+}
+
+// wait counts d more audio as sent and sleeps until it's no more than lead
+// ahead.
+func (p *pacer) wait(d time.Duration) {
+	p.sent += d
+	ahead := p.start.Add(p.sent).Sub(p.clock.Now()) - p.lead
+	if ahead > 0 { p.clock.Sleep(ahead) }
+}
+
+// delayLine sends packets to one receiver a fixed time after they're made.
+type delayLine struct {
+	delay time.Duration
+	packets chan delayedPacket
+}
+
+// delayedPacket is a packet waiting in a delayLine.
+type delayedPacket struct {
+	due time.Time
+	data []byte
+}
+
+// RTPSink streams audio over RTP.  The stream takes the format of whatever's
+// written (see SDP), and runs in real time, Latency ahead of the clock.
+// Receivers quicker than the slowest get their packets that much later, so
+// they all play in step.
+type RTPSink struct {
+	// Receivers is where the stream goes.
+	Receivers []Receiver
+	// Codec is CodecL16 or CodecOpus.
+	Codec string
+	// Latency is how far ahead of real time the audio goes out.
+	Latency time.Duration
+	conn *net.UDPConn
+	// ipv4 is whether all the receivers are IPv4, so the socket is too.
+	ipv4 bool
+	clock Clock
+	// epoch is what the RTP timestamps count from.
+	epoch time.Time
+	ssrc uint32
+	seq uint16
+	timestamp uint32
+	// marker flags the first packet after a gap.
+	marker bool
+	rate, channels int
+	// frames is the packet size, and pending what doesn't fill one yet.
+	frames int
+	pending []int32
+	encoder *opus.Encoder
+	pacer pacer
+	lines []*delayLine
+	// draining is the delay lines still sending.
+	draining sync.WaitGroup
+	sync.Mutex
+}
+
+// NewRTPSink starts an RTP stream to the receivers.  Pass WithClock and
+// WithRand to run on something other than the wall clock and a time-seeded
+// RNG (which picks the stream's SSRC).
+func NewRTPSink(receivers []Receiver, codec string, latency time.Duration,
+		opts ...Option) (*RTPSink, error) {
+	if codec != CodecL16 && codec != CodecOpus {
+		return nil, errors.New(fmt.Sprintf("Unknown codec: %s", codec))
+	}
+	if len(receivers) == 0 { return nil, errors.New("No RTP receivers") }
+	o := buildOptions(opts)
+	network := "udp4"
+	for _, receiver := range receivers {
+		if receiver.Addr.IP.To4() == nil { network = "udp" }
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil { return nil, err }
+	ret := &RTPSink{ Receivers: receivers, Codec: codec, Latency: latency,
+		conn: conn, ipv4: network == "udp4", clock: o.clock,
+		epoch: o.clock.Now(),
+		ssrc: uint32(o.rand.Intn(1 << 31)),
+		pacer: pacer{ clock: o.clock, lead: latency } }
+	var slowest time.Duration
+	for _, receiver := range receivers {
+		if receiver.Latency > slowest { slowest = receiver.Latency }
+	}
+	for _, receiver := range receivers {
+		line := &delayLine{ slowest - receiver.Latency, nil }
+		if line.delay > 0 {
+			line.packets = make(chan delayedPacket, 1024)
+			ret.draining.Add(1)
+			go ret.delayed(receiver.Addr, line.packets)
+		}
+		ret.lines = append(ret.lines, line)
+	}
+	return ret, nil
+}
+
+// Multicast sets how many hops (routers) packets to multicast receivers get
+// to cross, which is 1 (the local network) unless it's set, and the network
+// interface they go out on ("" for the one the system picks).
+func (r *RTPSink) Multicast(hops int, iface string) error {
+	var ifi *net.Interface
+	if iface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(iface)
+		if err != nil { return err }
+	}
+	if r.ipv4 {
+		p := ipv4.NewPacketConn(r.conn)
+		err := p.SetMulticastTTL(hops)
+		if err != nil || ifi == nil { return err }
+		return p.SetMulticastInterface(ifi)
+	}
+	p := ipv6.NewPacketConn(r.conn)
+	err := p.SetMulticastHopLimit(hops)
+	if err != nil || ifi == nil { return err }
+	return p.SetMulticastInterface(ifi)
+}
+
+// payloadType is the RTP payload type for the stream: the static ones where
+// L16 has them, and dynamic ones otherwise.
+func (r *RTPSink) payloadType() int {
+	if r.Codec == CodecOpus { return 97 }
+	if r.rate == 44100 && r.channels == 2 { return 10 }
+	if r.rate == 44100 && r.channels == 1 { return 11 }
+	return 96
+}
+
+// clockRate is the rate the RTP timestamps count at.
+func (r *RTPSink) clockRate() int {
+	if r.Codec == CodecOpus { return netRate }
+	return r.rate
+}
+
+// SDP describes the stream for receivers that need telling (e.g. "ffplay
+// -protocol_whitelist file,udp,rtp woofie.sdp").
+func (r *RTPSink) SDP() string {
+	r.Lock()
+	defer r.Unlock()
+	return r.sdp()
+}
+
+// sdp is SDP for callers already holding the lock.
+func (r *RTPSink) sdp() string {
+	addr := r.Receivers[0].Addr
+	family := "IP4"
+	if addr.IP.To4() == nil { family = "IP6" }
+	encoding := fmt.Sprintf("L16/%d/%d", r.rate, r.channels)
+	if r.Codec == CodecOpus { encoding = "opus/48000/2" }
+	return fmt.Sprintf("v=0\r\no=- %d 0 IN IP4 0.0.0.0\r\ns=woofie\r\n" +
+		"c=IN %s %s\r\nt=0 0\r\nm=audio %d RTP/AVP %d\r\n" +
+		"a=rtpmap:%d %s\r\n", r.ssrc, family, addr.IP, addr.Port,
+		r.payloadType(), r.payloadType(), encoding)
+}
+
+// format switches the stream to a new format.  The caller must hold the
+// lock.
+func (r *RTPSink) format(rate, channels int) error {
+	err := r.flush()
+	if err != nil { return err }
+	r.rate, r.channels = rate, channels
+	r.frames = int(packetTime.Seconds() * float64(rate))
+	if r.Codec == CodecOpus {
+		r.encoder, err = opus.NewEncoder(rate, channels, opus.AppAudio)
+		if err != nil { return err }
+	} else if r.frames*channels*2 > maxPayload {
+		r.frames = maxPayload / (channels*2)
+	}
+	logger.Printf("Streaming %d-channel %dHz %s over RTP:\n%s", channels,
+		rate, r.Codec, r.sdp())
+	return nil
+}
+
+// Write implements Sink.
+func (r *RTPSink) Write(rate, channels int, buf []int32) error {
+	r.Lock()
+	defer r.Unlock()
+	if rate != r.rate || channels != r.channels {
+		err := r.format(rate, channels)
+		if err != nil { return err }
+	}
+	r.pending = append(r.pending, buf...)
+	n := r.frames*channels
+	for len(r.pending) >= n {
+		err := r.send(r.pending[:n])
+		if err != nil { return err }
+		r.pending = r.pending[n:]
+	}
+	r.pending = append([]int32{}, r.pending...)
+	return nil
+}
+
+// send packs up a packet's worth of audio and sends it to the receivers,
+// then waits to keep to real time.  The caller must hold the lock.
+func (r *RTPSink) send(buf []int32) error {
+	frames := len(buf)/r.channels
+	if r.pacer.begin() {
+		// Timestamps carry on through the gap as if it had been
+		// sent, so receivers know how long it was.
+		elapsed := r.clock.Now().Sub(r.epoch).Seconds()
+		r.timestamp = uint32(int64(elapsed * float64(r.clockRate())))
+		r.marker = true
+	}
+	var payload []byte
+	if r.Codec == CodecOpus {
+		pcm := make([]int16, r.frames*r.channels)
+		for i, v := range buf {
+			pcm[i] = int16(v >> 16)
+		}
+		payload = make([]byte, 4000)
+		n, err := r.encoder.Encode(pcm, payload)
+		if err != nil { return err }
+		payload = payload[:n]
+	} else {
+		payload = make([]byte, len(buf)*2)
+		for i, v := range buf {
+			binary.BigEndian.PutUint16(payload[i*2:], uint16(v >> 16))
+		}
+	}
+	packet := make([]byte, 12, 12 + len(payload))
+	packet[0] = 0x80
+	packet[1] = byte(r.payloadType())
+	if r.marker { packet[1] |= 0x80 }
+	binary.BigEndian.PutUint16(packet[2:], r.seq)
+	binary.BigEndian.PutUint32(packet[4:], r.timestamp)
+	binary.BigEndian.PutUint32(packet[8:], r.ssrc)
+	packet = append(packet, payload...)
+
+	now := r.clock.Now()
+	for i, line := range r.lines {
+		if line.delay == 0 {
+			_, err := r.conn.WriteToUDP(packet, r.Receivers[i].Addr)
+			if err != nil { return err }
+			continue
+		}
+		select {
+			case line.packets <- delayedPacket{ now.Add(line.delay),
+					packet }:
+			default:
+				// A receiver that far behind is no use anyway.
+		}
+	}
+	r.seq++
+	r.marker = false
+	r.timestamp += uint32(frames * r.clockRate() / r.rate)
+	r.pacer.wait(time.Duration(frames) * time.Second /
+		time.Duration(r.rate))
+	return nil
+}
+
+// delayed sends the packets for one receiver once they're due.
+func (r *RTPSink) delayed(addr *net.UDPAddr, packets chan delayedPacket) {
+	defer r.draining.Done()
+	for p := range packets {
+		if wait := p.due.Sub(r.clock.Now()); wait > 0 {
+			r.clock.Sleep(wait)
+		}
+		_, err := r.conn.WriteToUDP(p.data, addr)
+		if err != nil {
+			logger.Printf("RTP send to %s failed: %s\n", addr,
+				err.Error())
+		}
+	}
+}
+
+// Flush implements Sink, sending what's left as a short packet (padded
+// out for Opus, which only does whole frames).
+func (r *RTPSink) Flush() error {
+	r.Lock()
+	defer r.Unlock()
+	return r.flush()
+}
+
+// flush is Flush for callers already holding the lock.
+func (r *RTPSink) flush() error {
+	if len(r.pending) == 0 { return nil }
+	buf := r.pending
+	r.pending = nil
+	if r.Codec == CodecOpus {
+		buf = append(buf, make([]int32, r.frames*r.channels - len(buf))...)
+	}
+	return r.send(buf)
+}
+
+// Close implements Sink, waiting for the delay lines to send what they're
+// holding so the quicker receivers get the end too.
+func (r *RTPSink) Close() error {
+	err := r.Flush()
+	r.Lock()
+	for _, line := range r.lines {
+		if line.packets != nil { close(line.packets) }
+	}
+	r.lines = nil
+	r.Unlock()
+	r.draining.Wait()
+	closeErr := r.conn.Close()
+	if err != nil { return err }
+	return closeErr
+}
@@ -0,0 +1,233 @@
+// Test routines for the RTP sink.
+
+package woofie
+
+import (
+	"golang.org/x/net/ipv4"
+	"gopkg.in/hraban/opus.v2"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseReceivers checks receiver lists parse, latencies and all.
+func TestParseReceivers(t *testing.T) {
+	receivers, err := ParseReceivers("127.0.0.1:5004@40, 239.255.0.1:5006")
+	if err != nil { t.Fatal(err) }
+	if len(receivers) != 2 {
+		t.Fatal("Expected 2 receivers, got ", len(receivers))
+	}
+	if receivers[0].Addr.String() != "127.0.0.1:5004" ||
+			receivers[0].Latency != 40*time.Millisecond {
+		t.Error("Bad first receiver: ", receivers[0])
+	}
+	if receivers[1].Addr.String() != "239.255.0.1:5006" ||
+			receivers[1].Latency != 0 {
+		t.Error("Bad second receiver: ", receivers[1])
+	}
+	for _, spec := range []string{ "", "127.0.0.1", "127.0.0.1:5004@",
+			"127.0.0.1:5004@-5" } {
+		_, err := ParseReceivers(spec)
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+}
+
+// listenUDP opens a loopback receiver for a test.
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{ IP: net.IPv4(127, 0, 0, 1) })
+	if err != nil { t.Fatal(err) }
+	return conn
+}
+
+// readPacket reads an RTP packet, failing the test if none comes.
+func readPacket(t *testing.T, conn *net.UDPConn) []byte {
+	conn.SetReadDeadline(time.Now().Add(2*time.Second))
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil { t.Fatal(err) }
+	return buf[:n]
+}
+
+// TestRTPSink checks the audio goes out in L16 packets with the right
+// headers, that the format shows up in the SDP and that the leftovers go out
+// on a Flush.
+func TestRTPSink(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	conn := listenUDP(t)
+	defer conn.Close()
+	receivers := []Receiver{ { conn.LocalAddr().(*net.UDPAddr), 0 } }
+	sink, err := NewRTPSink(receivers, CodecL16, time.Minute,
+		WithRand(fixedRand(0)))
+	if err != nil { t.Fatal(err) }
+	defer sink.Close()
+
+	// 48kHz stereo fits 300 frames in a packet.
+	buf := make([]int32, 700*2)
+	for i := range buf {
+		buf[i] = int32(i) << 16
+	}
+	err = sink.Write(48000, 2, buf)
+	if err != nil { t.Fatal(err) }
+	if !strings.Contains(sink.SDP(), "a=rtpmap:96 L16/48000/2") {
+		t.Error("Bad SDP: ", sink.SDP())
+	}
+	err = sink.Flush()
+	if err != nil { t.Fatal(err) }
+
+	var first uint32
+	for i, frames := range []int{ 300, 300, 100 } {
+		packet := readPacket(t, conn)
+		if len(packet) != 12 + frames*4 {
+			t.Fatal("Packet ", i, " is ", len(packet), " bytes")
+		}
+		if packet[0] != 0x80 { t.Error("Bad RTP version: ", packet[0]) }
+		marker := packet[1] & 0x80 != 0
+		if packet[1] & 0x7f != 96 || marker != (i == 0) {
+			t.Error("Bad marker/payload type in packet ", i, ": ",
+				packet[1])
+		}
+		if seq := binary.BigEndian.Uint16(packet[2:]); seq != uint16(i) {
+			t.Error("Expected seq ", i, ", got ", seq)
+		}
+		ts := binary.BigEndian.Uint32(packet[4:])
+		if i == 0 { first = ts }
+		if ts - first != uint32(i*300) {
+			t.Error("Packet ", i, " is ", ts - first, " frames in")
+		}
+		if ssrc := binary.BigEndian.Uint32(packet[8:]); ssrc != 0 {
+			t.Error("Expected SSRC 0, got ", ssrc)
+		}
+		for j := 0; j < frames*2; j++ {
+			v := int(binary.BigEndian.Uint16(packet[12 + j*2:]))
+			if v != i*600 + j {
+				t.Fatal("Sample ", j, " of packet ", i, " is ", v)
+			}
+		}
+	}
+}
+
+// TestRTPOpus checks the audio goes out as whole 20ms Opus frames that
+// decode back to something like it, with the leftovers padded out to a frame
+// on a Flush.
+func TestRTPOpus(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	conn := listenUDP(t)
+	defer conn.Close()
+	receivers := []Receiver{ { conn.LocalAddr().(*net.UDPAddr), 0 } }
+	sink, err := NewRTPSink(receivers, CodecOpus, time.Minute)
+	if err != nil { t.Fatal(err) }
+	defer sink.Close()
+
+	// A 440Hz tone at half scale, a frame and a half of it.
+	buf := make([]int32, 1440*2)
+	for i := range buf {
+		buf[i] = int32(math.Sin(float64(i/2) * 2 * math.Pi * 440 / 48000) *
+			(1 << 30))
+	}
+	err = sink.Write(48000, 2, buf)
+	if err != nil { t.Fatal(err) }
+	if !strings.Contains(sink.SDP(), "a=rtpmap:97 opus/48000/2") {
+		t.Error("Bad SDP: ", sink.SDP())
+	}
+	err = sink.Flush()
+	if err != nil { t.Fatal(err) }
+
+	decoder, err := opus.NewDecoder(48000, 2)
+	if err != nil { t.Fatal(err) }
+	var first uint32
+	peak := 0
+	for i := 0; i < 2; i++ {
+		packet := readPacket(t, conn)
+		if packet[1] & 0x7f != 97 {
+			t.Error("Expected payload type 97, got ", packet[1] & 0x7f)
+		}
+		ts := binary.BigEndian.Uint32(packet[4:])
+		if i == 0 { first = ts }
+		if ts - first != uint32(i*960) {
+			t.Error("Packet ", i, " is ", ts - first, " frames in")
+		}
+		pcm := make([]int16, 5760*2)
+		frames, err := decoder.Decode(packet[12:], pcm)
+		if err != nil { t.Fatal(err) }
+		if frames != 960 {
+			t.Error("Expected 960 frames in packet ", i, ", got ", frames)
+		}
+		for _, v := range pcm[:frames*2] {
+			if int(v) > peak { peak = int(v) }
+		}
+	}
+	// Opus is lossy, but not that lossy.
+	if peak < 8000 || peak > 24000 {
+		t.Error("Expected a peak around 16384, got ", peak)
+	}
+}
+
+// TestRTPLatency checks a quick receiver gets its packets later than a slow
+// one, by the difference in their latencies.
+func TestRTPLatency(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	quick, slow := listenUDP(t), listenUDP(t)
+	defer quick.Close()
+	defer slow.Close()
+	receivers := []Receiver{
+		{ quick.LocalAddr().(*net.UDPAddr), 20*time.Millisecond },
+		{ slow.LocalAddr().(*net.UDPAddr), 120*time.Millisecond } }
+	sink, err := NewRTPSink(receivers, CodecL16, time.Minute)
+	if err != nil { t.Fatal(err) }
+	defer sink.Close()
+
+	start := time.Now()
+	err = sink.Write(48000, 2, make([]int32, 300*2))
+	if err != nil { t.Fatal(err) }
+	readPacket(t, slow)
+	slowTime := time.Since(start)
+	readPacket(t, quick)
+	quickTime := time.Since(start)
+	if quickTime - slowTime < 90*time.Millisecond {
+		t.Error("Expected the quick receiver 100ms behind, got ",
+			quickTime - slowTime)
+	}
+
+	// Closing straight after the last bark still gets it all to the
+	// quick receiver.
+	err = sink.Write(48000, 2, make([]int32, 700*2))
+	if err != nil { t.Fatal(err) }
+	err = sink.Close()
+	if err != nil { t.Fatal(err) }
+	for i := 0; i < 3; i++ {
+		readPacket(t, quick)
+	}
+}
+
+// TestRTPMulticast checks the hop limit gets set, and that a multicast
+// receiver gets the stream (where the loopback interface can do multicast).
+func TestRTPMulticast(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	group := &net.UDPAddr{ IP: net.IPv4(239, 255, 42, 42), Port: 0 }
+	lo, err := net.InterfaceByName("lo")
+	if err != nil { t.Skip("No loopback interface: ", err) }
+	conn, err := net.ListenMulticastUDP("udp4", lo, group)
+	if err != nil { t.Skip("No multicast on loopback: ", err) }
+	defer conn.Close()
+	group.Port = conn.LocalAddr().(*net.UDPAddr).Port
+	sink, err := NewRTPSink([]Receiver{ { group, 0 } }, CodecL16, time.Minute)
+	if err != nil { t.Fatal(err) }
+	defer sink.Close()
+	err = sink.Multicast(4, "lo")
+	if err != nil { t.Skip("Can't multicast on loopback: ", err) }
+	ttl, err := ipv4.NewPacketConn(sink.conn).MulticastTTL()
+	if err != nil { t.Fatal(err) }
+	if ttl != 4 { t.Error("Expected a TTL of 4, got ", ttl) }
+
+	err = sink.Write(48000, 2, make([]int32, 300*2))
+	if err != nil { t.Fatal(err) }
+	packet := readPacket(t, conn)
+	if len(packet) != 12 + 300*4 {
+		t.Error("Expected a full packet, got ", len(packet), " bytes")
+	}
+}
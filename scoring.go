@@ -0,0 +1,323 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the fatigue scorers, which decide from the bark log
+// whether the dog has been annoying enough lately to shut up for a while.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scorer is a fatigue-scoring strategy.  Scorers are stateless: everything
+// they know comes from the bark log, so they can be swapped at runtime.
+type Scorer interface {
+	// Score totals up the log (oldest first) as of now.
+	Score(log []time.Time, now time.Time) float64
+	// Allow reports whether a score still lets us bark.
+	Allow(score float64) bool
+	// Used is how much of the budget a score has used up, from 0 for a
+	// rested dog to 1 (or more) for one that's out of barks.
+	Used(score float64) float64
+	// Retention is how long a log entry can still affect the score.
+	Retention() time.Duration
+	// String gives the scorer back in --scoring syntax.
+	String() string
+}
+
+// DefaultHorizon and DefaultScore are the --horizon and --score defaults,
+// which specs are checked with when there's no Woofer to ask.
+const (
+	DefaultHorizon = 30
+	DefaultScore = 150
+)
+
+// specre splits a scoring spec into name and settings.
+var specre = regexp.MustCompile("^\\s*([a-z]+)\\s*(?::(.*))?$")
+
+// NewScorer builds a scorer from a spec of the form name:key=val,key=val
+// (e.g. "exponential:halflife=10,limit=100").  horizon and score are the
+// --horizon and --score values, used as defaults where they make sense.
+func NewScorer(spec string, horizon, score int) (Scorer, error) {
+	matches := specre.FindStringSubmatch(spec)
+	if matches == nil {
+		return nil, errors.New(fmt.Sprintf("Bad scoring spec: %s", spec))
+	}
+	settings, err := parseSettings(matches[2])
+	if err != nil { return nil, err }
+	switch matches[1] {
+		case "linear":
+			ret := LinearScorer{ float64(horizon), float64(score) }
+			err = settings.apply(map[string]*float64{
+				"horizon": &ret.Horizon, "limit": &ret.Limit })
+			if err != nil { return nil, err }
+			if ret.Horizon <= 0 {
+				return nil, errors.New("Horizon must be positive")
+			}
+			return &ret, nil
+		case "exponential", "exp":
+			ret := ExponentialScorer{ float64(horizon) / 2.0,
+				float64(horizon), float64(score) }
+			err = settings.apply(map[string]*float64{
+				"halflife": &ret.HalfLife,
+				"points": &ret.Points, "limit": &ret.Limit })
+			if err != nil { return nil, err }
+			if ret.HalfLife <= 0 || ret.Points <= 0 {
+				return nil, errors.New(
+					"Half-life and points must be positive")
+			}
+			return &ret, nil
+		case "bucket":
+			ret := BucketScorer{ 10, 6 }
+			err = settings.apply(map[string]*float64{
+				"capacity": &ret.Capacity, "rate": &ret.Rate })
+			if err != nil { return nil, err }
+			if ret.Capacity < 1 || ret.Rate <= 0 {
+				return nil, errors.New("Capacity must be at " +
+					"least 1 and rate positive")
+			}
+			return &ret, nil
+		case "window":
+			ret := WindowScorer{ float64(horizon), 10 }
+			err = settings.apply(map[string]*float64{
+				"window": &ret.Window, "max": &ret.Max })
+			if err != nil { return nil, err }
+			if ret.Window <= 0 {
+				return nil, errors.New("Window must be positive")
+			}
+			return &ret, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown scoring strategy: %s",
+		matches[1]))
+}
+
+// scorerSettings is the parsed key=val part of a scoring spec.
+type scorerSettings map[string]float64
+
+// parseSettings breaks down "key=val,key=val".
+func parseSettings(s string) (scorerSettings, error) {
+	regexpInit()
+	ret := make(scorerSettings)
+	if strings.TrimSpace(s) == "" { return ret, nil }
+	for _, setting := range sepre.Split(strings.TrimSpace(s), -1) {
+		parts := kvre.Split(setting, -1)
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("Bad setting: %s",
+				setting))
+		}
+		val, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Bad value: %s",
+				setting))
+		}
+		ret[parts[0]] = val
+	}
+	return ret, nil
+}
+
+// apply copies the settings into the given fields, complaining about any
+// setting that doesn't belong.
+func (s scorerSettings) apply(fields map[string]*float64) error {
+	for key, val := range s {
+		field, ok := fields[key]
+		if !ok {
+			return errors.New(fmt.Sprintf("Unknown setting: %s",
+				key))
+		}
+		*field = val
+	}
+	return nil
+}
+
+// minutes converts a float number of minutes to a Duration.
+func minutes(m float64) time.Duration {
+	return time.Duration(m * float64(time.Minute))
+}
+
+// LinearScorer is the original woofie scoring: each distinct minute with a
+// bark in it is worth Horizon - (minutes ago) points.
+type LinearScorer struct {
+	// Horizon is how many minutes to look back.
+	Horizon float64
+	// Limit is the score at which we shut up.
+	Limit float64
+}
+
+// Score implements Scorer.
+func (s *LinearScorer) Score(log []time.Time, now time.Time) float64 {
+	// Having a map here makes sure we don't count the same minute
+	// delta multiple times.
+	scoreMap := make(map[int]float64)
+	for _, t := range log {
+		delta := int(now.Sub(t).Minutes())
+		if float64(delta) < s.Horizon {
+			scoreMap[delta] = s.Horizon - float64(delta)
+		}
+	}
+	woofScore := 0.0
+	for _, score := range scoreMap {
+		woofScore += score
+	}
+	return woofScore
+}
+
+// Allow implements Scorer.
+func (s *LinearScorer) Allow(score float64) bool {
+	return score < s.Limit
+}
+
+// Used implements Scorer.
+func (s *LinearScorer) Used(score float64) float64 {
+	return score / s.Limit
+}
+
+// Retention implements Scorer.
+func (s *LinearScorer) Retention() time.Duration {
+	return minutes(s.Horizon)
+}
+
+// String implements Scorer.
+func (s *LinearScorer) String() string {
+	return fmt.Sprintf("linear:horizon=%g,limit=%g", s.Horizon, s.Limit)
+}
+
+// ExponentialScorer gives every bark Points points, halving every HalfLife
+// minutes.
+type ExponentialScorer struct {
+	// HalfLife is the number of minutes it takes a bark to lose half its
+	// points.
+	HalfLife float64
+	// Points is what a bark is worth right when it happens.
+	Points float64
+	// Limit is the score at which we shut up.
+	Limit float64
+}
+
+// Score implements Scorer.
+func (s *ExponentialScorer) Score(log []time.Time, now time.Time) float64 {
+	woofScore := 0.0
+	for _, t := range log {
+		age := now.Sub(t).Minutes()
+		if age < 0 { age = 0 }
+		woofScore += s.Points * math.Pow(0.5, age / s.HalfLife)
+	}
+	return woofScore
+}
+
+// Allow implements Scorer.
+func (s *ExponentialScorer) Allow(score float64) bool {
+	return score < s.Limit
+}
+
+// Used implements Scorer.
+func (s *ExponentialScorer) Used(score float64) float64 {
+	return score / s.Limit
+}
+
+// Retention implements Scorer.  After ten half-lives a bark is worth under
+// a thousandth of its points, which is close enough to nothing.
+func (s *ExponentialScorer) Retention() time.Duration {
+	return minutes(10 * s.HalfLife)
+}
+
+// String implements Scorer.
+func (s *ExponentialScorer) String() string {
+	return fmt.Sprintf("exponential:halflife=%g,points=%g,limit=%g",
+		s.HalfLife, s.Points, s.Limit)
+}
+
+// BucketScorer is a token bucket: it holds up to Capacity barks, refilling
+// at Rate barks per hour, and each bark takes one.  The score is the number
+// of tokens missing from the bucket.
+type BucketScorer struct {
+	// Capacity is the most barks we can save up.
+	Capacity float64
+	// Rate is the number of barks earned back per hour.
+	Rate float64
+}
+
+// Score implements Scorer by replaying the log into a full bucket.
+func (s *BucketScorer) Score(log []time.Time, now time.Time) float64 {
+	tokens := s.Capacity
+	var last time.Time
+	for i, t := range log {
+		if i != 0 {
+			tokens += t.Sub(last).Hours() * s.Rate
+			if tokens > s.Capacity { tokens = s.Capacity }
+		}
+		tokens--
+		last = t
+	}
+	if len(log) != 0 {
+		tokens += now.Sub(last).Hours() * s.Rate
+		if tokens > s.Capacity { tokens = s.Capacity }
+	}
+	return s.Capacity - tokens
+}
+
+// Allow implements Scorer: we need a whole token left to bark.
+func (s *BucketScorer) Allow(score float64) bool {
+	return s.Capacity - score >= 1
+}
+
+// Used implements Scorer: the share of the bucket that's empty.
+func (s *BucketScorer) Used(score float64) float64 {
+	return score / s.Capacity
+}
+
+// Retention implements Scorer.  A bucket refills completely in
+// Capacity/Rate hours, so anything older than twice that is ancient history.
+func (s *BucketScorer) Retention() time.Duration {
+	return time.Duration(2 * s.Capacity / s.Rate * float64(time.Hour))
+}
+
+// String implements Scorer.
+func (s *BucketScorer) String() string {
+	return fmt.Sprintf("bucket:capacity=%g,rate=%g", s.Capacity, s.Rate)
+}
+
+// WindowScorer allows at most Max barks in any Window minutes.
+type WindowScorer struct {
+	// Window is the length of the sliding window in minutes.
+	Window float64
+	// Max is the number of barks allowed in the window.
+	Max float64
+}
+
+// Score implements Scorer by counting the barks in the window.
+func (s *WindowScorer) Score(log []time.Time, now time.Time) float64 {
+	count := 0.0
+	for _, t := range log {
+		if now.Sub(t) < minutes(s.Window) { count++ }
+	}
+	return count
+}
+
+// Allow implements Scorer.
+func (s *WindowScorer) Allow(score float64) bool {
+	return score < s.Max
+}
+
+// Used implements Scorer.
+func (s *WindowScorer) Used(score float64) float64 {
+	return score / s.Max
+}
+
+// Retention implements Scorer.
+func (s *WindowScorer) Retention() time.Duration {
+	return minutes(s.Window)
+}
+
+// String implements Scorer.
+func (s *WindowScorer) String() string {
+	return fmt.Sprintf("window:window=%g,max=%g", s.Window, s.Max)
+}
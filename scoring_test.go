@@ -0,0 +1,93 @@
+// Test routines for the fatigue scorers.
+
+package woofie
+
+import (
+	"testing"
+	"time"
+)
+
+// barks builds a log with a bark every step, count times, ending at now.
+func barks(now time.Time, step time.Duration, count int) []time.Time {
+	ret := make([]time.Time, count)
+	for i := 0; i < count; i++ {
+		ret[i] = now.Add(-step * time.Duration(count-1-i))
+	}
+	return ret
+}
+
+// TestNewScorer checks spec parsing, defaults and bad specs.
+func TestNewScorer(t *testing.T) {
+	good := map[string]string{
+		"linear": "linear:horizon=30,limit=150",
+		"linear:limit=90": "linear:horizon=30,limit=90",
+		"exp:halflife=5": "exponential:halflife=5,points=30,limit=150",
+		"bucket: capacity = 4, rate=2": "bucket:capacity=4,rate=2",
+		"window:max=3": "window:window=30,max=3",
+	}
+	for spec, expected := range good {
+		scorer, err := NewScorer(spec, 30, 150)
+		if err != nil {
+			t.Error("Error parsing ", spec, ": ", err)
+		} else if scorer.String() != expected {
+			t.Error("Expected ", expected, ", got ", scorer.String())
+		}
+	}
+	bad := []string{ "", "bogus", "linear:nope=1", "window:max=x",
+		"exponential:halflife=0", "bucket:capacity=0.5" }
+	for _, spec := range bad {
+		_, err := NewScorer(spec, 30, 150)
+		if err == nil { t.Error("Expected error parsing ", spec) }
+	}
+}
+
+// TestScorers runs the same bark log past each scorer.
+func TestScorers(t *testing.T) {
+	now := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	// Six barks a minute apart, the last one just now.
+	log := barks(now, time.Minute, 6)
+
+	// Linear: 30+29+28+27+26+25 distinct minutes.
+	linear, _ := NewScorer("linear", 30, 150)
+	if score := linear.Score(log, now); score != 165 {
+		t.Error("Linear: expected 165, got ", score)
+	}
+	if linear.Allow(165) || !linear.Allow(149) {
+		t.Error("Linear: wrong limit")
+	}
+
+	// Exponential: the newest bark is worth all its points, and a bark
+	// one half-life ago is worth half.
+	exp, _ := NewScorer("exponential:halflife=5,points=10,limit=30",
+		30, 150)
+	single := []time.Time{ now.Add(-5*time.Minute) }
+	if score := exp.Score(single, now); score != 5 {
+		t.Error("Exponential: expected 5, got ", score)
+	}
+	if score := exp.Score(log, now); score < 43 || score > 44 {
+		t.Error("Exponential: expected ~43.6, got ", score)
+	}
+
+	// Bucket: four tokens refilling at 60/hour (one a minute), so a bark
+	// a minute never runs it dry but six in a burst do.
+	bucket, _ := NewScorer("bucket:capacity=4,rate=60", 30, 150)
+	if !bucket.Allow(bucket.Score(log, now)) {
+		t.Error("Bucket: ran dry on one bark a minute")
+	}
+	burst := barks(now, time.Second, 6)
+	if bucket.Allow(bucket.Score(burst, now)) {
+		t.Error("Bucket: allowed a burst of six")
+	}
+	if !bucket.Allow(bucket.Score(burst, now.Add(4*time.Minute))) {
+		t.Error("Bucket: didn't refill")
+	}
+
+	// Window: five barks allowed in three minutes; only three count.
+	window, _ := NewScorer("window:window=3,max=5", 30, 150)
+	if score := window.Score(log, now); score != 3 {
+		t.Error("Window: expected 3, got ", score)
+	}
+	if window.Allow(5) || !window.Allow(4) {
+		t.Error("Window: wrong max")
+	}
+}
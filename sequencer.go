@@ -0,0 +1,298 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the sequencer, which strings the barks of a cycle
+// together into one stream (back to back or crossfaded) with natural pauses
+// in between, so it sounds like one dog rather than a playlist.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// The sequencer modes.
+const (
+	// SequenceOff plays each bark on its own and waits out the pause.
+	SequenceOff = "off"
+	// SequenceGapless plays the barks and pauses as one stream.
+	SequenceGapless = "gapless"
+	// SequenceCrossfade does the same, but overlaps the barks.
+	SequenceCrossfade = "crossfade"
+)
+
+// The shapes the pauses between barks can be drawn from.
+const (
+	PausesUniform = "uniform"
+	PausesNormal = "normal"
+	PausesExponential = "exponential"
+)
+
+// Sequencer is how the barks of a cycle follow on from one another.
+type Sequencer struct {
+	// Mode is SequenceOff, SequenceGapless or SequenceCrossfade.
+	Mode string
+	// Crossfade is how much the barks overlap in SequenceCrossfade mode,
+	// less the pause (so a pause longer than it means no overlap).
+	Crossfade time.Duration
+	// Pauses is how the pauses between barks are drawn.
+	Pauses Pauses
+}
+
+// NewSequencer builds a sequencer from the --sequence mode, the --crossfade
+// length and the --pauses spec.
+func NewSequencer(mode string, crossfade time.Duration,
+		pauses string) (Sequencer, error) {
+	ret := Sequencer{ Mode: mode, Crossfade: crossfade }
+	switch mode {
+		case SequenceOff, SequenceGapless, SequenceCrossfade:
+		default:
+			return ret, errors.New(fmt.Sprintf("Unknown sequence " +
+				"mode: %s", mode))
+	}
+	if crossfade < 0 {
+		return ret, errors.New(fmt.Sprintf("Invalid crossfade: %s",
+			crossfade))
+	}
+	var err error
+	ret.Pauses, err = NewPauses(pauses)
+	return ret, err
+}
+
+// Pauses is a distribution for the pauses between barks, which are kept
+// between the profile's PauseMin and PauseMax.
+type Pauses struct {
+	// Shape is PausesUniform, PausesNormal or PausesExponential.
+	Shape string
+	// Mean is the average pause in secs (0 for halfway between the
+	// bounds).  Uniform pauses ignore it.
+	Mean float64
+	// SD is how far normal pauses stray from the mean, in secs (0 for a
+	// quarter of the bounds).
+	SD float64
+}
+
+// NewPauses parses a --pauses spec of the form shape:key=val,key=val (e.g.
+// "normal:mean=0.5,sd=0.1" or "exponential:mean=0.4").  An empty spec is
+// uniform.
+func NewPauses(spec string) (Pauses, error) {
+	ret := Pauses{ Shape: PausesUniform }
+	if spec == "" { return ret, nil }
+	matches := specre.FindStringSubmatch(spec)
+	if matches == nil {
+		return ret, errors.New(fmt.Sprintf("Bad pauses spec: %s", spec))
+	}
+	settings, err := parseSettings(matches[2])
+	if err != nil { return ret, err }
+	ret.Shape = matches[1]
+	switch ret.Shape {
+		case PausesUniform:
+			err = settings.apply(map[string]*float64{})
+		case PausesNormal:
+			err = settings.apply(map[string]*float64{
+				"mean": &ret.Mean, "sd": &ret.SD })
+		case PausesExponential:
+			err = settings.apply(map[string]*float64{
+				"mean": &ret.Mean })
+		default:
+			return ret, errors.New(fmt.Sprintf("Unknown pauses: %s",
+				ret.Shape))
+	}
+	if err != nil { return ret, err }
+	if ret.Mean < 0 || ret.SD < 0 {
+		return ret, errors.New(fmt.Sprintf("Bad pauses settings: %s",
+			spec))
+	}
+	return ret, nil
+}
+
+// Pick draws a pause between min and max (or just above min if max isn't
+// above it).
+func (p Pauses) Pick(r Rand, min, max time.Duration) time.Duration {
+	lo, hi := min.Seconds(), max.Seconds()
+	mean := p.Mean
+	if mean == 0 { mean = (lo + hi) / 2 }
+	var x float64
+	switch p.Shape {
+		case PausesNormal:
+			sd := p.SD
+			if sd == 0 { sd = (hi - lo) / 4 }
+			// Box-Muller, keeping the log away from zero.
+			u1 := 1 - float64(r.Float32())
+			u2 := float64(r.Float32())
+			x = mean + sd * math.Sqrt(-2*math.Log(u1)) *
+				math.Cos(2*math.Pi*u2)
+		case PausesExponential:
+			u := 1 - float64(r.Float32())
+			x = lo - math.Max(mean - lo, 0) * math.Log(u)
+		default:
+			if max <= min { return min }
+			spread := float32(max - min)
+			return min + time.Duration(r.Float32() * spread)
+	}
+	if hi > lo { x = math.Min(x, hi) }
+	x = math.Max(x, lo)
+	return time.Duration(x * float64(time.Second))
+}
+
+// bark is one rendered bark, ready for the splicer.
+type bark struct {
+	pcm []float64
+	rate, channels int
+	// pause is the gap to leave before it.
+	pause time.Duration
+	err error
+}
+
+// splicer joins rendered barks into one stream on a fader, holding back the
+// end of each so the next can be crossfaded into it.
+type splicer struct {
+	f *fader
+	rate, channels int
+	// crossfade is the most the barks overlap, in frames.
+	crossfade int
+	// tail is the held-back end of the stream so far.
+	tail []float64
+	// started is false until the first bark, which gets no pause.
+	started bool
+}
+
+// newSplicer starts a stream on f, overlapping barks by up to crossfade.
+func newSplicer(f *fader, crossfade time.Duration) *splicer {
+	return &splicer{ f: f, rate: f.rate, channels: f.channels,
+		crossfade: int(crossfade.Seconds() * float64(f.rate)) }
+}
+
+// add puts pause worth of silence less the crossfade on the end of the
+// stream, then pcm.  If that would start it before the end of the last one,
+// the two are crossfaded (keeping the power level) where they overlap.
+// Everything but the new tail gets played.
+func (s *splicer) add(pcm []float64, pause time.Duration) error {
+	ch := s.channels
+	frames := len(s.tail)/ch
+	start := frames + int(pause.Seconds() * float64(s.rate)) - s.crossfade
+	if !s.started { start = frames }
+	s.started = true
+	if start < 0 { start = 0 }
+	if start >= frames {
+		s.tail = append(s.tail, make([]float64, (start-frames)*ch)...)
+		s.tail = append(s.tail, pcm...)
+	} else {
+		overlap := frames - start
+		for i := 0; i < overlap; i++ {
+			x := (float64(i) + 0.5) / float64(overlap) * math.Pi / 2
+			for c := 0; c < ch; c++ {
+				j := (start+i)*ch + c
+				s.tail[j] *= math.Cos(x)
+				if i*ch+c < len(pcm) {
+					s.tail[j] += pcm[i*ch+c] * math.Sin(x)
+				}
+			}
+		}
+		if len(pcm) > overlap*ch {
+			s.tail = append(s.tail, pcm[overlap*ch:]...)
+		}
+	}
+	keep := s.crossfade*ch
+	if keep > len(s.tail) { keep = len(s.tail) }
+	n := len(s.tail) - keep
+	err := s.f.write(toPCM(s.tail[:n]))
+	s.tail = append([]float64{}, s.tail[n:]...)
+	return err
+}
+
+// finish plays what's left (unless err says not to) and flushes.
+func (s *splicer) finish(err error) error {
+	if err == nil { err = s.f.write(toPCM(s.tail)) }
+	return s.f.finish(err)
+}
+
+// sequence plays the bark cycle as one stream for as long as it lasts, each
+// bark being picked and rendered while the one before it plays.  It stops
+// early if quiet time starts.  gen is as for playing.  The stream has the
+// output to itself, pauses and all, so a PlaySound waits for the cycle to
+// finish rather than breaking it up.
+func (w *Woofer) sequence(ctx context.Context, gen int) error {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	go w.quietWatch(ctx, stop)
+	return w.playing(ctx, "bark cycle", gen, func(ctx context.Context, out Sink,
+			mixer *Mixer, fade time.Duration) error {
+		barks := make(chan bark)
+		rctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go w.render(rctx, mixer, barks)
+		w.Lock()
+		var crossfade time.Duration
+		if w.Sequencer.Mode == SequenceCrossfade {
+			crossfade = w.Sequencer.Crossfade
+		}
+		w.Unlock()
+		var s *splicer
+		var err error
+		for b := range barks {
+			err = b.err
+			if err != nil { break }
+			// The next bark is rendered ahead of time, so the
+			// cycle may have ended since.
+			w.Lock()
+			barking := w.WoofUntil.After(w.Clock.Now())
+			w.Unlock()
+			if !barking { break }
+			if s == nil {
+				s = newSplicer(newFader(ctx, out, b.rate,
+					b.channels, fade), crossfade)
+			}
+			err = s.add(b.pcm, b.pause)
+			if err != nil { break }
+		}
+		if s == nil {
+			if err == nil && ctx.Err() != nil { err = ErrInterrupted }
+			return err
+		}
+		return s.finish(err)
+	})
+}
+
+// render picks and renders barks for sequence until the cycle ends, sending
+// each on barks (and closing it when there are no more).  The barks all
+// come out in the same format: the Mixer's, or else the first one's.
+func (w *Woofer) render(ctx context.Context, mixer *Mixer,
+		barks chan<- bark) {
+	defer close(barks)
+	m := Mixer{}
+	if mixer != nil { m = *mixer }
+	for ctx.Err() == nil {
+		w.Lock()
+		now := w.Clock.Now()
+		barking := w.WoofUntil.After(now) &&
+			!w.WoofSchedule.InSchedules(now)
+		level := w.Escalation.Current(now)
+		sets := append([]string{ level.Sounds }, w.PreferredSounds...)
+		zone := w.Zones.Get(w.zone)
+		b := bark{ pause: w.pause() }
+		w.Unlock()
+		if !barking { return }
+		voices, err := w.voices(sets, level.Gain(), zone)
+		b.err = err
+		if err == nil {
+			if m.Rate == 0 { m.Rate = voices[0].Sound.metadata.SampleRate }
+			if m.Channels == 0 {
+				m.Channels = voices[0].Sound.metadata.NChannels
+			}
+			b.pcm, b.rate, b.channels, b.err = m.render(ctx, voices)
+		}
+		select {
+			case barks <- b:
+			case <-ctx.Done():
+				return
+		}
+		if b.err != nil { return }
+	}
+}
@@ -0,0 +1,232 @@
+// Test routines for the sequencer.
+
+package woofie
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNewPauses checks the spec parser.
+func TestNewPauses(t *testing.T) {
+	p, err := NewPauses("normal:mean=0.5, sd=0.1")
+	if err != nil { t.Fatal(err) }
+	if p != (Pauses{ PausesNormal, 0.5, 0.1 }) { t.Error("Wrong pauses: ", p) }
+	p, err = NewPauses("")
+	if err != nil { t.Fatal(err) }
+	if p.Shape != PausesUniform { t.Error("Expected uniform, got ", p) }
+	for _, spec := range []string{ "poisson", "uniform:mean=1",
+			"exponential:sd=1", "normal:mean=-1" } {
+		_, err = NewPauses(spec)
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+	_, err = NewSequencer("shuffle", 0, "")
+	if err == nil { t.Error("Expected an unknown mode to fail") }
+}
+
+// TestPausesPick checks each shape stays in bounds and averages out about
+// right.
+func TestPausesPick(t *testing.T) {
+	min, max := 200*time.Millisecond, 2*time.Second
+	tests := []struct {
+		pauses Pauses
+		mean float64
+	}{
+		{ Pauses{ Shape: PausesUniform }, 1.1 },
+		{ Pauses{ Shape: PausesNormal }, 1.1 },
+		{ Pauses{ PausesNormal, 0.5, 0.05 }, 0.5 },
+		{ Pauses{ PausesExponential, 0.6, 0 }, 0.6 },
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		total := 0.0
+		for i := 0; i < 10000; i++ {
+			pause := test.pauses.Pick(r, min, max)
+			if pause < min || pause > max {
+				t.Fatal("Pause out of bounds: ", test.pauses, pause)
+			}
+			total += pause.Seconds()
+		}
+		// Exponential pauses get cut off at max, which pulls the
+		// mean down a little.
+		if mean := total/10000; mean < test.mean*0.95 ||
+				mean > test.mean*1.05 {
+			t.Errorf("%v: expected a mean of %v, got %v", test.pauses,
+				test.mean, mean)
+		}
+	}
+	if (Pauses{ Shape: PausesUniform }).Pick(r, min, 0) != min {
+		t.Error("Expected no spread to mean the minimum")
+	}
+}
+
+// TestSplicer checks barks get spaced out by the pause, or overlapped and
+// crossfaded.
+func TestSplicer(t *testing.T) {
+	bark := make([]float64, 100)
+	for i := range bark {
+		bark[i] = 1 << 20
+	}
+	tests := []struct {
+		crossfade, pause time.Duration
+		frames, silent int
+	}{
+		{ 0, 0, 300, 0 },
+		{ 0, 50*time.Millisecond, 400, 100 },
+		{ 40*time.Millisecond, 0, 220, 0 },
+		{ 40*time.Millisecond, 60*time.Millisecond, 340, 40 },
+	}
+	for _, test := range tests {
+		sink := &recordSink{}
+		f := newFader(context.Background(), sink, 1000, 1, 0)
+		s := newSplicer(f, test.crossfade)
+		for i := 0; i < 3; i++ {
+			err := s.add(bark, test.pause)
+			if err != nil { t.Fatal(err) }
+		}
+		err := s.finish(nil)
+		if err != nil { t.Fatal(err) }
+		if len(sink.pcm) != test.frames || sink.flushes != 1 {
+			t.Errorf("%v/%v: expected %d frames and a flush, got %d " +
+				"and %d", test.crossfade, test.pause, test.frames,
+				len(sink.pcm), sink.flushes)
+			continue
+		}
+		silent := 0
+		for _, v := range sink.pcm {
+			if v == 0 { silent++ }
+			// Equal-power crossfades bulge a little in the middle.
+			if v < 0 || v > (1 << 20)*3/2 {
+				t.Fatalf("%v/%v: bad sample %d", test.crossfade,
+					test.pause, v)
+			}
+		}
+		if silent != test.silent {
+			t.Errorf("%v/%v: expected %d silent frames, got %d",
+				test.crossfade, test.pause, test.silent, silent)
+		}
+	}
+}
+
+// TestWooferSequence checks a gapless bark cycle plays barks and pauses
+// back to back until it runs out, in one stream.
+func TestWooferSequence(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := fastClock{ NewManualClock(start) }
+	sink := NewNullSink(clock)
+	rng := fixedRand(0)
+	sounds := &Sounds{ Samples: []*Sound{ cachedSound(1) }, Sink: sink,
+		rand: rng }
+	woofer := NewWoofer(sounds, &Schedules{}, log.New(ioutil.Discard, "", 0),
+		15, 30, 150, 5, WithClock(clock), WithRand(rng))
+	woofer.Sequencer.Mode = SequenceGapless
+	woofer.PauseMin = 500*time.Millisecond
+	woofer.PauseMax = woofer.PauseMin
+	woofer.WoofUntil = start.Add(3*time.Second)
+	err := woofer.sequence(context.Background(), 0)
+	if err != nil { t.Fatal(err) }
+	// Each bark goes out with the pause before it, and the last one
+	// starts before the cycle's up.
+	if sink.Played != 4*time.Second {
+		t.Error("Expected 4s played, got ", sink.Played)
+	}
+}
+
+// gateSink records what's played, taking as long about it as playing would,
+// and holds up the first write until it's let go.
+type gateSink struct {
+	recordSink
+	clock fastClock
+	started, release chan bool
+	once sync.Once
+	sync.Mutex
+}
+
+func (g *gateSink) Write(rate, channels int, buf []int32) error {
+	g.once.Do(func() {
+		close(g.started)
+		<-g.release
+	})
+	g.Lock()
+	err := g.recordSink.Write(rate, channels, buf)
+	g.Unlock()
+	g.clock.Sleep(time.Duration(len(buf)/channels) * time.Second /
+		time.Duration(rate))
+	return err
+}
+
+// played is a copy of everything played so far.
+func (g *gateSink) played() []int32 {
+	g.Lock()
+	defer g.Unlock()
+	return append([]int32{}, g.pcm...)
+}
+
+// TestSequencePlaySound checks a sound played by hand during a bark cycle
+// waits for the cycle to finish rather than cutting into it.
+func TestSequencePlaySound(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := fastClock{ NewManualClock(start) }
+	sink := &gateSink{ clock: clock, started: make(chan bool),
+		release: make(chan bool) }
+	rng := fixedRand(0)
+	bark := cachedSound(1)
+	bark.filepath = "/bark.flac"
+	// The manual one's negative, to tell them apart.
+	growl := &Sound{ filepath: "/growl.flac",
+		metadata: Info{ "flac", 1000, 1, 1000 }, cache: NewCache(1 << 20) }
+	pcm := make([]int32, 1000)
+	for i := range pcm {
+		pcm[i] = -1 << 30
+	}
+	growl.cache.put(growl, pcm)
+	sounds := &Sounds{ Samples: []*Sound{ bark }, Sink: sink, rand: rng }
+	woofer := NewWoofer(sounds, &Schedules{}, log.New(ioutil.Discard, "", 0),
+		15, 30, 150, 5, WithClock(clock), WithRand(rng))
+	woofer.Sequencer.Mode = SequenceGapless
+	woofer.PauseMin = 500*time.Millisecond
+	woofer.PauseMax = woofer.PauseMin
+	woofer.WoofUntil = start.Add(3*time.Second)
+	done := make(chan error)
+	go func() { done <- woofer.sequence(context.Background(), 0) }()
+	<-sink.started
+	sounds.Samples = append(sounds.Samples, growl)
+	_, err := woofer.PlaySound("growl.flac")
+	if err != nil { t.Fatal(err) }
+	close(sink.release)
+	err = <-done
+	if err != nil { t.Fatal(err) }
+
+	// The growl takes a moment to get going once the cycle's done.
+	timeout := time.After(5*time.Second)
+	for growls := 0; growls < 1000; {
+		select {
+			case <-timeout:
+				t.Fatal("The growl never played")
+			case <-time.After(time.Millisecond):
+		}
+		growls = 0
+		for _, v := range sink.played() {
+			if v < 0 { growls++ }
+		}
+	}
+	barks, growls := 0, 0
+	for i, v := range sink.played() {
+		if v > 0 {
+			if growls > 0 {
+				t.Fatal("Bark at sample ", i, " after the growl")
+			}
+			barks++
+		}
+		if v < 0 { growls++ }
+	}
+	if barks != 3000 || growls != 1000 {
+		t.Error("Expected 3000 bark and 1000 growl samples, got ",
+			barks, " and ", growls)
+	}
+}
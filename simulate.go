@@ -0,0 +1,409 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the offline simulator, which replays a trigger history
+// through the real Woofer logic on a manual clock so parameter changes can be
+// tried out before the neighbors hear them.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TriggerRecord is one on/off request from a trigger history.
+type TriggerRecord struct {
+	// Time is when the request came in.
+	Time time.Time
+	// On is true for on requests and false for off requests.
+	On bool
+	// Sensor is whoever sent it, if known.
+	Sensor Sensor
+}
+
+// The timestamp formats the CSV reader understands (besides Unix seconds).
+var csvTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	time.UnixDate,
+}
+
+// logre picks on/off requests out of woofie's own log lines (see
+// requestLine), e.g.
+//    2017/01/20 22:00:00 Received on request (sensor=front-door, zone=porch)
+var logre = regexp.MustCompile("(\\d{4}/\\d\\d/\\d\\d \\d\\d:\\d\\d:\\d\\d) " +
+	"Received (on|off) request" +
+	"(?: \\(sensor=([^,)]*)(?:, zone=([^)]*))?\\))?")
+
+// requestLine is what the triggers log for an on or off request from a
+// sensor, which is what ReadTriggerLog reads back.
+func requestLine(on bool, sensor Sensor) string {
+	ret := "Received off request"
+	if on { ret = "Received on request" }
+	if sensor.ID == "" && sensor.Zone == "" { return ret }
+	ret = fmt.Sprintf("%s (sensor=%s", ret, sensor.ID)
+	if sensor.Zone != "" {
+		ret = fmt.Sprintf("%s, zone=%s", ret, sensor.Zone)
+	}
+	return ret + ")"
+}
+
+// ReadTriggers reads a trigger history, working out by itself whether it's a
+// woofie log or a CSV file.
+func ReadTriggers(r io.Reader) ([]TriggerRecord, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil { return nil, err }
+	if logre.Match(buf) {
+		return ReadTriggerLog(bytes.NewReader(buf))
+	}
+	return ReadTriggerCSV(bytes.NewReader(buf))
+}
+
+// ReadTriggerLog picks the on/off requests out of a woofie log (stderr or
+// file style; syslog adds its own timestamps and isn't supported).  The log
+// timestamps are taken to be local time.
+func ReadTriggerLog(r io.Reader) ([]TriggerRecord, error) {
+	ret := make([]TriggerRecord, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		matches := logre.FindStringSubmatch(scanner.Text())
+		if matches == nil { continue }
+		t, err := time.ParseInLocation("2006/01/02 15:04:05",
+			matches[1], time.Local)
+		if err != nil { return nil, err }
+		ret = append(ret, TriggerRecord{ t, matches[2] == "on",
+			Sensor{ ID: matches[3], Zone: matches[4] } })
+	}
+	if err := scanner.Err(); err != nil { return nil, err }
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Time.Before(ret[j].Time)
+	})
+	return ret, nil
+}
+
+// ReadTriggerCSV reads a CSV history of the form time[,sensor[,on|off]].  The
+// time may be Unix seconds or any of the usual formats.  A header line is
+// skipped if present.
+func ReadTriggerCSV(r io.Reader) ([]TriggerRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	ret := make([]TriggerRecord, 0)
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF { break }
+		if err != nil { return nil, err }
+		if len(fields) == 0 || fields[0] == "" { continue }
+		t, err := parseTriggerTime(fields[0])
+		if err != nil {
+			if line == 1 { continue }
+			return nil, errors.New(fmt.Sprintf("Line %d: %s",
+				line, err.Error()))
+		}
+		rec := TriggerRecord{ Time: t, On: true }
+		if len(fields) > 1 { rec.Sensor.ID = fields[1] }
+		if len(fields) > 2 {
+			switch strings.ToLower(fields[2]) {
+				case "", "on":
+				case "off":
+					rec.On = false
+				default:
+					return nil, errors.New(fmt.Sprintf(
+						"Line %d: bad action %s",
+						line, fields[2]))
+			}
+		}
+		ret = append(ret, rec)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Time.Before(ret[j].Time)
+	})
+	return ret, nil
+}
+
+// parseTriggerTime tries each of the formats we know on a CSV timestamp.
+func parseTriggerTime(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, format := range csvTimeFormats {
+		t, err := time.ParseInLocation(format, s, time.Local)
+		if err == nil { return t, nil }
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("Bad time: %s", s))
+}
+
+// SimParams is one set of parameters to simulate.
+type SimParams struct {
+	// Name labels the set in the output.
+	Name string
+	// Parameters is the bark parameters.
+	Parameters Parameters
+	// Schedule is the quiet schedule (--schedule syntax; empty for none).
+	Schedule string
+	// ZoneBudget is how zone and global fatigue combine (empty for
+	// BudgetBoth).
+	ZoneBudget string
+	// Zones is the zone setup (nil for none) and ZoneScoring the default
+	// zone scoring spec (empty for --scoring's).
+	Zones Zones
+	ZoneScoring string
+	// Levels and Escalation are the escalation ladder and its settings
+	// (--levels and --escalation syntax; empty for the defaults).
+	Levels, Escalation string
+	// Profile is the personality to take on (empty for none), which
+	// replaces the scoring, factor and escalation as it does for real.
+	Profile string
+	// Profiles is where Profile is looked up (nil for the built-ins).
+	Profiles Profiles
+}
+
+// ParseSimParams applies a space-separated list of key=value overrides
+// (resolution, horizon, score, factor, scoring, schedule, zonebudget,
+// zonescoring, sensitivity, levels, escalation, profile, name) to a base
+// parameter set, e.g. "name=strict score=100 sensitivity=garage=0.5".
+func ParseSimParams(spec string, base SimParams) (SimParams, error) {
+	ret := base
+	for _, setting := range strings.Fields(spec) {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return ret, errors.New(fmt.Sprintf("Bad setting: %s",
+				setting))
+		}
+		var err error
+		switch parts[0] {
+			case "name":
+				ret.Name = parts[1]
+			case "schedule":
+				ret.Schedule = parts[1]
+			case "scoring":
+				ret.Parameters.Scoring = parts[1]
+			case "zonebudget":
+				ret.ZoneBudget = parts[1]
+			case "zonescoring":
+				ret.ZoneScoring = parts[1]
+			case "sensitivity":
+				// Leave the base's zones alone.
+				zones := Zones{}
+				for name, zone := range ret.Zones {
+					zones[name] = zone
+				}
+				err = zones.ParseSensitivities(parts[1])
+				ret.Zones = zones
+			case "levels":
+				ret.Levels = parts[1]
+			case "escalation":
+				ret.Escalation = parts[1]
+			case "profile":
+				ret.Profile = parts[1]
+			case "resolution":
+				ret.Parameters.Resolution, err =
+					strconv.Atoi(parts[1])
+			case "horizon":
+				ret.Parameters.Horizon, err =
+					strconv.Atoi(parts[1])
+			case "score":
+				ret.Parameters.Score, err =
+					strconv.Atoi(parts[1])
+			case "factor":
+				ret.Parameters.Factor, err =
+					strconv.Atoi(parts[1])
+			default:
+				return ret, errors.New(fmt.Sprintf(
+					"Unknown setting: %s", parts[0]))
+		}
+		if err != nil {
+			return ret, errors.New(fmt.Sprintf("Bad value: %s",
+				setting))
+		}
+	}
+	return ret, nil
+}
+
+// SimDecision is what the dog did about one trigger.
+type SimDecision struct {
+	// Authorized is true if the Woofer authorized a bark.
+	Authorized bool
+	// Quiet is true if the schedule kept the dog quiet at the time.
+	Quiet bool
+	// Score is the fatigue score that decided it.
+	Score int
+	// Level is the escalation level the trigger left the dog at.
+	Level string
+}
+
+// SimResult is the outcome of replaying a history with one parameter set.
+type SimResult struct {
+	// Params is the parameter set that was simulated.
+	Params SimParams
+	// Decisions lines up with the trigger history.
+	Decisions []SimDecision
+	// BarkTime is the total barking per day (keyed 2006-01-02).
+	BarkTime map[string]time.Duration
+}
+
+// Simulate replays a trigger history through a fresh Woofer with the given
+// parameters, on a manual clock and an RNG seeded with seed.  The Woofer is
+// set up the way woofie sets it up from the command line: parameters, then
+// the escalation ladder, then the profile.
+func Simulate(triggers []TriggerRecord, params SimParams,
+		seed int64) (*SimResult, error) {
+	schedule := &Schedules{}
+	if params.Schedule != "" {
+		var err error
+		schedule, err = NewSchedules(params.Schedule)
+		if err != nil { return nil, err }
+	}
+	ret := SimResult{ Params: params,
+		Decisions: make([]SimDecision, len(triggers)),
+		BarkTime: make(map[string]time.Duration) }
+	if len(triggers) == 0 { return &ret, nil }
+
+	// The Woofer logs through the package logger, which the simulator
+	// doesn't want to hear from.
+	clock := NewManualClock(triggers[0].Time)
+	woofer, err := simWoofer(params, schedule, clock, NewRand(seed))
+	if err != nil { return nil, err }
+
+	for i, trig := range triggers {
+		clock.Set(trig.Time)
+		decision := SimDecision{ Quiet: schedule.InSchedules(trig.Time) }
+		if trig.On {
+			decision.Authorized, decision.Score =
+				woofer.WoofOnFrom(trig.Sensor)
+		} else {
+			woofer.WoofOffFrom(trig.Sensor)
+		}
+		decision.Level = woofer.Status().Level
+		ret.Decisions[i] = decision
+
+		// The player barks from once it's reacted until WoofUntil (or
+		// the next trigger changes its mind), except during quiet
+		// times.
+		woofer.Lock()
+		start, until := woofer.WoofStart, woofer.WoofUntil
+		woofer.Unlock()
+		if start.Before(trig.Time) { start = trig.Time }
+		if i+1 < len(triggers) && triggers[i+1].Time.Before(until) {
+			until = triggers[i+1].Time
+		}
+		for t := start; t.Before(until); t = t.Add(time.Second) {
+			if !schedule.InSchedules(t) {
+				ret.BarkTime[t.Format("2006-01-02")] +=
+					time.Second
+			}
+		}
+	}
+	return &ret, nil
+}
+
+// simWoofer builds the Woofer for Simulate.
+func simWoofer(params SimParams, schedule *Schedules, clock Clock,
+		rng Rand) (*Woofer, error) {
+	p := params.Parameters
+	woofer := NewWoofer(&Sounds{ rand: rng }, schedule,
+		log.New(ioutil.Discard, "", 0), p.Resolution, p.Horizon,
+		p.Score, p.Factor, WithClock(clock), WithRand(rng))
+	err := woofer.SetParameters(p)
+	if err != nil { return nil, err }
+	budget := params.ZoneBudget
+	if budget == "" { budget = BudgetBoth }
+	zones := params.Zones
+	if zones == nil { zones = Zones{} }
+	err = woofer.SetZones(zones, budget, params.ZoneScoring)
+	if err != nil { return nil, err }
+	if params.Levels != "" || params.Escalation != "" {
+		levels := params.Levels
+		if levels == "" { levels = DefaultLevels }
+		woofer.Escalation, err = NewEscalation(levels,
+			params.Escalation)
+		if err != nil { return nil, err }
+	}
+	if params.Profiles != nil { woofer.Profiles = params.Profiles }
+	if params.Profile != "" {
+		err = woofer.SetProfile(params.Profile)
+		if err != nil { return nil, err }
+	}
+	return woofer, nil
+}
+
+// FormatSimulation writes a side-by-side timeline of the results (which must
+// all come from the same history) followed by the bark minutes per day.
+func FormatSimulation(w io.Writer, triggers []TriggerRecord,
+		results []*SimResult) {
+	fmt.Fprintf(w, "%-19s %-12s", "time", "sensor")
+	for _, result := range results {
+		fmt.Fprintf(w, " %-14s", result.Params.Name)
+	}
+	fmt.Fprintln(w)
+	for i, trig := range triggers {
+		sensor := trig.Sensor.ID
+		if sensor == "" { sensor = "-" }
+		fmt.Fprintf(w, "%-19s %-12s",
+			trig.Time.Format("2006-01-02 15:04:05"), sensor)
+		for _, result := range results {
+			d := result.Decisions[i]
+			var what string
+			switch {
+				case !trig.On:
+					what = "off"
+				case d.Authorized && d.Quiet:
+					what = fmt.Sprintf("quiet(%d)", d.Score)
+				case d.Authorized:
+					what = fmt.Sprintf("BARK(%d)", d.Score)
+				default:
+					what = fmt.Sprintf("tired(%d)", d.Score)
+			}
+			fmt.Fprintf(w, " %-14s", what)
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Gather up every day any of the results barked on.
+	days := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, result := range results {
+		for day := range result.BarkTime {
+			if !seen[day] { days = append(days, day) }
+			seen[day] = true
+		}
+	}
+	sort.Strings(days)
+	fmt.Fprintf(w, "\n%-32s", "bark minutes per day")
+	for _, result := range results {
+		fmt.Fprintf(w, " %-14s", result.Params.Name)
+	}
+	fmt.Fprintln(w)
+	totals := make([]time.Duration, len(results))
+	for _, day := range days {
+		fmt.Fprintf(w, "%-32s", day)
+		for i, result := range results {
+			fmt.Fprintf(w, " %-14.1f",
+				result.BarkTime[day].Minutes())
+			totals[i] += result.BarkTime[day]
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%-32s", "total")
+	for _, total := range totals {
+		fmt.Fprintf(w, " %-14.1f", total.Minutes())
+	}
+	fmt.Fprintln(w)
+}
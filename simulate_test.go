@@ -0,0 +1,216 @@
+// Test routines for the offline simulator.
+
+package woofie
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadTriggers feeds the reader a CSV history and a woofie log.
+func TestReadTriggers(t *testing.T) {
+	csv := "time,sensor,action\n" +
+		"2017-01-20 22:01:00,garage\n" +
+		"2017-01-20 22:00:00,front-door,on\n" +
+		"1484949720,front-door,off\n"
+	triggers, err := ReadTriggers(strings.NewReader(csv))
+	if err != nil { t.Fatal(err) }
+	if len(triggers) != 3 {
+		t.Fatal("Expected 3 triggers, got ", len(triggers))
+	}
+	if triggers[0].Sensor.ID != "front-door" || !triggers[0].On {
+		t.Error("Triggers not sorted: ", triggers)
+	}
+	if triggers[2].On || triggers[2].Time.Unix() != 1484949720 {
+		t.Error("Bad off trigger: ", triggers[2])
+	}
+
+	logs := "woofie: 2017/01/20 22:00:00 Packet from 10.0.0.5:1234\n" +
+		"woofie: 2017/01/20 22:00:00 Received on request\n" +
+		"woofie: 2017/01/20 22:00:00 Authorizing bark at score=0.0\n" +
+		"2017/01/20 22:00:30 Received off request (sensor=porch)\n"
+	triggers, err = ReadTriggers(strings.NewReader(logs))
+	if err != nil { t.Fatal(err) }
+	if len(triggers) != 2 || triggers[1].On ||
+			triggers[1].Sensor.ID != "porch" {
+		t.Error("Bad log triggers: ", triggers)
+	}
+
+	_, err = ReadTriggers(strings.NewReader("time\nyesterday\n"))
+	if err == nil { t.Error("Expected error on a bad time") }
+}
+
+// TestSimulate replays a trigger a minute for ten minutes against two
+// parameter sets.
+func TestSimulate(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.Local)
+	triggers := make([]TriggerRecord, 0)
+	for i := 0; i < 10; i++ {
+		triggers = append(triggers, TriggerRecord{
+			Time: start.Add(time.Duration(i)*time.Minute),
+			On: true })
+	}
+	base := SimParams{ Name: "current",
+		Parameters: Parameters{ 15, 30, 150, 0, "linear" } }
+	strict, err := ParseSimParams("name=strict scoring=window:max=2",
+		base)
+	if err != nil { t.Fatal(err) }
+	quiet, err := ParseSimParams("name=quiet schedule=5=22-23", base)
+	if err != nil { t.Fatal(err) }
+	_, err = ParseSimParams("volume=11", base)
+	if err == nil { t.Error("Expected error on an unknown setting") }
+
+	barks := map[string]int{ "current": 7, "strict": 2, "quiet": 7 }
+	minutes := map[string]float64{ "current": 1.75, "strict": 0.5,
+		"quiet": 0 }
+	results := make([]*SimResult, 0)
+	for _, params := range []SimParams{ base, strict, quiet } {
+		result, err := Simulate(triggers, params, 1)
+		if err != nil { t.Fatal(err) }
+		count := 0
+		for _, d := range result.Decisions {
+			if d.Authorized { count++ }
+		}
+		if count != barks[params.Name] {
+			t.Errorf("%s: expected %d barks, got %d", params.Name,
+				barks[params.Name], count)
+		}
+		got := result.BarkTime["2017-01-20"].Minutes()
+		if got != minutes[params.Name] {
+			t.Errorf("%s: expected %.2f bark minutes, got %.2f",
+				params.Name, minutes[params.Name], got)
+		}
+		results = append(results, result)
+	}
+
+	var buf bytes.Buffer
+	FormatSimulation(&buf, triggers, results)
+	if !strings.Contains(buf.String(), "tired(159)") ||
+			!strings.Contains(buf.String(), "quiet(0)") {
+		t.Error("Unexpected output:\n", buf.String())
+	}
+}
+
+// TestSimulateWoofer checks the simulator comes to the same decisions as a
+// Woofer set up the way woofie sets one up, profile and escalation included,
+// and that the levels' bark lengths and the profile's reaction delay count
+// towards the bark time.
+func TestSimulateWoofer(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.Local)
+	triggers := make([]TriggerRecord, 0)
+	for i := 0; i < 12; i++ {
+		triggers = append(triggers, TriggerRecord{
+			Time: start.Add(time.Duration(i)*15*time.Second),
+			On: true, Sensor: Sensor{ ID: "porch" } })
+	}
+	params := SimParams{ Name: "lazy", Profile: "lazy",
+		Parameters: Parameters{ 15, 30, 150, 0, "linear" } }
+	result, err := Simulate(triggers, params, 1)
+	if err != nil { t.Fatal(err) }
+
+	clock := NewManualClock(start)
+	rng := NewRand(1)
+	woofer := NewWoofer(&Sounds{ rand: rng }, &Schedules{},
+		log.New(ioutil.Discard, "", 0), 15, 30, 150, 0,
+		WithClock(clock), WithRand(rng))
+	woofer.Escalation, err = NewEscalation(DefaultLevels, "")
+	if err != nil { t.Fatal(err) }
+	err = woofer.SetProfile("lazy")
+	if err != nil { t.Fatal(err) }
+	levels := make(map[string]bool)
+	for i, trig := range triggers {
+		clock.Set(trig.Time)
+		got, score := woofer.WoofOnFrom(trig.Sensor)
+		want := SimDecision{ got, false, score, woofer.Status().Level }
+		if result.Decisions[i] != want {
+			t.Errorf("Trigger %d: simulated %v, the Woofer did %v", i,
+				result.Decisions[i], want)
+		}
+		levels[want.Level] = true
+	}
+	if !levels["growl"] || !levels["bark"] || len(levels) != 2 {
+		t.Error("Expected the lazy dog's levels, got ", levels)
+	}
+
+	// A 10s growl then a 40s bark, each once the dog's had 3s to react
+	// rather than from the trigger.
+	params = SimParams{ Name: "levels", Levels: "growl:::10,bark:::40",
+		Profile: "lazy", Profiles: Profiles{ "lazy": Profile{
+			Name: "lazy", Scoring: "linear", Delay: 3,
+			Levels: "growl:::10,bark:::40",
+			Escalation: "window=60,decay=300" } },
+		Parameters: Parameters{ 15, 30, 150, 0, "linear" } }
+	result, err = Simulate([]TriggerRecord{ triggers[0], triggers[2] },
+		params, 1)
+	if err != nil { t.Fatal(err) }
+	if got := result.BarkTime["2017-01-20"]; got != 50*time.Second {
+		t.Error("Expected 50s of barking, got ", got)
+	}
+	if result.Decisions[1].Level != "bark" {
+		t.Error("Expected to be barking by the second trigger, got ",
+			result.Decisions[1].Level)
+	}
+}
+
+// TestSimulateZones checks the zone setup gets simulated too: a zone with
+// zero sensitivity stays quiet, and a --set can change sensitivities
+// without touching the base's.
+func TestSimulateZones(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.Local)
+	triggers := make([]TriggerRecord, 0)
+	for i := 0; i < 4; i++ {
+		triggers = append(triggers, TriggerRecord{
+			Time: start.Add(time.Duration(i)*time.Minute), On: true,
+			Sensor: Sensor{ ID: []string{ "porch", "garage" }[i%2] } })
+	}
+	zones := Zones{}
+	err := zones.ParseSensitivities("porch=0")
+	if err != nil { t.Fatal(err) }
+	base := SimParams{ Name: "current", Zones: zones,
+		Parameters: Parameters{ 15, 30, 150, 0, "linear" } }
+	loud, err := ParseSimParams("name=loud sensitivity=porch=1", base)
+	if err != nil { t.Fatal(err) }
+	if base.Zones.Get("porch").Sensitivity != 0 {
+		t.Error("Expected the base's zones left alone")
+	}
+	for _, params := range []SimParams{ base, loud } {
+		result, err := Simulate(triggers, params, 1)
+		if err != nil { t.Fatal(err) }
+		for i, d := range result.Decisions {
+			quiet := params.Name == "current" && i%2 == 0
+			if d.Authorized == quiet {
+				t.Errorf("%s: trigger %d authorized=%v", params.Name,
+					i, d.Authorized)
+			}
+		}
+	}
+}
+
+// TestRequestLine checks what the triggers log comes back out of the log as
+// the same trigger, sensor and zone and all.
+func TestRequestLine(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "woofie: ", log.LstdFlags)
+	sensors := []Sensor{ {}, { ID: "front-door" }, { Zone: "porch" },
+		{ ID: "garage-pir", Zone: "garage" } }
+	for i, sensor := range sensors {
+		logger.Println(requestLine(i%2 == 0, sensor))
+	}
+	triggers, err := ReadTriggers(&buf)
+	if err != nil { t.Fatal(err) }
+	if len(triggers) != len(sensors) {
+		t.Fatal("Expected ", len(sensors), " triggers, got ",
+			len(triggers))
+	}
+	for i, trig := range triggers {
+		if trig.On != (i%2 == 0) || trig.Sensor.ID != sensors[i].ID ||
+				trig.Sensor.Zone != sensors[i].Zone {
+			t.Errorf("Trigger %d: expected %v, got %v", i, sensors[i],
+				trig)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the audio sinks: somewhere for the barks to go other
+// than the sound card, for testing, recording or piping to other players.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink is somewhere to play audio.
+type Sink interface {
+	// Write plays interleaved samples in the given format, blocking as
+	// long as the sink needs to keep up with real time.
+	Write(rate, channels int, buf []int32) error
+	// Flush plays anything still queued up.
+	Flush() error
+	// Close flushes and shuts the sink down.
+	Close() error
+}
+
+// DefaultSink is where Sound.Play and friends play to.
+var DefaultSink Sink = NewPortaudioSink(1024, 0)
+
+// WithSink plays sounds on the given sink instead of the DefaultSink.
+func WithSink(s Sink) Option {
+	return func(o *options) { o.sink = s }
+}
+
+// NewSink builds a sink from a --sink spec:
+//    portaudio      the sound card (with the given buffer and latency)
+//    null           nowhere, but taking as long as playing would
+//    wav:PATH       record everything into a WAV file
+//    exec:COMMAND   pipe raw 16-bit little-endian PCM into a command, where
+//                   {rate} and {channels} in the command get filled in
+//                   (e.g. "exec:aplay -q -t raw -f S16_LE -r {rate} -c
+//                   {channels}")
+//    rtp:ADDRS      stream L16 over RTP to a list of receivers, unicast or
+//                   multicast, each with its latency in ms after an @ (e.g.
+//                   "rtp:10.0.0.5:5004@40,239.255.0.1:5004")
+//    rtp-opus:ADDRS the same, but Opus-encoded
+//    http:ADDR      serve a stream over HTTP (e.g. "http::8000")
+// The network sinks all go out as 48kHz stereo, latency ahead of real time
+// (200ms if it's 0).
+func NewSink(spec string, framesPerBuffer int,
+		latency time.Duration) (Sink, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	switch parts[0] {
+		case "portaudio":
+			return NewPortaudioSink(framesPerBuffer, latency), nil
+		case "null":
+			return NewNullSink(realClock{}), nil
+		case "wav":
+			if len(parts) != 2 || parts[1] == "" { break }
+			return NewWavSink(parts[1])
+		case "exec":
+			if len(parts) != 2 || parts[1] == "" { break }
+			return NewCommandSink(parts[1]), nil
+		case "rtp", "rtp-opus":
+			if len(parts) != 2 || parts[1] == "" { break }
+			receivers, err := ParseReceivers(parts[1])
+			if err != nil { return nil, err }
+			codec := CodecL16
+			if parts[0] == "rtp-opus" { codec = CodecOpus }
+			if latency == 0 { latency = netLatency }
+			rtp, err := NewRTPSink(receivers, codec, latency)
+			if err != nil { return nil, err }
+			return NewConvertSink(rtp, netRate, 2, QualityCubic)
+		case "http":
+			if len(parts) != 2 || parts[1] == "" { break }
+			if latency == 0 { latency = netLatency }
+			stream, err := NewStreamSink(parts[1], netRate, 2, latency)
+			if err != nil { return nil, err }
+			return NewConvertSink(stream, netRate, 2, QualityCubic)
+	}
+	return nil, errors.New(fmt.Sprintf("Bad sink: %s", spec))
+}
+
+// pcm16 converts samples into 16-bit little-endian PCM.
+func pcm16(buf []int32) []byte {
+	ret := make([]byte, len(buf)*2)
+	for i, v := range buf {
+		binary.LittleEndian.PutUint16(ret[i*2:], uint16(v >> 16))
+	}
+	return ret
+}
+
+// NullSink throws the audio away, but takes as long about it as playing it
+// would, so everything upstream behaves as it would with a sound card.
+type NullSink struct {
+	// Clock is what it waits on.
+	Clock Clock
+	// Played is how much audio it's been given.
+	Played time.Duration
+	// pending is what's been played since the last Flush.
+	pending time.Duration
+	sync.Mutex
+}
+
+// NewNullSink makes a null sink that waits on clock.
+func NewNullSink(clock Clock) *NullSink {
+	return &NullSink{ Clock: clock }
+}
+
+// Write implements Sink.
+func (n *NullSink) Write(rate, channels int, buf []int32) error {
+	d := time.Duration(len(buf)/channels) * time.Second /
+		time.Duration(rate)
+	n.Lock()
+	n.Played += d
+	n.pending += d
+	n.Unlock()
+	n.Clock.Sleep(d)
+	return nil
+}
+
+// Flush implements Sink, logging what was played.
+func (n *NullSink) Flush() error {
+	n.Lock()
+	pending := n.pending
+	n.pending = 0
+	n.Unlock()
+	if pending > 0 { logger.Printf("Null sink played %s\n", pending) }
+	return nil
+}
+
+// Close implements Sink.
+func (n *NullSink) Close() error {
+	return n.Flush()
+}
+
+// WavSink records everything played into a 16-bit WAV file.  The file takes
+// the format of the first thing played.
+type WavSink struct {
+	file *os.File
+	rate, channels int
+	// size is the number of bytes of audio written.
+	size int64
+	sync.Mutex
+}
+
+// NewWavSink creates (or truncates) the WAV file at path.
+func NewWavSink(path string) (*WavSink, error) {
+	f, err := os.Create(path)
+	if err != nil { return nil, err }
+	return &WavSink{ file: f }, nil
+}
+
+// wavHeader is a 16-bit PCM WAV header for size bytes of audio.
+func wavHeader(rate, channels int, size int64) []byte {
+	hdr := make([]byte, 44)
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(36 + size))
+	copy(hdr[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:], 16)
+	binary.LittleEndian.PutUint16(hdr[20:], 1)
+	binary.LittleEndian.PutUint16(hdr[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(hdr[24:], uint32(rate))
+	binary.LittleEndian.PutUint32(hdr[28:], uint32(rate*channels*2))
+	binary.LittleEndian.PutUint16(hdr[32:], uint16(channels*2))
+	binary.LittleEndian.PutUint16(hdr[34:], 16)
+	copy(hdr[36:], "data")
+	binary.LittleEndian.PutUint32(hdr[40:], uint32(size))
+	return hdr
+}
+
+// header writes the WAV header for the audio so far.  The caller must hold
+// the lock.
+func (w *WavSink) header() error {
+	_, err := w.file.WriteAt(wavHeader(w.rate, w.channels, w.size), 0)
+	return err
+}
+
+// Write implements Sink.
+func (w *WavSink) Write(rate, channels int, buf []int32) error {
+	w.Lock()
+	defer w.Unlock()
+	if w.rate == 0 {
+		w.rate, w.channels = rate, channels
+		err := w.header()
+		if err != nil { return err }
+	}
+	if rate != w.rate || channels != w.channels {
+		return errors.New(fmt.Sprintf("WAV file is %d-channel %dHz, " +
+			"can't add %d-channel %dHz", w.channels, w.rate,
+			channels, rate))
+	}
+	n, err := w.file.WriteAt(pcm16(buf), 44 + w.size)
+	w.size += int64(n)
+	return err
+}
+
+// Flush implements Sink, bringing the header up to date so the file is good
+// to play as it stands.
+func (w *WavSink) Flush() error {
+	w.Lock()
+	defer w.Unlock()
+	if w.rate == 0 { return nil }
+	return w.header()
+}
+
+// Close implements Sink.
+func (w *WavSink) Close() error {
+	err := w.Flush()
+	closeErr := w.file.Close()
+	if err != nil { return err }
+	return closeErr
+}
+
+// CommandSink pipes the audio into a command, which is started on the first
+// write and kept running for as long as the format stays the same.
+type CommandSink struct {
+	// Command is the command line, with {rate} and {channels} to be
+	// filled in.
+	Command string
+	rate, channels int
+	cmd *exec.Cmd
+	stdin io.WriteCloser
+	sync.Mutex
+}
+
+// NewCommandSink makes a sink that pipes into command.
+func NewCommandSink(command string) *CommandSink {
+	return &CommandSink{ Command: command }
+}
+
+// start runs the command for a format.  The caller must hold the lock.
+func (c *CommandSink) start(rate, channels int) error {
+	line := strings.NewReplacer("{rate}", strconv.Itoa(rate),
+		"{channels}", strconv.Itoa(channels)).Replace(c.Command)
+	args := strings.Fields(line)
+	if len(args) == 0 { return errors.New("Empty sink command") }
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil { return err }
+	err = cmd.Start()
+	if err != nil { return err }
+	logger.Printf("Started sink command: %s\n", line)
+	c.cmd, c.stdin, c.rate, c.channels = cmd, stdin, rate, channels
+	return nil
+}
+
+// stop closes the command's input and waits for it to finish.  The caller
+// must hold the lock.
+func (c *CommandSink) stop() error {
+	if c.cmd == nil { return nil }
+	c.stdin.Close()
+	err := c.cmd.Wait()
+	c.cmd = nil
+	return err
+}
+
+// Write implements Sink.  If the command has died, it's started again.
+func (c *CommandSink) Write(rate, channels int, buf []int32) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.cmd != nil && (c.rate != rate || c.channels != channels) {
+		err := c.stop()
+		if err != nil { return err }
+	}
+	if c.cmd == nil {
+		err := c.start(rate, channels)
+		if err != nil { return err }
+	}
+	_, err := c.stdin.Write(pcm16(buf))
+	if err != nil {
+		logger.Printf("Sink command failed: %s\n", err.Error())
+		c.stop()
+	}
+	return err
+}
+
+// Flush implements Sink.  The command gets everything as it's written, so
+// there's nothing to do.
+func (c *CommandSink) Flush() error {
+	return nil
+}
+
+// Close implements Sink.
+func (c *CommandSink) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.stop()
+}
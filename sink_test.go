@@ -0,0 +1,159 @@
+// Test routines for the audio sinks.
+
+package woofie
+
+import (
+	"github.com/gordonklaus/portaudio"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fastClock is a ManualClock that sleeps by moving itself forward.
+type fastClock struct {
+	*ManualClock
+}
+
+func (c fastClock) Sleep(d time.Duration) { c.Advance(d) }
+
+// cachedSound makes a sound that only exists in the cache: secs of a
+// constant tone at 1kHz mono.
+func cachedSound(secs int) *Sound {
+	sound := &Sound{ filepath: "/nonexistent.flac",
+		metadata: Info{ "flac", 1000, 1, int64(secs*1000) } }
+	sound.cache = NewCache(1 << 20)
+	pcm := make([]int32, secs*1000)
+	for i := range pcm {
+		pcm[i] = 1 << 30
+	}
+	sound.cache.put(sound, pcm)
+	return sound
+}
+
+// TestNullSink checks that playback through the null sink takes as long as
+// the sample, or as long as the fade when it's interrupted.
+func TestNullSink(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := fastClock{ NewManualClock(start) }
+	sink := NewNullSink(clock)
+	logger = log.New(ioutil.Discard, "", 0)
+	sound := cachedSound(2)
+	err := sound.PlayContext(context.Background(), sink, 1.0, 0)
+	if err != nil { t.Fatal(err) }
+	if sink.Played != 2*time.Second {
+		t.Error("Expected 2s played, got ", sink.Played)
+	}
+	if clock.Now().Sub(start) != 2*time.Second {
+		t.Error("Expected playing to take 2s, took ",
+			clock.Now().Sub(start))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = sound.PlayContext(ctx, sink, 1.0, 100*time.Millisecond)
+	if err != ErrInterrupted {
+		t.Error("Expected an interruption, got ", err)
+	}
+	if sink.Played != 2100*time.Millisecond {
+		t.Error("Expected just the fade to play, got ", sink.Played)
+	}
+}
+
+// TestWavSink checks the WAV file's header and size.
+func TestWavSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "barks.wav")
+	sink, err := NewSink("wav:" + path, 0, 0)
+	if err != nil { t.Fatal(err) }
+	err = sink.Write(8000, 2, make([]int32, 1000))
+	if err != nil { t.Fatal(err) }
+	err = sink.Write(44100, 1, make([]int32, 10))
+	if err == nil { t.Error("Expected an error changing formats") }
+	err = sink.Close()
+	if err != nil { t.Fatal(err) }
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil { t.Fatal(err) }
+	if len(buf) != 44 + 2000 {
+		t.Fatal("Expected 2044 bytes, got ", len(buf))
+	}
+	if string(buf[0:4]) != "RIFF" || string(buf[8:16]) != "WAVEfmt " {
+		t.Error("Bad WAV header")
+	}
+	if binary.LittleEndian.Uint16(buf[22:]) != 2 ||
+			binary.LittleEndian.Uint32(buf[24:]) != 8000 ||
+			binary.LittleEndian.Uint32(buf[40:]) != 2000 {
+		t.Error("Wrong format or size in WAV header")
+	}
+}
+
+// TestCommandSink pipes audio through tee into a file.
+func TestCommandSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	sink, err := NewSink("exec:tee " +
+		filepath.Join(dir, "out-{rate}-{channels}.raw"), 0, 0)
+	if err != nil { t.Fatal(err) }
+	logger = log.New(ioutil.Discard, "", 0)
+	err = sink.Write(22050, 1, make([]int32, 500))
+	if err != nil { t.Fatal(err) }
+	err = sink.Close()
+	if err != nil { t.Fatal(err) }
+	info, err := os.Stat(filepath.Join(dir, "out-22050-1.raw"))
+	if err != nil { t.Fatal(err) }
+	if info.Size() != 1000 {
+		t.Error("Expected 1000 bytes, got ", info.Size())
+	}
+}
+
+// TestNewSink checks sink spec parsing.
+func TestNewSink(t *testing.T) {
+	for _, spec := range []string{ "portaudio", "null" } {
+		_, err := NewSink(spec, 1024, 0)
+		if err != nil { t.Error(spec, ": ", err) }
+	}
+	for _, spec := range []string{ "", "wav", "exec:", "pulse", "rtp:",
+			"rtp:nohost", "rtp-opus:127.0.0.1:5004@soon", "http:" } {
+		_, err := NewSink(spec, 1024, 0)
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+}
+
+// TestMatchDevice checks output devices are found by index or name, and
+// input-only ones are skipped.
+func TestMatchDevice(t *testing.T) {
+	devices := []*portaudio.DeviceInfo{
+		{ Index: 0, Name: "HDA Intel PCH: HDMI 0", MaxOutputChannels: 8 },
+		{ Index: 1, Name: "USB Audio Device: Mic", MaxInputChannels: 1 },
+		{ Index: 2, Name: "USB Audio Device: Speaker",
+			MaxOutputChannels: 2 },
+	}
+	tests := []struct {
+		spec string
+		index int
+	}{
+		{ "0", 0 },
+		{ "2", 2 },
+		{ "hdmi", 0 },
+		{ "USB", 2 },
+		{ "1", -1 },
+		{ "bluetooth", -1 },
+	}
+	for _, test := range tests {
+		dev, err := matchDevice(devices, test.spec)
+		if test.index < 0 {
+			if err == nil { t.Error("Expected an error for ", test.spec) }
+		} else if err != nil || dev.Index != test.index {
+			t.Errorf("%s: expected device %d, got %v (%v)", test.spec,
+				test.index, dev, err)
+		}
+	}
+}
@@ -1,113 +1,407 @@
 // Network-triggered randomized sound player, simulating how a dog would bark at
 // a door.
 
-// This file implements the FLAC directory scanner and sound player.
+// This file implements the sample directory scanner and sound player.
 
 // (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
 
 package woofie
 
 import (
-	"github.com/gordonklaus/portaudio"
-	"github.com/mewkiz/flac"
-	"github.com/mewkiz/flac/meta"
-	"github.com/wjblack/goflacook"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"math/rand"
-	"regexp"
+	"math"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
-// Sound represents a single FLAC sample and metadata.  This does not include
-// the sample data until played (just the meta).
+// ErrInterrupted is what playback returns when it was cut short.
+var ErrInterrupted = errors.New("Playback interrupted")
+
+// chunkFrames is how many frames get written at a time, which is how often
+// playback checks whether it's been interrupted (about 20ms at 48kHz).
+const chunkFrames = 1024
+
+// Sound represents a single sample (FLAC, WAV, Ogg Vorbis, Opus or MP3) and
+// its metadata.  This does not include the sample data until played (just the
+// meta).
 type Sound struct {
 	filepath string
-	metadata meta.StreamInfo
+	metadata Info
+	// format is what kind of file it is.
+	format *format
+	// category is the sound set (woofdir subdirectory) it came from.
+	category string
+	// cache is where its decoded samples are kept (nil to not keep them).
+	cache *Cache
+	// sink is where Play plays it (nil for the DefaultSink).
+	sink Sink
+	// loudness is how loud it is, if it's been analyzed.
+	loudness Loudness
+	// normalizer evens out its loudness (nil to play it as recorded).
+	normalizer *Normalizer
+	// trim is the part of it that isn't silence (zero to play it all).
+	trim Trim
 }
 
-// NewSample loads the metadata from a filename.
+// NewSample loads the metadata from a filename, working out the format from
+// the file's contents rather than its name.
 func NewSample(filepath string) (*Sound, error) {
-	stream, err := flac.ParseFile(filepath)
+	f, err := detectFormat(filepath)
+	if err != nil { return nil, err }
+	if f == nil {
+		return nil, errors.New(fmt.Sprintf("Unknown format: %s",
+			filepath))
+	}
+	dec, err := openSample(filepath, f)
 	if err != nil { return nil, err }
-	stream.Close()
-	return &Sound{filepath, *stream.Info}, nil
+	dec.Close()
+	return &Sound{ filepath: filepath, metadata: dec.Info(), format: f },
+		nil
+}
+
+// Name is the sample's file name without the directory, prefixed with its
+// sound set if it has one (e.g. "growl/grr.flac").
+func (s *Sound) Name() string {
+	if s.category != "" {
+		return fmt.Sprintf("%s/%s", s.category,
+			filepath.Base(s.filepath))
+	}
+	return filepath.Base(s.filepath)
+}
+
+// Category is the sound set the sample belongs to ("" for the top level).
+func (s *Sound) Category() string {
+	return s.category
+}
+
+// Info describes the sample.
+func (s *Sound) Info() Info {
+	return s.metadata
 }
 
 // String dumps info about the sample to a string.
 func (s *Sound) String() string {
-	total := float32(s.metadata.NSamples) / float32(s.metadata.SampleRate)
-	return fmt.Sprintf("%d %d-bit samples/sec, %0.2f secs",
-		s.metadata.NSamples, s.metadata.SampleRate, total)
+	ret := fmt.Sprintf("%s, %d Hz, %d channels, %d frames, %0.2f secs",
+		s.metadata.Format, s.metadata.SampleRate, s.metadata.NChannels,
+		s.metadata.NSamples, s.metadata.Duration())
+	if s.normalizer != nil {
+		ret += fmt.Sprintf(", %0.1f dBFS RMS, %0.1f dBFS peak",
+			s.loudness.RMS, s.loudness.Peak)
+	}
+	if trimmed := s.Trimmed(); trimmed > 0 {
+		ret += fmt.Sprintf(", %0.2f secs of silence trimmed",
+			trimmed.Seconds())
+	}
+	return ret
 }
 
-// Play fires up pulseaudio and plays a FLAC sample based on goflacook.
+// Loudness is how loud the sample is (zero unless it's been analyzed).
+func (s *Sound) Loudness() Loudness {
+	return s.loudness
+}
+
+// Play plays the sample on the sink of the Sounds it was found by, or the
+// DefaultSink if they haven't got one.
 func (s *Sound) Play() error {
+	return s.PlayVolume(1.0)
+}
 
-	// Init pulseaudio
-	buffer := make([]int32, 1)
-	stream, err := portaudio.OpenDefaultStream(0,
-		int(s.metadata.NChannels), float64(s.metadata.SampleRate),
-		len(buffer), &(buffer))
-	if err != nil {
-		logger.Printf("Err: %s, restarting portaudio.\n", err.Error())
-		portaudio.Terminate()
-		time.Sleep(100*time.Millisecond)
-		portaudio.Initialize()
-		time.Sleep(100*time.Millisecond)
-		stream, err = portaudio.OpenDefaultStream(0,
-			int(s.metadata.NChannels),
-			float64(s.metadata.SampleRate),
-			len(buffer), &(buffer))
+// PlayVolume is Play with the samples scaled by gain (1.0 being as
+// recorded).
+func (s *Sound) PlayVolume(gain float32) error {
+	out := s.sink
+	if out == nil { out = DefaultSink }
+	return s.PlayContext(context.Background(), out, gain, 0)
+}
+
+// PlayContext plays the sample on out at the given gain (on top of any
+// normalization).  It stops early if ctx is done, fading out over fade so it
+// doesn't click, and returns ErrInterrupted.
+func (s *Sound) PlayContext(ctx context.Context, out Sink, gain float32,
+		fade time.Duration) error {
+	rate, channels := s.metadata.SampleRate, s.metadata.NChannels
+	f := newFader(ctx, out, rate, channels, fade)
+	var lim *limiter
+	if s.normalizer != nil {
+		gain *= float32(s.gain())
+		lim = newLimiter(s.normalizer.Ceiling, rate)
 	}
+	err := s.decodeTrimmed(func(buf []int32) error {
+		if lim != nil {
+			buf = lim.scale(buf, channels, gain)
+		} else {
+			scale(buf, gain)
+		}
+		return f.write(buf)
+	})
+	return f.finish(err)
+}
+
+// fader feeds a sink a chunk at a time, keeping an eye on ctx in between,
+// and fades out and stops once ctx is done.
+type fader struct {
+	ctx context.Context
+	out Sink
+	rate, channels int
+	// total is the length of the fade and remaining how much of it is
+	// left (-1 until it starts), both in frames.
+	total, remaining int
+}
+
+// newFader starts feeding out audio in the given format.
+func newFader(ctx context.Context, out Sink, rate, channels int,
+		fade time.Duration) *fader {
+	return &fader{ ctx, out, rate, channels,
+		int(fade.Seconds() * float64(rate)), -1 }
+}
+
+// write plays interleaved samples, returning ErrInterrupted once the fade
+// is done.
+func (f *fader) write(buf []int32) error {
+	for len(buf) > 0 {
+		n := chunkFrames*f.channels
+		if n > len(buf) { n = len(buf) }
+		chunk := buf[:n]
+		buf = buf[n:]
+		if f.remaining < 0 && f.ctx.Err() != nil {
+			f.remaining = f.total
+		}
+		if f.remaining >= 0 {
+			// Only play as far as the fade goes.
+			if f.remaining*f.channels < len(chunk) {
+				chunk = chunk[:f.remaining*f.channels]
+			}
+			f.remaining = fadeOut(chunk, f.channels, f.remaining,
+				f.total)
+			if len(chunk) == 0 { return ErrInterrupted }
+		}
+		err := f.out.Write(f.rate, f.channels, chunk)
+		if err != nil { return err }
+		if f.remaining == 0 { return ErrInterrupted }
+	}
+	return nil
+}
+
+// finish flushes the sink after the last write, given what it returned.
+// Audio that ran out mid-fade still counts as interrupted.
+func (f *fader) finish(err error) error {
+	if err == nil && f.remaining >= 0 { err = ErrInterrupted }
+	if err == nil || err == ErrInterrupted {
+		flushErr := f.out.Flush()
+		if flushErr != nil { return flushErr }
+	}
+	return err
+}
+
+// decode runs through the file, handing each batch of interleaved samples
+// (scaled up to the full 32 bits) to fn.  An error from
+// fn stops the decoding and is returned.  With a cache, the samples come
+// from memory if they're there and go into it if they weren't.
+func (s *Sound) decode(fn func(buf []int32) error) error {
+	var all []int32
+	if s.cache != nil {
+		pcm := s.cache.get(s)
+		if pcm != nil { return s.replay(pcm, fn) }
+		all = make([]int32, 0,
+			int(s.metadata.NSamples)*s.metadata.NChannels)
+	}
+	dec, err := openSample(s.filepath, s.format)
 	if err != nil { return err }
-	outputter := goflacook.NewOutputter(
-		func(st *flac.Stream, buf []int32) error {
-			buffer = buf
-			return stream.Write()
-		})
-
-	// Start playing it.  Basically load the FLAC and run decode through
-	// Outputter's MainLoop()
-	if err != nil { return err }
-	err = stream.Start()
-	if err != nil { return err }
-	err = outputter.Init(s.filepath)
-	if err != nil { return err }
-	outputter.MainLoop()
-	stream.Close()
+	defer dec.Close()
+	for {
+		buf, err := dec.Read()
+		if err == io.EOF {
+			if s.cache != nil { s.cache.put(s, all) }
+			return nil
+		}
+		if err != nil { return err }
+		if s.cache != nil { all = append(all, buf...) }
+		err = fn(buf)
+		if err != nil { return err }
+	}
+}
+
+// replay is decode for samples already in memory.  fn gets its own copy of
+// each chunk, so it can't mess up the cached ones.
+func (s *Sound) replay(pcm []int32, fn func(buf []int32) error) error {
+	n := chunkFrames*s.metadata.NChannels
+	for len(pcm) > 0 {
+		if n > len(pcm) { n = len(pcm) }
+		err := fn(append([]int32{}, pcm[:n]...))
+		if err != nil { return err }
+		pcm = pcm[n:]
+	}
 	return nil
 }
 
-// Sounds represents all available FLAC files from the WoofDir.
-type Sounds []*Sound
+// scale multiplies the samples by gain, clipping at the limits.
+func scale(buf []int32, gain float32) {
+	if gain == 1.0 { return }
+	for i := range buf {
+		v := float64(buf[i]) * float64(gain)
+		v = math.Max(math.Min(v, math.MaxInt32), math.MinInt32)
+		buf[i] = int32(v)
+	}
+}
 
-// NewSounds constructs the whole list of sounds from a directory, scanning for
-// files that end in .FLAC and whose metadata can be parsed.
-func NewSounds(dirpath string) (*Sounds, error) {
+// fadeOut ramps the interleaved samples in buf down linearly, continuing a
+// fade of total frames with remaining frames to go, and silencing whatever
+// comes after it.  It returns how many frames of the fade are left.
+func fadeOut(buf []int32, channels, remaining, total int) int {
+	for i := 0; i < len(buf)/channels; i++ {
+		gain := 0.0
+		if remaining > 0 {
+			gain = float64(remaining) / float64(total)
+			remaining--
+		}
+		for ch := 0; ch < channels; ch++ {
+			buf[i*channels+ch] = int32(float64(buf[i*channels+ch]) *
+				gain)
+		}
+	}
+	return remaining
+}
 
-	// Seed the RNG for later
-	rand.Seed(time.Now().Unix())
+// Sounds represents all available samples from the WoofDir.  Samples in
+// subdirectories are picked up too, each subdirectory being a sound set (e.g.
+// woofs/growl/* is the "growl" set).
+type Sounds struct {
+	// Samples is the list of sounds we found.
+	Samples []*Sound
+	// Sink is where the Woofer plays them (nil for the DefaultSink).
+	Sink Sink
+	// Cache keeps them decoded in memory (nil for none).
+	Cache *Cache
+	// rand picks which sample PlayRandom plays.
+	rand Rand
+}
+
+// NewSounds constructs the whole list of sounds from a directory, scanning for
+// files in a format we know (whatever their names) whose metadata can be
+// parsed.  Pass WithRand to control which samples PlayRandom picks, WithSink
+// to play somewhere other than the DefaultSink, WithCache to keep the decoded
+// samples around, WithNormalizer to even out their loudness, WithTrim to skip
+// the silence at their ends and WithoutSets to ignore the subdirectories.
+func NewSounds(dirpath string, opts ...Option) (*Sounds, error) {
 
 	// Open the dir and read all ents in it.  To end up in the slice,
-	// the file must end in ".flac" and process through NewSample OK.
-	ret := make(Sounds, 0)
-	ents, err := ioutil.ReadDir(dirpath)
+	// the file must look like a sample and process through NewSample OK.
+	o := buildOptions(opts)
+	ret := Sounds{ make([]*Sound, 0), o.sink, o.cache, o.rand }
+	err := ret.scan(dirpath, "", o.flat)
 	if err != nil { return nil, err }
-	flacre := regexp.MustCompile("\\.flac$")
+	if o.trim != nil {
+		err = ret.trim(*o.trim)
+		if err != nil { return nil, err }
+	}
+	if o.normalizer != nil {
+		err = ret.analyze(o.normalizer)
+		if err != nil { return nil, err }
+	}
+	return &ret, nil
+}
+
+// WithoutSets only loads the samples right in the woofdir, leaving its
+// subdirectories alone (for a woofdir that's somebody's home directory, say).
+func WithoutSets() Option {
+	return func(o *options) { o.flat = true }
+}
+
+// scan adds the samples in a directory to the pile under the given sound
+// set, and (at the top level only, unless flat) each subdirectory as its own
+// set.  Hidden subdirectories (.git and the like) are never sets.  Files
+// that look like samples but won't decode are logged and skipped.
+func (s *Sounds) scan(dirpath, category string, flat bool) error {
+	ents, err := ioutil.ReadDir(dirpath)
+	if err != nil { return err }
 	for _, ent := range ents {
-		if flacre.MatchString(ent.Name()) {
-			filepath := fmt.Sprintf("%s/%s", dirpath, ent.Name())
+		filepath := fmt.Sprintf("%s/%s", dirpath, ent.Name())
+		if ent.IsDir() && category == "" && !flat &&
+				!strings.HasPrefix(ent.Name(), ".") {
+			err = s.scan(filepath, ent.Name(), flat)
+			if err != nil { return err }
+		} else if ent.Mode().IsRegular() {
+			// Skip anything that isn't a sample (READMEs etc.).
+			f, err := detectFormat(filepath)
+			if err != nil { return err }
+			if f == nil { continue }
 			sound, err := NewSample(filepath)
-			if err != nil { return nil, err }
-			ret = append(ret, sound)
+			if err != nil {
+				// One broken file shouldn't silence the
+				// rest.
+				logger.Printf("Skipping %s\n", err.Error())
+				continue
+			}
+			sound.category = category
+			sound.cache = s.Cache
+			sound.sink = s.Sink
+			s.Samples = append(s.Samples, sound)
 		}
 	}
-	return &ret, nil
+	return nil
+}
+
+// Preload decodes samples into the cache until it's full, so even the first
+// bark of each doesn't have to wait for the disk.
+func (s *Sounds) Preload() error {
+	if s.Cache == nil { return nil }
+	for _, sound := range s.Samples {
+		stats := s.Cache.Stats()
+		size := sound.metadata.NSamples *
+			int64(sound.metadata.NChannels)*4
+		if stats.Bytes+size > stats.Limit { break }
+		err := sound.decode(func(buf []int32) error { return nil })
+		if err != nil { return err }
+	}
+	return nil
 }
 
 // PlayRandom plays one random sound from the pile.
 func (s *Sounds) PlayRandom() error {
-	samp := (*s)[rand.Intn(len(*s))]
-	return samp.Play()
+	samp := s.Samples[s.rand.Intn(len(s.Samples))]
+	return samp.PlayContext(context.Background(), s.out(), 1.0, 0)
+}
+
+// PlayRandomFrom plays one random sound at the given gain from the first of
+// the sound sets that has any sounds in it.  If none do, it picks from the
+// whole pile.
+func (s *Sounds) PlayRandomFrom(categories []string, gain float32) error {
+	samp, err := s.PickFrom(categories)
+	if err != nil { return err }
+	return samp.PlayContext(context.Background(), s.out(), gain, 0)
+}
+
+// out is where the sounds get played.
+func (s *Sounds) out() Sink {
+	if s.Sink == nil { return DefaultSink }
+	return s.Sink
+}
+
+// PickFrom picks the sound PlayRandomFrom would play.
+func (s *Sounds) PickFrom(categories []string) (*Sound, error) {
+	set := make([]*Sound, 0)
+	for _, category := range categories {
+		for _, sound := range s.Samples {
+			if sound.category == category {
+				set = append(set, sound)
+			}
+		}
+		if len(set) != 0 { break }
+	}
+	if len(set) == 0 { set = s.Samples }
+	if len(set) == 0 { return nil, errors.New("No sounds available") }
+	return set[s.rand.Intn(len(set))], nil
+}
+
+// Find looks up a sound by its Name, returning nil if there isn't one.
+func (s *Sounds) Find(name string) *Sound {
+	for _, sound := range s.Samples {
+		if sound.Name() == name { return sound }
+	}
+	return nil
 }
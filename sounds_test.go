@@ -1,19 +1,23 @@
-// Test routines for the FLAC decoder.
+// Test routines for the sample scanner and player.
 
 package woofie
 
 import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-// TestSounds loads the sample woofdir and ensures that the FLAC files parse OK
+// TestSounds loads the sample woofdir and ensures that the samples parse OK
 func TestSounds(t *testing.T) {
 	samples, err := NewSounds("woofs")
 	if err != nil { t.Error(err) }
-	if len(*samples) != 3 {
-		t.Error("Expected 3 samples, got ", len(*samples))
+	if len(samples.Samples) != 3 {
+		t.Error("Expected 3 samples, got ", len(samples.Samples))
 	}
-	for _, sample := range *samples {
+	for _, sample := range samples.Samples {
 		if sample.metadata.SampleRate != 22500.0 {
 			t.Error("Got wrong samplerate for ", sample.filepath,
 				":  Expected 22500, got ",
@@ -21,3 +25,93 @@ func TestSounds(t *testing.T) {
 		}
 	}
 }
+
+// TestSoundsSink checks the sounds play on their own sink rather than the
+// DefaultSink.
+func TestSoundsSink(t *testing.T) {
+	out := &recordSink{}
+	samples, err := NewSounds("woofs", WithSink(out),
+		WithRand(fixedRand(0)))
+	if err != nil { t.Fatal(err) }
+	err = samples.PlayRandom()
+	if err != nil { t.Fatal(err) }
+	n := len(out.pcm)
+	if n == 0 { t.Fatal("Expected PlayRandom to play on the sink") }
+	err = samples.Samples[0].Play()
+	if err != nil { t.Fatal(err) }
+	if len(out.pcm) != 2*n {
+		t.Error("Expected Play to play on the sink too, got ",
+			len(out.pcm) - n, " samples")
+	}
+}
+
+// TestSoundsSkipBroken checks a file that looks like a sample but won't
+// decode is left out rather than failing the whole load.
+func TestSoundsSkipBroken(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	writeFixture(t, dir, "good.flac", flacFixture(1, make([]int32, 100)))
+	writeFixture(t, dir, "broken.flac", []byte("fLaC, or so it says"))
+	samples, err := NewSounds(dir)
+	if err != nil { t.Fatal(err) }
+	if len(samples.Samples) != 1 || samples.Samples[0].Name() != "good.flac" {
+		t.Error("Expected just good.flac, got ", samples.Samples)
+	}
+}
+
+// TestSoundsSets checks subdirectories are sound sets (bar the hidden ones),
+// and that WithoutSets leaves them alone.
+func TestSoundsSets(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	pcm := make([]int32, 100)
+	writeFixture(t, dir, "bark.flac", flacFixture(1, pcm))
+	for _, sub := range []string{ "growl", ".git", "growl/deeper" } {
+		err = os.Mkdir(filepath.Join(dir, sub), 0755)
+		if err != nil { t.Fatal(err) }
+		writeFixture(t, filepath.Join(dir, sub), "woof.flac",
+			flacFixture(1, pcm))
+	}
+	samples, err := NewSounds(dir)
+	if err != nil { t.Fatal(err) }
+	if len(samples.Samples) != 2 ||
+			samples.Samples[1].Category() != "growl" {
+		t.Error("Expected bark.flac and growl, got ", samples.Samples)
+	}
+	samples, err = NewSounds(dir, WithoutSets())
+	if err != nil { t.Fatal(err) }
+	if len(samples.Samples) != 1 || samples.Samples[0].Name() != "bark.flac" {
+		t.Error("Expected just bark.flac, got ", samples.Samples)
+	}
+}
+
+// TestFadeOut checks the fade ramp carries across chunks and silences
+// whatever comes after it.
+func TestFadeOut(t *testing.T) {
+	buf := []int32{ 100, 100, 100, 100, 100, 100 }
+	remaining := fadeOut(buf, 2, 4, 4)
+	if remaining != 1 {
+		t.Error("Expected 1 frame of fade left, got ", remaining)
+	}
+	expected := []int32{ 100, 100, 75, 75, 50, 50 }
+	for i := range buf {
+		if buf[i] != expected[i] {
+			t.Errorf("Sample %d: expected %d, got %d", i,
+				expected[i], buf[i])
+		}
+	}
+	buf = []int32{ 100, 100, 100, 100 }
+	remaining = fadeOut(buf, 2, remaining, 4)
+	expected = []int32{ 25, 25, 0, 0 }
+	for i := range buf {
+		if buf[i] != expected[i] {
+			t.Errorf("Sample %d: expected %d, got %d", i,
+				expected[i], buf[i])
+		}
+	}
+	if remaining != 0 { t.Error("Expected the fade to be done") }
+}
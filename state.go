@@ -0,0 +1,231 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the state file, which lets the dog remember how tired
+// it is (and what it's been told) across restarts.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Overrides is whatever was changed at runtime (through the API) rather than
+// on the command line.  Unset fields weren't touched.
+type Overrides struct {
+	// Parameters is the last set of parameters asked for.
+	Parameters *Parameters `json:"parameters,omitempty"`
+	// Profile is the last personality asked for.
+	Profile string `json:"profile,omitempty"`
+	// Schedule is the last quiet schedule asked for.
+	Schedule *Schedules `json:"schedule,omitempty"`
+	// Volume is the last volume setup asked for.
+	Volume *Volume `json:"volume,omitempty"`
+}
+
+// State is everything the state file remembers.
+type State struct {
+	// Saved is when the state was written.
+	Saved time.Time `json:"saved"`
+	// WoofLog is the global bark log.
+	WoofLog []time.Time `json:"woof_log"`
+	// WoofZones is the zone of each bark in WoofLog.
+	WoofZones []string `json:"woof_zones,omitempty"`
+	// ZoneLogs is each zone's bark log.
+	ZoneLogs map[string][]time.Time `json:"zone_logs,omitempty"`
+	// WoofStart and WoofUntil are the current bark cycle, if any.
+	WoofStart time.Time `json:"woof_start"`
+	WoofUntil time.Time `json:"woof_until"`
+	// Level and LevelTime are where the escalation ladder stood and
+	// when it last moved.
+	Level int `json:"level"`
+	LevelTime time.Time `json:"level_time"`
+	// Armed and SnoozeUntil are the armed/snoozed state.
+	Armed bool `json:"armed"`
+	SnoozeUntil time.Time `json:"snooze_until"`
+	// Overrides is what was changed at runtime.
+	Overrides Overrides `json:"overrides"`
+}
+
+// ReadState reads a state file.
+func ReadState(path string) (*State, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil { return nil, err }
+	ret := State{}
+	err = json.Unmarshal(buf, &ret)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Bad state file %s: %s",
+			path, err.Error()))
+	}
+	return &ret, nil
+}
+
+// WriteState writes a state file atomically: it goes to a temp file in the
+// same directory which then replaces the old one, so a crash leaves either
+// the old state or the new one but never half of each.
+func WriteState(path string, s *State) error {
+	buf, err := json.MarshalIndent(s, "", "\t")
+	if err != nil { return err }
+	f, err := ioutil.TempFile(filepath.Dir(path),
+		fmt.Sprintf(".%s.", filepath.Base(path)))
+	if err != nil { return err }
+	_, err = f.Write(buf)
+	if err == nil { err = f.Sync() }
+	if err == nil { err = f.Close() } else { f.Close() }
+	if err == nil { err = os.Rename(f.Name(), path) }
+	if err != nil { os.Remove(f.Name()) }
+	return err
+}
+
+// Restore puts the Woofer back the way the state file at path left it
+// (minus any barks too old to matter any more).  A missing file is a fresh
+// dog, not an error.  Call it once the command-line setup is done: anything
+// changed through the APIs last time round then wins over the command line,
+// which still decides everything the APIs never touched.
+func (w *Woofer) Restore(path string) error {
+	s, err := ReadState(path)
+	if os.IsNotExist(err) { return nil }
+	if err != nil { return err }
+	return w.restore(s)
+}
+
+// Persist keeps the Woofer's state in a file, saving every change in the
+// background from then on.  It writes the file straight away, so Restore
+// from it first to keep what's already there.
+func (w *Woofer) Persist(path string) error {
+	w.Lock()
+	w.statePath = path
+	w.save = make(chan bool, 1)
+	w.saved = make(chan bool)
+	save, saved := w.save, w.saved
+	w.Unlock()
+	go func() {
+		for range save {
+			err := w.SaveState()
+			if err != nil {
+				logger.Printf("Can't save state: %s\n",
+					err.Error())
+			}
+		}
+		close(saved)
+	}()
+	return w.SaveState()
+}
+
+// Close stops persisting the state, saving it one last time.
+func (w *Woofer) Close() error {
+	w.Lock()
+	save, saved := w.save, w.saved
+	w.save = nil
+	w.Unlock()
+	if save == nil { return nil }
+	close(save)
+	<-saved
+	err := w.SaveState()
+	w.Lock()
+	w.statePath = ""
+	w.Unlock()
+	return err
+}
+
+// SaveState writes the state file right away.
+func (w *Woofer) SaveState() error {
+	w.Lock()
+	path := w.statePath
+	s := w.state()
+	w.Unlock()
+	if path == "" { return errors.New("No state file") }
+	return WriteState(path, s)
+}
+
+// changed lets the saver know there's something new to save.  The caller
+// must hold the lock.
+func (w *Woofer) changed() {
+	select {
+		case w.save <- true:
+		default:
+	}
+}
+
+// state snapshots the Woofer.  The caller must hold the lock.
+func (w *Woofer) state() *State {
+	ret := State{ Saved: w.Clock.Now(), WoofStart: w.WoofStart,
+		WoofUntil: w.WoofUntil, Level: w.Escalation.level,
+		LevelTime: w.Escalation.last, Armed: w.Armed,
+		SnoozeUntil: w.SnoozeUntil, Overrides: w.overrides }
+	ret.WoofLog = append([]time.Time{}, w.WoofLog...)
+	ret.WoofZones = append([]string{}, w.woofZones...)
+	ret.ZoneLogs = make(map[string][]time.Time)
+	for name, log := range w.zoneLogs {
+		ret.ZoneLogs[name] = append([]time.Time{}, log...)
+	}
+	return &ret
+}
+
+// restore puts the Woofer back the way a state file says it was.
+func (w *Woofer) restore(s *State) error {
+	// Overrides go first, since the scoring decides what's stale.
+	if s.Overrides.Profile != "" {
+		err := w.SetProfile(s.Overrides.Profile)
+		if err != nil {
+			logger.Printf("Not restoring profile: %s\n", err.Error())
+		}
+	}
+	if s.Overrides.Parameters != nil {
+		err := w.SetParameters(*s.Overrides.Parameters)
+		if err != nil { return err }
+	}
+	if s.Overrides.Schedule != nil {
+		w.SetSchedule(s.Overrides.Schedule)
+	}
+	if s.Overrides.Volume != nil {
+		err := w.SetVolume(*s.Overrides.Volume)
+		if err != nil { return err }
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.overrides = s.Overrides
+	w.WoofLog = append([]time.Time{}, s.WoofLog...)
+	// Older state files don't say whose barks were whose.
+	w.woofZones = append([]string{}, s.WoofZones...)
+	if len(w.woofZones) != len(w.WoofLog) {
+		w.woofZones = make([]string, len(w.WoofLog))
+	}
+	w.zoneLogs = make(map[string][]time.Time)
+	for name, log := range s.ZoneLogs {
+		w.zoneLogs[name] = append([]time.Time{}, log...)
+	}
+	w.WoofStart = s.WoofStart
+	w.WoofUntil = s.WoofUntil
+	w.Escalation.level = s.Level
+	if w.Escalation.level >= len(w.Escalation.Levels) {
+		w.Escalation.level = len(w.Escalation.Levels) - 1
+	}
+	if w.Escalation.level < 0 { w.Escalation.level = 0 }
+	w.Escalation.last = s.LevelTime
+	w.Armed = s.Armed
+	w.SnoozeUntil = s.SnoozeUntil
+
+	// Scoring hoovers the logs, which prunes anything past the horizon.
+	w.logScore()
+	for name := range w.zoneLogs {
+		zone := w.Zones.Get(name)
+		if zone.Sensitivity == 0 {
+			delete(w.zoneLogs, name)
+		} else {
+			w.zoneScore(zone)
+		}
+	}
+	logger.Printf("Restored state from %s (%d barks in the log)\n",
+		s.Saved.Format(time.RFC3339), len(w.WoofLog))
+	return nil
+}
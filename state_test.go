@@ -0,0 +1,94 @@
+// Test routines for the state file.
+
+package woofie
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStateRestore checks that a restarted Woofer picks up where the old one
+// left off, minus the stale barks.
+func TestStateRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	err = woofer.Restore(path)
+	if err != nil { t.Error("Expected no state file to be fine, got ", err) }
+	err = woofer.Persist(path)
+	if err != nil { t.Fatal(err) }
+	woofer.WoofOnFrom(Sensor{ Zone: "garage" })
+	clock.Advance(45*time.Minute)
+	for minute := 0; minute < 6; minute++ {
+		woofer.WoofOnFrom(Sensor{ Zone: "porch" })
+		clock.Advance(time.Minute)
+	}
+	p := woofer.Parameters()
+	p.Score = 140
+	err = woofer.SetParameters(p)
+	if err != nil { t.Fatal(err) }
+	woofer.Snooze(10*time.Minute)
+	err = woofer.SaveState()
+	if err != nil { t.Fatal(err) }
+
+	// The garage bark is past the horizon by now.
+	restarted := testWoofer(clock, fixedRand(0.99), 5)
+	err = restarted.Restore(path)
+	if err != nil { t.Fatal(err) }
+	err = restarted.Persist(path)
+	if err != nil { t.Fatal(err) }
+	status := restarted.Status()
+	if status.LogSize != 6 {
+		t.Error("Expected 6 barks in the log, got ", status.LogSize)
+	}
+	if len(status.Zones) != 1 || status.Zones[0].Name != "porch" {
+		t.Error("Expected only the porch zone, got ", status.Zones)
+	}
+	if status.Parameters.Score != 140 {
+		t.Error("Expected the score override, got ",
+			status.Parameters.Score)
+	}
+	if !status.SnoozeUntil.Equal(clock.Now().Add(10*time.Minute)) {
+		t.Error("Expected to still be snoozing, got ",
+			status.SnoozeUntil)
+	}
+	if got, _ := restarted.WoofOnFrom(Sensor{}); got {
+		t.Error("Expected a snoozing dog to ignore triggers")
+	}
+
+	// A fresh dog would score nothing here.
+	restarted.Arm()
+	if _, score := restarted.WoofOnFrom(Sensor{}); score == 0 {
+		t.Error("Expected the restored log to count")
+	}
+	err = restarted.Close()
+	if err != nil { t.Fatal(err) }
+	err = woofer.Close()
+	if err != nil { t.Fatal(err) }
+}
+
+// TestWriteState checks that writing leaves just the state file behind.
+func TestWriteState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	for i := 0; i < 2; i++ {
+		err = WriteState(path, &State{ Armed: i == 0 })
+		if err != nil { t.Fatal(err) }
+	}
+	ents, err := ioutil.ReadDir(dir)
+	if err != nil { t.Fatal(err) }
+	if len(ents) != 1 { t.Error("Expected one file, got ", len(ents)) }
+	s, err := ReadState(path)
+	if err != nil { t.Fatal(err) }
+	if s.Armed { t.Error("Expected the second write to win") }
+}
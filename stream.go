@@ -0,0 +1,236 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the HTTP stream sink, which serves the barks as one
+// endless stream (a WAV for players, or raw PCM for Snapcast and the like)
+// to however many listeners connect.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamBacklog is how many chunks a listener can fall behind before it's
+// dropped.
+const streamBacklog = 64
+
+// streamListener is one HTTP client of a StreamSink.
+type streamListener struct {
+	chunks chan []byte
+}
+
+// StreamSink serves the audio over HTTP in one fixed format, going out in
+// real time (with silence when there's nothing playing) to every client.
+// GET / gets a WAV that never ends; GET /raw (or ?raw) gets headerless 16-bit
+// little-endian PCM.  ?delay=MS starts a client that far behind the rest,
+// to line it up with quicker speakers.
+type StreamSink struct {
+	// Rate and Channels are the stream's format.
+	Rate, Channels int
+	// Latency is how far ahead of real time writes can run.
+	Latency time.Duration
+	listener net.Listener
+	clock Clock
+	// queue is the audio written but not yet sent.
+	queue []int32
+	listeners map[*streamListener]bool
+	closed bool
+	// done stops the pump, which closes pumped on the way out.
+	done, pumped chan bool
+	sync.Mutex
+}
+
+// NewStreamSink starts serving a stream on addr (e.g. ":8000").  Pass
+// WithClock to run on something other than the wall clock.
+func NewStreamSink(addr string, rate, channels int, latency time.Duration,
+		opts ...Option) (*StreamSink, error) {
+	if rate <= 0 || channels <= 0 {
+		return nil, errors.New(fmt.Sprintf("Bad stream format: " +
+			"%d-channel %dHz", channels, rate))
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil { return nil, err }
+	o := buildOptions(opts)
+	ret := &StreamSink{ Rate: rate, Channels: channels, Latency: latency,
+		listener: listener, clock: o.clock,
+		listeners: make(map[*streamListener]bool),
+		done: make(chan bool), pumped: make(chan bool) }
+	go http.Serve(listener, ret)
+	go ret.pump()
+	logger.Printf("Streaming %d-channel %dHz audio on http://%s/\n",
+		channels, rate, listener.Addr())
+	return ret, nil
+}
+
+// Addr is the address the stream is served on.
+func (s *StreamSink) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// ServeHTTP implements http.Handler, streaming to the client until it
+// hangs up or falls too far behind.
+func (s *StreamSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	raw := strings.TrimSuffix(r.URL.Path, "/") == "/raw" || query.Has("raw")
+	var delay time.Duration
+	if d := query.Get("delay"); d != "" {
+		ms, err := strconv.Atoi(d)
+		if err != nil || ms < 0 {
+			http.Error(w, fmt.Sprintf("Bad delay: %s", d),
+				http.StatusBadRequest)
+			return
+		}
+		delay = time.Duration(ms)*time.Millisecond
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	l := &streamListener{ make(chan []byte, streamBacklog) }
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		http.Error(w, "Stream closed", http.StatusServiceUnavailable)
+		return
+	}
+	s.listeners[l] = true
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.listeners, l)
+		s.Unlock()
+	}()
+	logger.Printf("Stream listener %s connected\n", r.RemoteAddr)
+	defer logger.Printf("Stream listener %s gone\n", r.RemoteAddr)
+
+	if raw {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "audio/wav")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	if !raw {
+		_, err := w.Write(wavHeader(s.Rate, s.Channels, 0xffffffff - 36))
+		if err != nil { return }
+	}
+	if delay > 0 {
+		frames := int(delay.Seconds() * float64(s.Rate))
+		_, err := w.Write(make([]byte, frames*s.Channels*2))
+		if err != nil { return }
+	}
+	flusher.Flush()
+	for {
+		select {
+			case chunk, ok := <-l.chunks:
+				if !ok { return }
+				_, err := w.Write(chunk)
+				if err != nil { return }
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+		}
+	}
+}
+
+// pump sends the queue out to the listeners in real time, a chunk at a
+// time, with silence whenever it's empty.  The chunks go out under the lock,
+// so a listener can't be hung up on halfway through.
+func (s *StreamSink) pump() {
+	defer close(s.pumped)
+	p := pacer{ clock: s.clock }
+	n := int(packetTime.Seconds() * float64(s.Rate)) * s.Channels
+	for {
+		select {
+			case <-s.done:
+				return
+			default:
+		}
+		p.begin()
+		buf := make([]int32, n)
+		s.Lock()
+		copied := copy(buf, s.queue)
+		s.queue = s.queue[copied:]
+		chunk := pcm16(buf)
+		for l := range s.listeners {
+			select {
+				case l.chunks <- chunk:
+				default:
+					s.drop(l)
+			}
+		}
+		s.Unlock()
+		p.wait(packetTime)
+	}
+}
+
+// drop disconnects a listener that's fallen behind.  The caller must hold
+// the lock.
+func (s *StreamSink) drop(l *streamListener) {
+	delete(s.listeners, l)
+	close(l.chunks)
+	logger.Println("Dropped a stream listener that fell behind")
+}
+
+// queued is how long the queue takes to play.  The caller must hold the
+// lock.
+func (s *StreamSink) queued() time.Duration {
+	return time.Duration(len(s.queue)/s.Channels) * time.Second /
+		time.Duration(s.Rate)
+}
+
+// Write implements Sink, queueing the audio and waiting while there's more
+// than Latency of it.
+func (s *StreamSink) Write(rate, channels int, buf []int32) error {
+	if rate != s.Rate || channels != s.Channels {
+		return errors.New(fmt.Sprintf("Stream is %d-channel %dHz, " +
+			"can't add %d-channel %dHz", s.Channels, s.Rate,
+			channels, rate))
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.closed { return errors.New("Stream closed") }
+	s.queue = append(s.queue, buf...)
+	for {
+		ahead := s.queued() - s.Latency
+		if ahead <= 0 || s.closed { return nil }
+		s.Unlock()
+		s.clock.Sleep(ahead)
+		s.Lock()
+	}
+}
+
+// Flush implements Sink.  The queue plays out by itself.
+func (s *StreamSink) Flush() error {
+	return nil
+}
+
+// Close implements Sink, hanging up on the listeners once the pump has
+// stopped.
+func (s *StreamSink) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for l := range s.listeners {
+		delete(s.listeners, l)
+		close(l.chunks)
+	}
+	s.Unlock()
+	<-s.pumped
+	return s.listener.Close()
+}
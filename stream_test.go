@@ -0,0 +1,112 @@
+// Test routines for the HTTP stream sink.
+
+package woofie
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestStreamSink checks a WAV client gets the header and a raw one gets
+// its delay in silence, that both then get what's written, and that they're
+// hung up on when the sink closes.
+func TestStreamSink(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	sink, err := NewStreamSink("127.0.0.1:0", 48000, 2, time.Minute)
+	if err != nil { t.Fatal(err) }
+	defer sink.Close()
+	url := fmt.Sprintf("http://%s", sink.Addr())
+
+	wav, err := http.Get(url + "/")
+	if err != nil { t.Fatal(err) }
+	defer wav.Body.Close()
+	if wav.Header.Get("Content-Type") != "audio/wav" {
+		t.Error("Bad WAV content type: ", wav.Header.Get("Content-Type"))
+	}
+	hdr := make([]byte, 44)
+	_, err = io.ReadFull(wav.Body, hdr)
+	if err != nil { t.Fatal(err) }
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:16]) != "WAVEfmt " ||
+			binary.LittleEndian.Uint32(hdr[24:]) != 48000 ||
+			binary.LittleEndian.Uint16(hdr[22:]) != 2 {
+		t.Error("Bad WAV header: ", hdr)
+	}
+
+	raw, err := http.Get(url + "/raw?delay=10")
+	if err != nil { t.Fatal(err) }
+	defer raw.Body.Close()
+	silence := make([]byte, 480*4)
+	_, err = io.ReadFull(raw.Body, silence)
+	if err != nil { t.Fatal(err) }
+	for i, b := range silence {
+		if b != 0 { t.Fatal("Expected silence, got ", b, " at ", i) }
+	}
+
+	buf := make([]int32, 4800*2)
+	for i := range buf {
+		buf[i] = 1 << 30
+	}
+	err = sink.Write(48000, 2, buf)
+	if err != nil { t.Fatal(err) }
+	for _, body := range []io.Reader{ wav.Body, raw.Body } {
+		// There may be silence ahead of it, but not much.
+		sample := make([]byte, 2)
+		found := false
+		for i := 0; i < 48000*2 && !found; i++ {
+			_, err = io.ReadFull(body, sample)
+			if err != nil { t.Fatal(err) }
+			v := binary.LittleEndian.Uint16(sample)
+			if v == 0 { continue }
+			if v != 0x4000 { t.Fatal("Expected 0x4000, got ", v) }
+			found = true
+		}
+		if !found { t.Error("The audio never arrived") }
+	}
+
+	err = sink.Write(44100, 2, buf)
+	if err == nil { t.Error("Expected an error for the wrong format") }
+	bad, err := http.Get(url + "/?delay=soon")
+	if err != nil { t.Fatal(err) }
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Error("Expected a bad request for a bad delay, got ",
+			bad.Status)
+	}
+
+	err = sink.Close()
+	if err != nil { t.Fatal(err) }
+	_, err = io.Copy(ioutil.Discard, raw.Body)
+	if err != nil { t.Error("Expected a clean hangup, got ", err) }
+}
+
+// TestStreamClose closes the sink while a listener is connected and the pump
+// is going, which mustn't send to the hung up listener (or leave the pump
+// running).
+func TestStreamClose(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	for i := 0; i < 20; i++ {
+		sink, err := NewStreamSink("127.0.0.1:0", 8000, 1, time.Minute)
+		if err != nil { t.Fatal(err) }
+		raw, err := http.Get(fmt.Sprintf("http://%s/raw", sink.Addr()))
+		if err != nil { t.Fatal(err) }
+		err = sink.Write(8000, 1, make([]int32, 800))
+		if err != nil { t.Fatal(err) }
+		time.Sleep(time.Duration(i)*time.Millisecond)
+		err = sink.Close()
+		if err != nil { t.Fatal(err) }
+		select {
+			case <-sink.pumped:
+			default:
+				t.Error("Expected the pump to have stopped")
+		}
+		_, err = io.Copy(ioutil.Discard, raw.Body)
+		if err != nil { t.Error("Expected a clean hangup, got ", err) }
+		raw.Body.Close()
+	}
+}
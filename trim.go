@@ -0,0 +1,92 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements silence trimming, so a recording with a second of
+// nothing at the start doesn't hold up the first bark after a trigger.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"math"
+	"time"
+)
+
+// trimPad is how much of the silence is left either side of the sound, so
+// soft starts and endings don't get clipped.
+const trimPad = 0.01
+
+// Trim is the part of a sample worth playing, in frames.
+type Trim struct {
+	// Start is the first frame to play and End the one after the last.
+	Start, End int64
+}
+
+// WithTrim trims the silence (anything quieter than threshold dBFS) off
+// both ends of the samples as they're loaded.
+func WithTrim(threshold float64) Option {
+	return func(o *options) { o.trim = &threshold }
+}
+
+// trim works out how much silence each sample has at either end.
+func (s *Sounds) trim(threshold float64) error {
+	for _, sound := range s.Samples {
+		trim, err := sound.findTrim(threshold)
+		if err != nil { return err }
+		sound.trim = trim
+	}
+	return nil
+}
+
+// findTrim decodes the sample and finds the first and last frames louder
+// than threshold dBFS.  A sample that's silent all the way through is left
+// alone rather than trimmed to nothing.
+func (s *Sound) findTrim(threshold float64) (Trim, error) {
+	level := math.Pow(10, threshold/20) * -math.MinInt32
+	channels := s.metadata.NChannels
+	first, last := int64(-1), int64(-1)
+	var frames int64
+	err := s.decode(func(buf []int32) error {
+		for i, v := range buf {
+			if math.Abs(float64(v)) <= level { continue }
+			frame := frames + int64(i/channels)
+			if first < 0 { first = frame }
+			last = frame
+		}
+		frames += int64(len(buf)/channels)
+		return nil
+	})
+	if err != nil || first < 0 { return Trim{}, err }
+	pad := int64(trimPad * float64(s.metadata.SampleRate))
+	ret := Trim{ first - pad, last + 1 + pad }
+	if ret.Start < 0 { ret.Start = 0 }
+	if ret.End > frames { ret.End = frames }
+	if ret == (Trim{ 0, frames }) { return Trim{}, nil }
+	return ret, nil
+}
+
+// Trimmed is how much silence is skipped at the ends of the sample.
+func (s *Sound) Trimmed() time.Duration {
+	if s.trim == (Trim{}) { return 0 }
+	frames := s.trim.Start + s.metadata.NSamples - s.trim.End
+	if frames < 0 { frames = 0 }
+	return time.Duration(float64(frames) /
+		float64(s.metadata.SampleRate) * float64(time.Second))
+}
+
+// decodeTrimmed is decode without the silence at either end.
+func (s *Sound) decodeTrimmed(fn func(buf []int32) error) error {
+	if s.trim == (Trim{}) { return s.decode(fn) }
+	channels := s.metadata.NChannels
+	var pos int64
+	return s.decode(func(buf []int32) error {
+		frames := int64(len(buf)/channels)
+		from, to := s.trim.Start - pos, s.trim.End - pos
+		pos += frames
+		if from < 0 { from = 0 }
+		if to > frames { to = frames }
+		if to <= from { return nil }
+		return fn(buf[from*int64(channels):to*int64(channels)])
+	})
+}
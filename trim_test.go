@@ -0,0 +1,66 @@
+// Test routines for silence trimming.
+
+package woofie
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTrim loads a sample with silence either side of a tone and one with
+// nothing but silence, and checks only the first is trimmed and played
+// without the silence.
+func TestTrim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	for name, level := range map[string]int32{ "bark": 1 << 28,
+			"hush": 1 << 10 } {
+		sink, err := NewWavSink(filepath.Join(dir, name + ".wav"))
+		if err != nil { t.Fatal(err) }
+		// A second of silence, half a second of sound and a quarter
+		// of a second of silence, at 8kHz.
+		pcm := make([]int32, 14000)
+		for i := 8000; i < 12000; i++ {
+			pcm[i] = level
+		}
+		err = sink.Write(8000, 1, pcm)
+		if err == nil { err = sink.Close() }
+		if err != nil { t.Fatal(err) }
+	}
+	sounds, err := NewSounds(dir, WithTrim(-50))
+	if err != nil { t.Fatal(err) }
+	bark, hush := sounds.Find("bark.wav"), sounds.Find("hush.wav")
+	if bark == nil || hush == nil { t.Fatal("Missing samples") }
+
+	// The padding leaves 10ms either side.
+	if bark.Trimmed() != 1230*time.Millisecond {
+		t.Error("Expected 1.23s trimmed, got ", bark.Trimmed())
+	}
+	if !strings.Contains(bark.String(), "1.23 secs of silence trimmed") {
+		t.Error("Expected the trim in ", bark.String())
+	}
+	if hush.Trimmed() != 0 {
+		t.Error("Expected a silent sample to be left alone, got ",
+			hush.Trimmed())
+	}
+	sink := &recordSink{}
+	err = bark.PlayContext(context.Background(), sink, 1.0, 0)
+	if err != nil { t.Fatal(err) }
+	if len(sink.pcm) != 4160 {
+		t.Fatal("Expected 4160 frames played, got ", len(sink.pcm))
+	}
+	if sink.pcm[80] == 0 || sink.pcm[79] != 0 {
+		t.Error("Expected the sound to start after the padding")
+	}
+	pcm, err := bark.load(8000, QualityLinear)
+	if err != nil { t.Fatal(err) }
+	if len(pcm) != 4160 {
+		t.Error("Expected 4160 frames mixed, got ", len(pcm))
+	}
+}
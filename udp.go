@@ -36,18 +36,20 @@ func NewUdpWoofTrigger(pw string, port int) (*UdpWoofTrigger, error) {
 }
 
 // ProcessBytes double-checks the packet data against the two possible
-// transaction types.
-func (wt UdpWoofTrigger) ProcessBytes(buf []byte, woofer *Woofer) error {
+// transaction types.  The sender is the sensor (the packets carry no other
+// identity).
+func (wt UdpWoofTrigger) ProcessBytes(buf []byte, sensor Sensor,
+		woofer *Woofer) error {
 	if len(buf) != 16 {
 		return errors.New(fmt.Sprintf("Invalid packet size %d",
 			len(buf)))
 	}
 	if bytes.Equal(buf, wt.onbytes) {
-		woofer.WoofOn()
-		logger.Println("Received on request")
+		logger.Println(requestLine(true, sensor))
+		woofer.WoofOnFrom(sensor)
 	} else if bytes.Equal(buf, wt.offbytes) {
-		woofer.WoofOff()
-		logger.Println("Received off request")
+		logger.Println(requestLine(false, sensor))
+		woofer.WoofOffFrom(sensor)
 	} else {
 		return errors.New("Received invalid request.")
 	}
@@ -68,7 +70,8 @@ func (wt UdpWoofTrigger) MainLoop(logger *log.Logger, woofer *Woofer) error {
 		} else {
 			logger.Printf("Packet from %s (%d len)\n",
 				src.String(), nb)
-			err = wt.ProcessBytes(buf[:16], woofer)
+			sensor := Sensor{ ID: src.IP.String(), Kind: "udp" }
+			err = wt.ProcessBytes(buf[:16], sensor, woofer)
 			if err != nil {
 				logger.Printf("Error processing packet: %s\n",
 					err.Error())
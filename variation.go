@@ -0,0 +1,125 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements procedural variation: nudging the pitch, length and
+// loudness of each bark a little so a handful of recordings don't repeat.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// grainLength is the length of the pieces stretch cuts the audio into.
+const grainLength = 0.04
+
+// Variation bounds how much each bark may be varied, either way.
+type Variation struct {
+	// Pitch is the most the pitch moves, in semitones.
+	Pitch float64
+	// Tempo is the most the length changes, in percent.
+	Tempo float64
+	// Gain is the most the loudness changes, in dB.
+	Gain float64
+}
+
+// NewVariation parses a --variation spec like "pitch=1,tempo=10,gain=3".
+// Anything left out doesn't vary.
+func NewVariation(spec string) (Variation, error) {
+	ret := Variation{}
+	s, err := parseSettings(spec)
+	if err != nil { return ret, err }
+	err = s.apply(map[string]*float64{ "pitch": &ret.Pitch,
+		"tempo": &ret.Tempo, "gain": &ret.Gain })
+	if err != nil { return ret, err }
+	if ret.Pitch < 0 || ret.Pitch > 12 || ret.Tempo < 0 ||
+			ret.Tempo >= 100 || ret.Gain < 0 {
+		return ret, errors.New(fmt.Sprintf("Bad variation: %s", spec))
+	}
+	return ret, nil
+}
+
+// Pick draws one bark's variant.  The same whims give the same variant, so
+// a seeded Rand varies the same way every time.
+func (v Variation) Pick(r Rand) Variant {
+	if v == (Variation{}) { return Variant{} }
+	jitter := func(max float64) float64 {
+		return (2*float64(r.Float32()) - 1) * max
+	}
+	return Variant{ jitter(v.Pitch), jitter(v.Tempo) / 100, jitter(v.Gain) }
+}
+
+// Variant is how one bark is varied.  The zero Variant leaves it alone.
+type Variant struct {
+	// Semitones moves the pitch up (or down if negative).
+	Semitones float64
+	// Stretch changes the length by that fraction (e.g. 0.1 is 10%
+	// longer) without touching the pitch.
+	Stretch float64
+	// GainDB changes the loudness.
+	GainDB float64
+}
+
+// String describes the variant for the logs.
+func (v Variant) String() string {
+	return fmt.Sprintf("%+.2f semitones, %+.1f%% length, %+.1f dB",
+		v.Semitones, v.Stretch*100, v.GainDB)
+}
+
+// gain is the variant's loudness change as a multiplier.
+func (v Variant) gain() float64 {
+	return math.Pow(10, v.GainDB/20)
+}
+
+// apply varies the pitch and length of interleaved audio at the given rate.
+// The pitch is moved by resampling, which changes the length too, and then
+// stretch sets the length right.
+func (v Variant) apply(pcm []float64, rate, channels int,
+		quality string) ([]float64, error) {
+	ratio := math.Pow(2, v.Semitones/12)
+	if v.Semitones != 0 {
+		from := int(math.Round(float64(rate) * ratio))
+		if from != rate {
+			r, err := newResampler(from, rate, channels, quality)
+			if err != nil { return nil, err }
+			pcm = append(r.write(pcm), r.flush()...)
+		}
+	}
+	factor := (1 + v.Stretch) * ratio
+	if math.Abs(factor - 1) < 1e-6 { return pcm, nil }
+	return stretch(pcm, channels, factor, int(grainLength*float64(rate))),
+		nil
+}
+
+// stretch makes interleaved audio factor times longer without changing its
+// pitch, by overlap-adding Hann-windowed grains taken from the input at a
+// different pace than they're laid down.
+func stretch(in []float64, channels int, factor float64, grain int) []float64 {
+	if grain < 2 { grain = 2 }
+	frames := len(in)/channels
+	outFrames := int(float64(frames) * factor)
+	out := make([]float64, (outFrames+grain)*channels)
+	norm := make([]float64, outFrames+grain)
+	for o := 0; o < outFrames; o += grain/2 {
+		i := int(float64(o) / factor)
+		for k := 0; k < grain && i+k < frames; k++ {
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(k)/float64(grain))
+			for ch := 0; ch < channels; ch++ {
+				out[(o+k)*channels+ch] += in[(i+k)*channels+ch] * w
+			}
+			norm[o+k] += w
+		}
+	}
+	// The windows add up to one except at the very ends.
+	for i, n := range norm[:outFrames] {
+		if n < 1e-3 { continue }
+		for ch := 0; ch < channels; ch++ {
+			out[i*channels+ch] /= n
+		}
+	}
+	return out[:outFrames*channels]
+}
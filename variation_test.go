@@ -0,0 +1,78 @@
+// Test routines for the procedural variation.
+
+package woofie
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestNewVariation checks the spec parser.
+func TestNewVariation(t *testing.T) {
+	v, err := NewVariation("pitch=1, tempo=10,gain=3")
+	if err != nil { t.Fatal(err) }
+	if v != (Variation{ 1, 10, 3 }) { t.Error("Wrong variation: ", v) }
+	for _, spec := range []string{ "pitch=-1", "tempo=100", "speed=2" } {
+		_, err = NewVariation(spec)
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+	v, err = NewVariation("")
+	if err != nil { t.Fatal(err) }
+	if v.Pick(rand.New(rand.NewSource(1))) != (Variant{}) {
+		t.Error("Expected no variation by default")
+	}
+}
+
+// TestVariationPick checks the variants stay in bounds and come out the
+// same from the same seed.
+func TestVariationPick(t *testing.T) {
+	v := Variation{ 2, 10, 3 }
+	a := rand.New(rand.NewSource(42))
+	b := rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		va, vb := v.Pick(a), v.Pick(b)
+		if va != vb { t.Fatal("Same seed, different variants: ", va, vb) }
+		if math.Abs(va.Semitones) > 2 || math.Abs(va.Stretch) > 0.1 ||
+				math.Abs(va.GainDB) > 3 {
+			t.Fatal("Variant out of bounds: ", va)
+		}
+	}
+}
+
+// TestVariantApply checks the lengths come out right and a steady tone
+// stays steady.
+func TestVariantApply(t *testing.T) {
+	pcm := make([]float64, 2000)
+	for i := range pcm {
+		pcm[i] = 1000
+	}
+	tests := []struct {
+		variant Variant
+		frames int
+	}{
+		{ Variant{}, 1000 },
+		{ Variant{ Stretch: 0.5 }, 1500 },
+		{ Variant{ Semitones: 12 }, 1000 },
+		{ Variant{ Semitones: -12, Stretch: -0.2 }, 800 },
+	}
+	for _, test := range tests {
+		out, err := test.variant.apply(pcm, 1000, 2, QualityLinear)
+		if err != nil { t.Fatal(err) }
+		if math.Abs(float64(len(out)/2 - test.frames)) > 2 {
+			t.Errorf("%s: expected %d frames, got %d", test.variant,
+				test.frames, len(out)/2)
+			continue
+		}
+		for i := 100; i < len(out) - 100; i++ {
+			if math.Abs(out[i] - 1000) > 1 {
+				t.Errorf("%s: sample %d is %g", test.variant, i,
+					out[i])
+				break
+			}
+		}
+	}
+	if math.Abs(Variant{ GainDB: -6 }.gain() - 0.501) > 0.001 {
+		t.Error("Expected -6dB to be about half")
+	}
+}
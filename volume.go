@@ -0,0 +1,164 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the software volume control: a master volume, a curve
+// over the day so the dog can be quieter at night, and a volume per sound
+// set.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Volume is the volume control.  Everything is in percent, and the master
+// volume, the curve and the sound set's volume all multiply together.
+type Volume struct {
+	// Master is the overall volume.
+	Master int `json:"master"`
+	// Curve is the volume over the day, in time order (none for 100%
+	// all day).
+	Curve []VolumePoint `json:"curve,omitempty"`
+	// Sets is the volume of each sound set (100% if it isn't in here).
+	Sets map[string]int `json:"sets,omitempty"`
+}
+
+// VolumePoint is one point on the day's volume curve.  The volume slides
+// from one point to the next, wrapping round at midnight.
+type VolumePoint struct {
+	Hour int `json:"hour"`
+	Min int `json:"min"`
+	Volume int `json:"volume"`
+}
+
+// NewVolume builds a volume control from the master volume and the
+// --volumecurve and --setvolume specs.
+func NewVolume(master int, curve, sets string) (*Volume, error) {
+	ret := Volume{ Master: master }
+	var err error
+	ret.Curve, err = ParseVolumeCurve(curve)
+	if err != nil { return nil, err }
+	ret.Sets, err = ParseSetVolumes(sets)
+	if err != nil { return nil, err }
+	err = ret.Validate()
+	if err != nil { return nil, err }
+	return &ret, nil
+}
+
+// ParseVolumeCurve parses a curve like "18=100,0=60,7:30=80" (100% at 6pm,
+// sliding down to 60% by midnight and back up to 80% by 7:30am).
+func ParseVolumeCurve(spec string) ([]VolumePoint, error) {
+	settings, err := parseSettings(spec)
+	if err != nil { return nil, err }
+	ret := make([]VolumePoint, 0)
+	for t, volume := range settings {
+		hour, min, err := hhmm(t)
+		if err != nil { return nil, err }
+		ret = append(ret, VolumePoint{ hour, min, int(volume) })
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].minutes() < ret[j].minutes()
+	})
+	return ret, nil
+}
+
+// ParseSetVolumes parses per-set volumes like "growl=50,frenzy=120".
+func ParseSetVolumes(spec string) (map[string]int, error) {
+	settings, err := parseSettings(spec)
+	if err != nil { return nil, err }
+	ret := make(map[string]int)
+	for set, volume := range settings {
+		ret[set] = int(volume)
+	}
+	return ret, nil
+}
+
+// Validate checks nothing's negative.
+func (v *Volume) Validate() error {
+	if v.Master < 0 {
+		return errors.New(fmt.Sprintf("Invalid volume: %d", v.Master))
+	}
+	for _, point := range v.Curve {
+		if point.Volume < 0 {
+			return errors.New(fmt.Sprintf("Invalid volume at " +
+				"%02d:%02d: %d", point.Hour, point.Min,
+				point.Volume))
+		}
+	}
+	for set, volume := range v.Sets {
+		if volume < 0 {
+			return errors.New(fmt.Sprintf("Invalid volume for %s: " +
+				"%d", set, volume))
+		}
+	}
+	return nil
+}
+
+// minutes is how far into the day the point is.
+func (p VolumePoint) minutes() float64 {
+	return float64(p.Hour*60 + p.Min)
+}
+
+// At is the curve's volume at t.
+func (v Volume) At(t time.Time) float64 {
+	if len(v.Curve) == 0 { return 100 }
+	now := float64(t.Hour()*60 + t.Minute()) + float64(t.Second())/60
+	// Find the points either side, wrapping round midnight.
+	prev, next := v.Curve[len(v.Curve)-1], v.Curve[0]
+	for i, point := range v.Curve {
+		if point.minutes() > now {
+			next = point
+			if i > 0 { prev = v.Curve[i-1] }
+			break
+		}
+		prev = point
+		next = v.Curve[(i+1)%len(v.Curve)]
+	}
+	span := next.minutes() - prev.minutes()
+	since := now - prev.minutes()
+	if span <= 0 { span += 24*60 }
+	if since < 0 { since += 24*60 }
+	return float64(prev.Volume) +
+		float64(next.Volume - prev.Volume) * since / span
+}
+
+// Gain is what a sample from the sound set gets multiplied by at t.
+func (v Volume) Gain(t time.Time, set string) float32 {
+	gain := float64(v.Master) / 100 * v.At(t) / 100
+	if volume, ok := v.Sets[set]; ok { gain *= float64(volume) / 100 }
+	return float32(gain)
+}
+
+// CurveString gives the curve back in --volumecurve syntax.
+func (v Volume) CurveString() string {
+	points := make([]string, len(v.Curve))
+	for i, point := range v.Curve {
+		points[i] = fmt.Sprintf("%d:%02d=%d", point.Hour, point.Min,
+			point.Volume)
+	}
+	return strings.Join(points, ",")
+}
+
+// SetsString gives the sound set volumes back in --setvolume syntax.
+func (v Volume) SetsString() string {
+	sets := make([]string, 0)
+	for set, volume := range v.Sets {
+		sets = append(sets, fmt.Sprintf("%s=%d", set, volume))
+	}
+	sort.Strings(sets)
+	return strings.Join(sets, ",")
+}
+
+// String describes the volume control for the logs.
+func (v Volume) String() string {
+	ret := fmt.Sprintf("master %d%%", v.Master)
+	if len(v.Curve) != 0 { ret += ", curve " + v.CurveString() }
+	if len(v.Sets) != 0 { ret += ", sets " + v.SetsString() }
+	return ret
+}
@@ -0,0 +1,103 @@
+// Test routines for the volume control.
+
+package woofie
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestNewVolume checks the spec parsers.
+func TestNewVolume(t *testing.T) {
+	v, err := NewVolume(80, "18=100, 0=60,7:30=80", "growl=50")
+	if err != nil { t.Fatal(err) }
+	if v.CurveString() != "0:00=60,7:30=80,18:00=100" {
+		t.Error("Wrong curve: ", v.CurveString())
+	}
+	if v.SetsString() != "growl=50" { t.Error("Wrong sets: ", v.SetsString()) }
+	bad := [][]string{ { "-1", "", "" }, { "100", "25=50", "" },
+		{ "100", "12=-5", "" }, { "100", "", "growl=-1" },
+		{ "100", "noon", "" } }
+	for _, spec := range bad {
+		master := 100
+		if spec[0] == "-1" { master = -1 }
+		_, err = NewVolume(master, spec[1], spec[2])
+		if err == nil { t.Error("Expected an error for ", spec) }
+	}
+}
+
+// TestVolumeAt checks the curve slides between points and wraps round
+// midnight.
+func TestVolumeAt(t *testing.T) {
+	v, err := NewVolume(100, "18=100,0=60,6=80", "")
+	if err != nil { t.Fatal(err) }
+	tests := []struct {
+		hour, min int
+		volume float64
+	}{
+		{ 18, 0, 100 },
+		{ 21, 0, 80 },
+		{ 0, 0, 60 },
+		{ 3, 0, 70 },
+		{ 12, 0, 90 },
+	}
+	for _, test := range tests {
+		at := time.Date(2017, 1, 20, test.hour, test.min, 0, 0, time.UTC)
+		if got := v.At(at); math.Abs(got - test.volume) > 1e-9 {
+			t.Errorf("At %02d:%02d expected %v, got %v", test.hour,
+				test.min, test.volume, got)
+		}
+	}
+	if (Volume{ Master: 100 }).At(time.Now()) != 100 {
+		t.Error("Expected no curve to mean full volume")
+	}
+	v.Master = 50
+	v.Sets = map[string]int{ "growl": 50 }
+	at := time.Date(2017, 1, 20, 0, 0, 0, 0, time.UTC)
+	if got := v.Gain(at, "growl"); math.Abs(float64(got) - 0.15) > 1e-6 {
+		t.Error("Expected a gain of 0.15, got ", got)
+	}
+	if got := v.Gain(at, "woof"); math.Abs(float64(got) - 0.3) > 1e-6 {
+		t.Error("Expected a gain of 0.3, got ", got)
+	}
+}
+
+// TestSetVolume checks a runtime volume change survives a restart.
+func TestSetVolume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woofie")
+	if err != nil { t.Fatal(err) }
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	err = woofer.Persist(path)
+	if err != nil { t.Fatal(err) }
+	if woofer.SetVolume(Volume{ Master: -5 }) == nil {
+		t.Error("Expected a negative volume to fail")
+	}
+	v, err := NewVolume(40, "22=50", "")
+	if err != nil { t.Fatal(err) }
+	err = woofer.SetVolume(*v)
+	if err != nil { t.Fatal(err) }
+	if got := woofer.Status().CurrentVolume; got != 20 {
+		t.Error("Expected the current volume to be 20, got ", got)
+	}
+	err = woofer.Close()
+	if err != nil { t.Fatal(err) }
+
+	restarted := testWoofer(clock, fixedRand(0.99), 5)
+	err = restarted.Restore(path)
+	if err != nil { t.Fatal(err) }
+	err = restarted.Persist(path)
+	if err != nil { t.Fatal(err) }
+	if got := restarted.Status().Volume; got.String() != v.String() {
+		t.Error("Expected the volume override, got ", got)
+	}
+	err = restarted.Close()
+	if err != nil { t.Fatal(err) }
+}
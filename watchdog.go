@@ -0,0 +1,232 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the playback watchdog, which notices when the speaker
+// has been unplugged or its driver has hung, and brings it back when it
+// returns.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ErrSpeakerDown is what playback returns while the Watchdog has the speaker
+// down.
+var ErrSpeakerDown = errors.New("Speaker down")
+
+// reopener is a Sink that can be torn down and set up again from scratch.
+type reopener interface {
+	Reopen() error
+}
+
+// aborter is a Sink that can let go of a stuck write.
+type aborter interface {
+	Abort()
+}
+
+// Watchdog is a Sink that times every write against the length of the audio
+// in it.  A write that fails, or takes longer than its audio plus Slack,
+// puts the speaker down: everything fails with ErrSpeakerDown until Recover
+// gets it going again.  A stuck write is aborted if the sink knows how;
+// otherwise it holds up the writer until it lets go by itself, so it's
+// meant for sound cards (see PortaudioSink) rather than files or networks.
+type Watchdog struct {
+	// Out is the sink being watched.
+	Out Sink
+	// Slack is how much longer than its audio a write may take.
+	Slack time.Duration
+	// MinBackoff and MaxBackoff bound the wait between attempts to bring
+	// the speaker back, which doubles each time one fails.
+	MinBackoff, MaxBackoff time.Duration
+	// Alert is a command to run when the speaker goes down or comes back
+	// ("" for none), with WOOFIE_EVENT and WOOFIE_MESSAGE in its
+	// environment.
+	Alert string
+	clock Clock
+	// fault is why the speaker is down (nil while it's up).
+	fault error
+	// writing is closed once the write going on lets go (nil if there
+	// isn't one).
+	writing chan bool
+	// reopening gets the result of a Reopen still going (nil if there
+	// isn't one).
+	reopening chan error
+	sync.Mutex
+}
+
+// NewWatchdog watches out with the given slack.  Pass WithClock to time
+// things by something other than the wall clock.
+func NewWatchdog(out Sink, slack time.Duration, opts ...Option) *Watchdog {
+	o := buildOptions(opts)
+	return &Watchdog{ Out: out, Slack: slack, MinBackoff: time.Second,
+		MaxBackoff: time.Minute, clock: o.clock }
+}
+
+// Write implements Sink.
+func (d *Watchdog) Write(rate, channels int, buf []int32) error {
+	audio := time.Duration(float64(len(buf)/channels) / float64(rate) *
+		float64(time.Second))
+	return d.timed(audio + d.Slack, func() error {
+		return d.Out.Write(rate, channels, buf)
+	})
+}
+
+// Flush implements Sink.
+func (d *Watchdog) Flush() error {
+	return d.timed(d.Slack, d.Out.Flush)
+}
+
+// Close implements Sink.
+func (d *Watchdog) Close() error {
+	return d.Out.Close()
+}
+
+// timed runs fn, putting the speaker down if it fails or takes longer than
+// limit (and aborting it in the latter case, if the sink can).
+func (d *Watchdog) timed(limit time.Duration, fn func() error) error {
+	d.Lock()
+	if d.fault != nil {
+		d.Unlock()
+		return ErrSpeakerDown
+	}
+	writing := make(chan bool)
+	d.writing = writing
+	d.Unlock()
+	defer func() {
+		close(writing)
+		d.Lock()
+		if d.writing == writing { d.writing = nil }
+		d.Unlock()
+	}()
+	stop := d.clock.AfterFunc(limit, func() {
+		d.down(errors.New(fmt.Sprintf("Output stuck for %s", limit)))
+		if a, ok := d.Out.(aborter); ok { a.Abort() }
+	})
+	err := fn()
+	if !stop() {
+		// It was stuck, whatever it says now.
+		return d.Fault()
+	}
+	if err != nil { d.down(err) }
+	return err
+}
+
+// down records why the speaker went down.
+func (d *Watchdog) down(err error) {
+	d.Lock()
+	defer d.Unlock()
+	if d.fault == nil { d.fault = err }
+}
+
+// Fault is why the speaker is down (nil if it isn't).
+func (d *Watchdog) Fault() error {
+	d.Lock()
+	defer d.Unlock()
+	return d.fault
+}
+
+// Recover tries once to bring the speaker back, reopening the sink (which
+// finds devices all over again) if it knows how.  The speaker's only back
+// once any stuck write has let go, which it gets Slack to do (aborting it
+// should have done that already).
+func (d *Watchdog) Recover() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.fault == nil { return nil }
+	if r, ok := d.Out.(reopener); ok {
+		if d.reopening == nil {
+			d.reopening = make(chan error, 1)
+			go func(done chan error) { done <- r.Reopen() }(d.reopening)
+		}
+		select {
+			case err := <-d.reopening:
+				d.reopening = nil
+				if err != nil { return err }
+			case <-d.clock.After(d.Slack):
+				return errors.New("Output still stuck")
+		}
+	}
+	if d.writing != nil {
+		select {
+			case <-d.writing:
+			case <-d.clock.After(d.Slack):
+				return errors.New("Output still stuck")
+		}
+	}
+	d.fault = nil
+	return nil
+}
+
+// alert runs the Alert command for an event in the background.
+func (d *Watchdog) alert(e Event) {
+	if d.Alert == "" { return }
+	cmd := exec.Command("/bin/sh", "-c", d.Alert)
+	cmd.Env = append(os.Environ(), "WOOFIE_EVENT=" + e.Kind,
+		"WOOFIE_MESSAGE=" + e.Message)
+	go func() {
+		err := cmd.Run()
+		if err != nil {
+			logger.Printf("Alert command failed: %s\n", err.Error())
+		}
+	}()
+}
+
+// supervise looks after the speaker while the Watchdog has it down: it
+// publishes EventSpeakerDown the first time, then tries to bring it back
+// with backoff, publishing EventSpeakerUp once it's back.  It returns
+// false if the speaker isn't down.
+func (w *Woofer) supervise(ctx context.Context) bool {
+	d := w.Watchdog
+	if d == nil { return false }
+	fault := d.Fault()
+	if fault == nil { return false }
+	w.Lock()
+	first := !w.speakerDown
+	w.speakerDown = true
+	if first { w.backoff = d.MinBackoff }
+	backoff := w.backoff
+	w.Unlock()
+	if first {
+		msg := fmt.Sprintf("Speaker down: %s", fault.Error())
+		logger.Println(msg)
+		e := Event{ Kind: EventSpeakerDown, Message: msg }
+		w.publish(e)
+		d.alert(e)
+	}
+
+	// Triggers can wait: there's no barking without a speaker.
+	select {
+		case <-ctx.Done():
+			return true
+		case <-w.Clock.After(backoff):
+	}
+	err := d.Recover()
+	if err != nil {
+		backoff *= 2
+		if backoff > d.MaxBackoff { backoff = d.MaxBackoff }
+	}
+	w.Lock()
+	w.speakerDown = err != nil
+	w.backoff = backoff
+	w.Unlock()
+	if err != nil {
+		logger.Printf("Speaker still down: %s, trying again in %s\n",
+			err.Error(), backoff)
+		return true
+	}
+	msg := "Speaker back up"
+	logger.Println(msg)
+	e := Event{ Kind: EventSpeakerUp, Message: msg }
+	w.publish(e)
+	d.alert(e)
+	return true
+}
@@ -0,0 +1,172 @@
+// Test routines for the playback watchdog.
+
+package woofie
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakySink fails while it's broken and hangs while it's held.
+type flakySink struct {
+	broken, closed bool
+	hold chan bool
+	sync.Mutex
+}
+
+func (f *flakySink) Write(rate, channels int, buf []int32) error {
+	f.Lock()
+	broken, hold := f.broken, f.hold
+	f.Unlock()
+	if hold != nil { <-hold }
+	if broken { return errors.New("Device unplugged") }
+	return nil
+}
+
+func (f *flakySink) Flush() error { return nil }
+
+func (f *flakySink) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	f.closed = true
+	return nil
+}
+
+// set breaks or fixes the sink.
+func (f *flakySink) set(broken bool) {
+	f.Lock()
+	f.broken = broken
+	f.Unlock()
+}
+
+// TestWatchdogStuck checks a write that hangs puts the speaker down until
+// it lets go, and hangs on until then itself.
+func TestWatchdogStuck(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	out := &flakySink{ hold: make(chan bool) }
+	d := NewWatchdog(out, 20*time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- d.Write(1000, 1, make([]int32, 10)) }()
+	for i := 0; d.Fault() == nil; i++ {
+		if i == 1000 { t.Fatal("Expected the speaker to go down") }
+		time.Sleep(time.Millisecond)
+	}
+	if d.Write(1000, 1, make([]int32, 10)) != ErrSpeakerDown {
+		t.Error("Expected writes to fail while the speaker's down")
+	}
+	if d.Recover() == nil {
+		t.Error("Expected no recovery while the write is stuck")
+	}
+	close(out.hold)
+	if <-done == nil { t.Error("Expected the stuck write to fail") }
+	err := d.Recover()
+	if err != nil { t.Fatal("Expected the speaker to come back, got ", err) }
+	err = d.Write(1000, 1, make([]int32, 10))
+	if err != nil { t.Error("Expected writes to work again, got ", err) }
+}
+
+// reopenSink is a flakySink that can be aborted, letting go of a held write
+// the way aborting a stream would, and reopened.
+type reopenSink struct {
+	flakySink
+	reopens int
+}
+
+func (r *reopenSink) Abort() {
+	r.Lock()
+	defer r.Unlock()
+	if r.hold != nil {
+		close(r.hold)
+		r.hold = nil
+	}
+}
+
+func (r *reopenSink) Reopen() error {
+	r.Lock()
+	defer r.Unlock()
+	r.reopens++
+	return nil
+}
+
+// TestWatchdogReopen checks a stuck write is aborted, and the sink reopened
+// exactly once to bring it back, and not again once it's up.
+func TestWatchdogReopen(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	out := &reopenSink{ flakySink: flakySink{ hold: make(chan bool) } }
+	d := NewWatchdog(out, 20*time.Millisecond)
+	err := d.Write(1000, 1, make([]int32, 10))
+	if err == nil || d.Fault() == nil {
+		t.Fatal("Expected a stuck write to put the speaker down")
+	}
+	err = d.Recover()
+	if err != nil { t.Fatal("Expected the reopen to recover, got ", err) }
+	err = d.Recover()
+	if err != nil { t.Error("Expected nothing to recover, got ", err) }
+	err = d.Write(1000, 1, make([]int32, 10))
+	if err != nil { t.Error("Expected writes to work again, got ", err) }
+	out.Lock()
+	reopens := out.reopens
+	out.Unlock()
+	if reopens != 1 { t.Error("Expected 1 reopen, got ", reopens) }
+}
+
+// TestWatchdogClose checks the sink gets closed even while the speaker's
+// down.
+func TestWatchdogClose(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	out := &flakySink{ broken: true }
+	d := NewWatchdog(out, 20*time.Millisecond)
+	if d.Write(1000, 1, make([]int32, 10)) == nil {
+		t.Fatal("Expected a failed write to put the speaker down")
+	}
+	d.Close()
+	if !out.closed { t.Error("Expected the sink to be closed") }
+}
+
+// TestSupervise checks the Player raises the alarm when the speaker fails
+// and carries on once it's back.
+func TestSupervise(t *testing.T) {
+	out := &flakySink{ broken: true }
+	rng := fixedRand(0.99)
+	d := NewWatchdog(out, 20*time.Millisecond)
+	d.MinBackoff = time.Millisecond
+	d.MaxBackoff = 4*time.Millisecond
+	sounds := &Sounds{ Samples: []*Sound{ cachedSound(1) }, Sink: d,
+		rand: rng }
+	woofer := NewWoofer(sounds, &Schedules{}, log.New(ioutil.Discard, "", 0),
+		15, 30, 150, 5, WithRand(rng))
+	woofer.Watchdog = d
+	events := woofer.Events.Subscribe(64)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := woofer.Player(ctx)
+	woofer.WoofOn()
+
+	timeout := time.After(5*time.Second)
+	for _, kind := range []string{ EventSpeakerDown, EventSpeakerUp } {
+		for seen := false; !seen; {
+			select {
+				case e := <-events:
+					seen = e.Kind == kind
+				case <-timeout:
+					t.Fatal("Never saw ", kind)
+			}
+		}
+		if kind == EventSpeakerDown {
+			if !woofer.Status().SpeakerDown {
+				t.Error("Expected the status to say so")
+			}
+			out.set(false)
+		}
+	}
+	if woofer.Status().SpeakerDown {
+		t.Error("Expected the speaker back up")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,163 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the WAV decoder, for 8/16/24/32-bit integer and 32-bit
+// float PCM.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// The WAV sample formats we handle.
+const (
+	wavPCM = 1
+	wavFloat = 3
+	wavExtensible = 0xfffe
+)
+
+// wavDecoder reads the data chunk of a WAV file.
+type wavDecoder struct {
+	file *os.File
+	info Info
+	bits int
+	float bool
+	// blockAlign is the size of a frame in bytes.
+	blockAlign int
+	// remaining is the number of bytes of audio left.
+	remaining int64
+}
+
+// openWav starts decoding a WAV file, skipping to its data chunk.
+func openWav(path string) (decoder, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	d, err := newWavDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// newWavDecoder reads the chunks up to the audio.
+func newWavDecoder(f *os.File) (*wavDecoder, error) {
+	d := wavDecoder{ file: f }
+	d.info.Format = "wav"
+	header := make([]byte, 12)
+	_, err := io.ReadFull(f, header)
+	if err != nil { return nil, err }
+	code := 0
+	for {
+		_, err = io.ReadFull(f, header[:8])
+		if err != nil {
+			return nil, errors.New("No data chunk")
+		}
+		id := string(header[:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		if id == "data" {
+			if code == 0 { return nil, errors.New("No fmt chunk") }
+			// Streamed WAVs may not know their size.
+			pos, err := f.Seek(0, io.SeekCurrent)
+			if err != nil { return nil, err }
+			stat, err := f.Stat()
+			if err != nil { return nil, err }
+			if size == 0 || size > stat.Size()-pos {
+				size = stat.Size()-pos
+			}
+			d.remaining = size - size % int64(d.blockAlign)
+			d.info.NSamples = d.remaining / int64(d.blockAlign)
+			break
+		}
+		if id != "fmt " {
+			_, err = f.Seek(size + size%2, io.SeekCurrent)
+			if err != nil { return nil, err }
+			continue
+		}
+		if size < 16 { return nil, errors.New("Short fmt chunk") }
+		fmtChunk := make([]byte, size + size%2)
+		_, err = io.ReadFull(f, fmtChunk)
+		if err != nil { return nil, err }
+		code = int(binary.LittleEndian.Uint16(fmtChunk[0:]))
+		d.info.NChannels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+		d.info.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+		d.blockAlign = int(binary.LittleEndian.Uint16(fmtChunk[12:]))
+		d.bits = int(binary.LittleEndian.Uint16(fmtChunk[14:]))
+		if code == wavExtensible && size >= 26 {
+			code = int(binary.LittleEndian.Uint16(fmtChunk[24:]))
+		}
+		switch {
+			case code == wavPCM && (d.bits == 8 || d.bits == 16 ||
+					d.bits == 24 || d.bits == 32):
+			case code == wavFloat && d.bits == 32:
+				d.float = true
+			default:
+				return nil, errors.New(fmt.Sprintf("Unsupported " +
+					"format %d (%d-bit)", code, d.bits))
+		}
+		if d.info.NChannels == 0 || d.info.SampleRate == 0 ||
+				d.blockAlign != d.info.NChannels*d.bits/8 {
+			return nil, errors.New("Bad fmt chunk")
+		}
+	}
+	return &d, nil
+}
+
+// Info implements decoder.
+func (d *wavDecoder) Info() Info {
+	return d.info
+}
+
+// Read implements decoder.
+func (d *wavDecoder) Read() ([]int32, error) {
+	if d.remaining == 0 { return nil, io.EOF }
+	size := int64(chunkFrames*d.blockAlign)
+	if size > d.remaining { size = d.remaining }
+	raw := make([]byte, size)
+	n, err := io.ReadFull(d.file, raw)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		// A truncated file just ends early.
+		d.remaining = 0
+		err = nil
+	} else if err != nil {
+		return nil, err
+	} else {
+		d.remaining -= size
+	}
+	raw = raw[:n - n % d.blockAlign]
+	if len(raw) == 0 { return nil, io.EOF }
+	width := d.bits / 8
+	ret := make([]int32, len(raw)/width)
+	for i := range ret {
+		b := raw[i*width:]
+		switch {
+			case d.float:
+				ret[i] = floatToPCM([]float32{ math.Float32frombits(
+					binary.LittleEndian.Uint32(b)) })[0]
+			case width == 1:
+				ret[i] = (int32(b[0]) - 128) << 24
+			case width == 2:
+				ret[i] = int32(int16(
+					binary.LittleEndian.Uint16(b))) << 16
+			case width == 3:
+				ret[i] = int32(uint32(b[0]) << 8 |
+					uint32(b[1]) << 16 | uint32(b[2]) << 24)
+			default:
+				ret[i] = int32(binary.LittleEndian.Uint32(b))
+		}
+	}
+	return ret, err
+}
+
+// Close implements decoder.
+func (d *wavDecoder) Close() error {
+	return d.file.Close()
+}
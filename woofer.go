@@ -9,8 +9,11 @@
 package woofie
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
+	"math"
 	"sync"
 	"time"
 )
@@ -24,6 +27,10 @@ var logger *log.Logger
 type Woofer struct {
 	// WoofLog is any record of woofs for the last hour.
 	WoofLog []time.Time
+	// woofZones is the zone of each woof in WoofLog.
+	woofZones []string
+	// WoofStart is the time at which we start woofing (after reacting).
+	WoofStart time.Time
 	// WoofUntil is the time at which we will stop woofing.
 	WoofUntil time.Time
 	// WoofSamples is the pile of available preloaded sample files.
@@ -36,18 +43,166 @@ type Woofer struct {
 	Horizon int
 	// Score is the maximum score we can reach before shutting up.
 	Score int
+	// Scoring is the --scoring spec that Scorer was built from.
+	Scoring string
+	// Scorer decides from the log whether we're too tired to bark.
+	Scorer Scorer
 	// RandomFactor is the % possibility that we might ignore the
 	// log and bark anyway.
 	RandomFactor float32
+	// Escalation tracks how worked up the dog is.
+	Escalation *Escalation
+	// ReactionDelay is how long it takes to start barking.
+	ReactionDelay time.Duration
+	// PauseMin and PauseMax bound the random pause between barks.
+	PauseMin, PauseMax time.Duration
+	// Sequencer is how the barks follow on from one another, and how
+	// the pauses between them are drawn.
+	Sequencer Sequencer
+	// PreferredSounds is the sound sets to fall back on when the current
+	// level doesn't have one.
+	PreferredSounds []string
+	// Profile is the name of the personality in use ("" if none).
+	Profile string
+	// Profiles is the personalities SetProfile can pick from.
+	Profiles Profiles
+	// Zones is the per-zone setup (sensitivity and scoring).
+	Zones Zones
+	// ZoneBudget is how zone and global fatigue combine (BudgetBoth,
+	// BudgetZone or BudgetGlobal).
+	ZoneBudget string
+	// ZoneScoring is the scoring spec for zones without their own ("" to
+	// score them like the global log).
+	ZoneScoring string
+	// zoneLogs is the bark log of each zone.
+	zoneLogs map[string][]time.Time
+	// Armed is false while the dog has been told to ignore triggers.
+	Armed bool
+	// SnoozeUntil is when a snooze (a temporary disarm) runs out.
+	SnoozeUntil time.Time
+	// statePath is the file the state is persisted to ("" for none).
+	statePath string
+	// save nudges the state saver, which closes saved when it's done.
+	save, saved chan bool
+	// overrides is what's been changed at runtime, for the state file.
+	overrides Overrides
+	// Events is where everything interesting gets published.
+	Events *EventBus
+	// Clock is where the Woofer gets its time.
+	Clock Clock
+	// Rand is where the Woofer gets its whims.
+	Rand Rand
+	// wake nudges the player when something changes.
+	wake chan bool
+	// Mixer plays several dogs at once, each placed by its zone, and
+	// varied or run through effects (nil to play one dog as it is).
+	Mixer *Mixer
+	// Voices is the most dogs that bark at once with a Mixer.
+	Voices int
+	// VoiceOffset is the most the other dogs start after the first.
+	VoiceOffset time.Duration
+	// VoiceGain is the quietest the other dogs get, relative to the first.
+	VoiceGain float32
+	// Volume is the volume control.
+	Volume *Volume
+	// Variation is how much each bark gets varied.
+	Variation Variation
+	// Effects is the effect chain for zones without their own (nil for
+	// none).
+	Effects *Effects
+	// zone is the zone of the trigger that last authorized barking.
+	zone string
+	// Watchdog is the sink the barks play through, if it's watched for a
+	// speaker that's gone away (nil if not).
+	Watchdog *Watchdog
+	// speakerDown is true while the Watchdog has the speaker down, and
+	// backoff how long until the next attempt to bring it back.
+	speakerDown bool
+	backoff time.Duration
+	// FadeOut is how long interrupted playback takes to fade out.
+	FadeOut time.Duration
+	// stopPlaying interrupts whatever is playing (nil if nothing is).
+	stopPlaying context.CancelCauseFunc
+	// interrupts counts the interruptions, so playback decided on before
+	// one can tell it's been called off, and interrupted is the latest
+	// one's reason.
+	interrupts int
+	interrupted string
+	// playLock keeps the player and one-off PlaySound calls from talking
+	// over each other.
+	playLock sync.Mutex
 	sync.Mutex
 }
 
-// NewWoofer initializes a new player and gets it ready to start.
+// Parameters is the set of runtime-tunable knobs (same units as the
+// command line).
+type Parameters struct {
+	Resolution int
+	Horizon int
+	Score int
+	Factor int
+	// Scoring is the fatigue-scoring spec (see NewScorer).
+	Scoring string
+}
+
+// Status is a snapshot of what the Woofer is up to.
+type Status struct {
+	// Barking is true while the bark cycle is running.
+	Barking bool
+	// Quiet is true while the schedule keeps us quiet.
+	Quiet bool
+	// WoofUntil is when the current bark cycle ends.
+	WoofUntil time.Time
+	// LastBark is the most recent authorized bark (zero if none).
+	LastBark time.Time
+	// LogSize is the number of barks still in the log.
+	LogSize int
+	// Score is the current fatigue score.
+	Score int
+	// Level is the current escalation level.
+	Level string
+	// Zones is the fatigue of each zone that barked lately.
+	Zones []ZoneStatus
+	// Armed is false while the dog ignores triggers.
+	Armed bool
+	// SnoozeUntil is when the current snooze runs out (zero if none).
+	SnoozeUntil time.Time
+	// Profile is the personality in use.
+	Profile string
+	// Parameters is the current set of knobs.
+	Parameters Parameters
+	// Schedule is the quiet schedule as Schedules.Dump prints it.
+	Schedule string
+	// Sounds is the names of the available samples.
+	Sounds []string
+	// Trimmed is how much silence was trimmed off each sample that had
+	// any.
+	Trimmed map[string]time.Duration
+	// Cache is how the decoded sample cache is doing (zero if there
+	// isn't one).
+	Cache CacheStats
+	// Volume is the volume control's setup.
+	Volume Volume
+	// CurrentVolume is the master volume times the curve right now, in
+	// percent.
+	CurrentVolume int
+	// SpeakerDown is true while the speaker isn't working.
+	SpeakerDown bool
+}
+
+// NewWoofer initializes a new player and gets it ready to start.  Pass
+// WithClock/WithRand to run on something other than the wall clock and a
+// time-seeded RNG.  It always starts as a fresh dog: to pick up a state file,
+// call Restore once it's set up, then Persist to keep the file up to date.
 func NewWoofer(sounds *Sounds, schedule *Schedules, mainlogger *log.Logger,
-		resolution, horizon, score, factor int) *Woofer {
+		resolution, horizon, score, factor int, opts ...Option) *Woofer {
 	ret := Woofer{}
+	o := buildOptions(opts)
+	ret.Clock = o.clock
+	ret.Rand = o.rand
 	ret.WoofLog = make([]time.Time, 1)
 	ret.WoofLog[0] = time.Time{}
+	ret.woofZones = make([]string, 1)
 	ret.WoofUntil = time.Time{}
 	ret.WoofSamples = sounds
 	ret.WoofSchedule = schedule
@@ -55,90 +210,674 @@ func NewWoofer(sounds *Sounds, schedule *Schedules, mainlogger *log.Logger,
 	ret.Horizon = horizon
 	ret.Score = score
 	ret.RandomFactor = float32(factor) / 100.0
+	ret.Scoring = "linear"
+	ret.Scorer, _ = NewScorer(ret.Scoring, horizon, score)
+	ret.Escalation, _ = NewEscalation(DefaultLevels, "")
+	ret.Profiles = DefaultProfiles()
+	ret.Zones = Zones{}
+	ret.ZoneBudget = BudgetBoth
+	ret.zoneLogs = make(map[string][]time.Time)
+	ret.Armed = true
+	ret.wake = make(chan bool, 1)
+	ret.Volume = &Volume{ Master: 100 }
+	ret.Sequencer = Sequencer{ Mode: SequenceOff,
+		Pauses: Pauses{ Shape: PausesUniform } }
+	ret.Mixer = &Mixer{ Quality: QualityCubic }
+	ret.Voices = 1
+	ret.VoiceOffset = 500*time.Millisecond
+	ret.VoiceGain = 0.6
+	ret.FadeOut = 50*time.Millisecond
+	ret.Events = NewEventBus()
 	logger = mainlogger
 	logger.Printf("Woofer initialized with %d available sounds\n",
-		len(*sounds))
+		len(sounds.Samples))
 	return &ret
 }
 
-// Player runs a singleton goroutine to wake up periodically and play sound
-// if it's appropriate to do so.
-func (w *Woofer) Player() {
+// Player runs a singleton goroutine that plays sound whenever it's
+// appropriate to do so.  It sleeps until something changes (WoofOn wakes it
+// right away) and stops once ctx is done, closing the returned channel.
+func (w *Woofer) Player(ctx context.Context) chan bool {
+	done := make(chan bool)
 	go func() {
-		for true {
-			// Stay quiet if we're in the right time to do so.
-			if w.WoofSchedule.InSchedules(time.Now()) {
-				time.Sleep(time.Second)
-			} else {
-				playWoof := false
-				// Keep the exclusive lock short.
-				w.Lock()
-				playWoof = w.WoofUntil.After(time.Now())
-				w.Unlock()
-				if playWoof {
-					err := w.WoofSamples.PlayRandom()
-					if err != nil {
-						logger.Println(err)
-						time.Sleep(time.Second)
-					}
-				} else {
-					time.Sleep(time.Second)
+		defer close(done)
+		for ctx.Err() == nil {
+			// Nothing can play until the speaker's back.
+			if w.supervise(ctx) { continue }
+			// Keep the exclusive lock short.
+			w.Lock()
+			now := w.Clock.Now()
+			quiet := w.WoofSchedule.InSchedules(now)
+			woofUntil := w.WoofUntil
+			playWoof := woofUntil.After(now)
+			react := w.WoofStart.Sub(now)
+			level := w.Escalation.Current(now)
+			sets := append([]string{ level.Sounds },
+				w.PreferredSounds...)
+			zone := w.Zones.Get(w.zone)
+			pause := w.pause()
+			sequenced := w.Sequencer.Mode != SequenceOff
+			gen := w.interrupts
+			w.Unlock()
+			if quiet && playWoof {
+				// Stay quiet if we're in the right time to do
+				// so, but look again when the schedule might.
+				next := now.Truncate(time.Minute).Add(
+					time.Minute).Sub(now)
+				if until := woofUntil.Sub(now); until < next {
+					next = until
 				}
+				w.wait(ctx, next)
+			} else if playWoof && react > 0 {
+				// Still making up our mind.
+				w.wait(ctx, react)
+			} else if playWoof && sequenced {
+				err := w.sequence(ctx, gen)
+				if err != nil {
+					logger.Println(err)
+					w.publish(Event{ Kind: EventError,
+						Message: err.Error() })
+					w.wait(ctx, time.Second)
+				}
+			} else if playWoof {
+				err := w.playFrom(ctx, sets, level.Gain(), zone,
+					gen)
+				if err != nil {
+					logger.Println(err)
+					w.publish(Event{ Kind: EventError,
+						Message: err.Error() })
+					w.wait(ctx, time.Second)
+				} else if pause > 0 {
+					w.wait(ctx, pause)
+				}
+			} else {
+				// Nothing to do until somebody says so.
+				w.wait(ctx, 0)
 			}
 		}
 	}()
+	return done
+}
+
+// playFrom plays a random sound from the sets (or, with a Mixer, a few dogs'
+// worth) as if from the zone, cutting it short if quiet time starts.  gen is
+// the interrupt count when it was decided on (see playing).
+func (w *Woofer) playFrom(ctx context.Context, sets []string, gain float32,
+		zone Zone, gen int) error {
+	voices, err := w.voices(sets, gain, zone)
+	if err != nil { return err }
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	go w.quietWatch(ctx, stop)
+	return w.play(ctx, voices, gen)
+}
+
+// voices picks the dogs for a bark.  The first is at the given gain and the
+// zone's placement; with a Mixer, up to Voices-1 others join in a little
+// later, quieter and (unless the zone has a speaker of its own) off to one
+// side.  Each gets its own Variation, and all of them the zone's Effects.
+func (w *Woofer) voices(sets []string, gain float32,
+		zone Zone) ([]Voice, error) {
+	sound, err := w.WoofSamples.PickFrom(sets)
+	if err != nil { return nil, err }
+	w.Lock()
+	defer w.Unlock()
+	placement := zone.Placement()
+	effects := w.Effects
+	if zone.Effects != "" { effects = zone.effects }
+	now := w.Clock.Now()
+	voices := []Voice{ { sound, gain * w.Volume.Gain(now, sound.Category()),
+		0, placement, w.Variation.Pick(w.Rand), effects } }
+	if w.Mixer == nil || w.Voices < 2 { return voices, nil }
+	for n := w.Rand.Intn(w.Voices); n > 0; n-- {
+		sound, err := w.WoofSamples.PickFrom(sets)
+		if err != nil { return nil, err }
+		voice := Voice{ sound, gain * w.Volume.Gain(now,
+			sound.Category()), 0, placement,
+			w.Variation.Pick(w.Rand), effects }
+		voice.Gain *= w.VoiceGain + (1 - w.VoiceGain)*w.Rand.Float32()
+		voice.Offset = time.Duration(w.Rand.Float32() *
+			float32(w.VoiceOffset))
+		if placement.Channel == 0 {
+			pan := placement.Pan + float64(w.Rand.Float32()) - 0.5
+			voice.Placement.Pan = math.Max(-1, math.Min(1, pan))
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// quietWatch interrupts playback through stop if quiet time starts before
+// ctx is done.  The schedule works in minutes, so that's how often it looks.
+func (w *Woofer) quietWatch(ctx context.Context,
+		stop context.CancelCauseFunc) {
+	for {
+		now := w.Clock.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+			case <-ctx.Done():
+				return
+			case <-w.Clock.After(next.Sub(now)):
+		}
+		w.Lock()
+		quiet := w.WoofSchedule.InSchedules(w.Clock.Now())
+		w.Unlock()
+		if quiet {
+			stop(errors.New("Quiet time"))
+			return
+		}
+	}
+}
+
+// play plays the voices where interrupt can get at it, publishing an event
+// if they get cut short.
+func (w *Woofer) play(ctx context.Context, voices []Voice, gen int) error {
+	return w.playing(ctx, voiceNames(voices), gen, func(ctx context.Context,
+			out Sink, mixer *Mixer, fade time.Duration) error {
+		if mixer != nil && (len(voices) > 1 || voices[0].Variant !=
+				(Variant{}) || voices[0].Effects != nil ||
+				voices[0].Placement != (Placement{})) {
+			return mixer.Play(ctx, out, voices, fade)
+		}
+		// One dog as it is doesn't need mixing.
+		return voices[0].Sound.PlayContext(ctx, out, voices[0].Gain, fade)
+	})
+}
+
+// playing runs fn (which plays what on out, through the Mixer if there is
+// one) where interrupt can get at it, publishing an event if it gets cut
+// short.  gen is the interrupt count when the playing was decided on: if
+// something's been interrupted since (while it waited its turn, say), it
+// was called off too, and fn never runs.
+func (w *Woofer) playing(ctx context.Context, what string, gen int,
+		fn func(ctx context.Context, out Sink, mixer *Mixer,
+			fade time.Duration) error) error {
+	w.playLock.Lock()
+	defer w.playLock.Unlock()
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	w.Lock()
+	if w.interrupts != gen {
+		msg := fmt.Sprintf("Skipped %s: %s", what, w.interrupted)
+		w.Unlock()
+		logger.Println(msg)
+		w.publish(Event{ Kind: EventInterrupted, Message: msg })
+		return nil
+	}
+	w.stopPlaying = stop
+	fade := w.FadeOut
+	mixer := w.Mixer
+	w.Unlock()
+	out := w.WoofSamples.out()
+	err := fn(ctx, out, mixer, fade)
+	w.Lock()
+	w.stopPlaying = nil
+	w.Unlock()
+	if err == ErrInterrupted {
+		msg := fmt.Sprintf("Interrupted %s: %s", what,
+			context.Cause(ctx).Error())
+		logger.Println(msg)
+		w.publish(Event{ Kind: EventInterrupted, Message: msg })
+		return nil
+	}
+	return err
+}
+
+// interrupt cuts off whatever is playing, and anything already decided on
+// that hasn't started yet.  The caller must hold the lock.
+func (w *Woofer) interrupt(reason string) {
+	w.interrupts++
+	w.interrupted = reason
+	if w.stopPlaying != nil { w.stopPlaying(errors.New(reason)) }
+}
+
+// wait sleeps for d (forever if d is 0) or until the player is woken up or
+// stopped, whichever comes first.
+func (w *Woofer) wait(ctx context.Context, d time.Duration) {
+	var timeout <-chan time.Time
+	if d != 0 { timeout = w.Clock.After(d) }
+	select {
+		case <-ctx.Done():
+		case <-w.wake:
+		case <-timeout:
+	}
+}
+
+// wakeup nudges the player to look at things again.
+func (w *Woofer) wakeup() {
+	select {
+		case w.wake <- true:
+		default:
+	}
+}
+
+// pause picks how long to catch our breath between barks.  The caller must
+// hold the lock.
+func (w *Woofer) pause() time.Duration {
+	return w.Sequencer.Pauses.Pick(w.Rand, w.PauseMin, w.PauseMax)
+}
+
+// publish stamps an event with the Woofer's clock and sends it out.
+func (w *Woofer) publish(e Event) {
+	e.Time = w.Clock.Now()
+	w.Events.Publish(e)
 }
 
 // WoofOn receives a signal from the server, vacuums the log, and may signal
 // the player to play a woof if appropriate.
 func (w *Woofer) WoofOn() {
+	w.WoofOnFrom(Sensor{})
+}
+
+// WoofOnFrom is WoofOn on behalf of a particular sensor.  It reports whether
+// the bark was authorized along with the score that decided it.
+func (w *Woofer) WoofOnFrom(sensor Sensor) (bool, int) {
 	w.Lock()
 	defer w.Unlock()
-	// Hoover the log.  Remove anything more than an hour old.
-	if len(w.WoofLog) != 0 {
-		for len(w.WoofLog) > 0 && time.Since(w.WoofLog[0]).Hours() > 1 {
-			w.WoofLog = w.WoofLog[1:]
-		}
+	defer w.changed()
+	now := w.Clock.Now()
+	w.publish(Event{ Kind: EventOn, Sensor: sensor,
+		Message: "Received on request" })
+	if !w.Armed || w.SnoozeUntil.After(now) {
+		logger.Println("Disarmed; ignoring on request")
+		w.publish(Event{ Kind: EventSuppressed, Sensor: sensor,
+			Message: "Disarmed" })
+		return false, 0
+	}
+	zone := w.Zones.Get(sensor.ZoneName())
+	if zone.Sensitivity == 0 {
+		logger.Printf("Ignoring zone '%s'\n", zone.Name)
+		w.publish(Event{ Kind: EventSuppressed, Sensor: sensor,
+			Message: "Ignoring zone" })
+		return false, 0
+	}
+	woofScore := w.logScore()
+	zoneScorer, zoneScore := w.zoneScore(zone)
+	// Only a bark moves the dog up the ladder; triggers it's too tired
+	// for don't work it up.
+	level := w.Escalation.Next(now)
+	duration := w.Resolution*time.Second
+	if level.Duration != 0 {
+		duration = time.Duration(level.Duration)*time.Second
 	}
+	// If we're already barking we just keep going; otherwise it takes
+	// a moment to react.
+	start := now
+	if !w.WoofUntil.After(now) { start = now.Add(w.ReactionDelay) }
 	// Score the log.  If the score exceeds the max, we shut up (clearly
 	// the barking doesn't work, so no point annoying the neighbors).
+	// Depending on the budget, that's the whole log, the zone's, or both.
+	allowed := w.Scorer.Allow(woofScore)
+	switch w.ZoneBudget {
+		case BudgetZone:
+			allowed = zoneScorer.Allow(zoneScore)
+			woofScore = zoneScore
+		case BudgetBoth:
+			woofScore = w.sharedScore(zone)
+			allowed = w.Scorer.Allow(woofScore) &&
+				zoneScorer.Allow(zoneScore)
+	}
 	if len(w.WoofLog) != 0 {
-		// Having a map here makes sure we don't count the same minute
-		// delta multiple times.
-		scoreMap := make(map[int]int)
-		for _, t := range w.WoofLog {
-			delta := int(time.Since(t).Minutes())
-			if delta < w.Horizon {
-				scoreMap[delta] = w.Horizon - delta
-			}
-		}
-		woofScore := 0
-		for _, score := range scoreMap {
-			woofScore += score
-		}
 		// We might (just as a real dog would) ignore the log's
 		// command and bark anyway.
-		if (woofScore < w.Score) || (rand.Float32() < w.RandomFactor) {
-			w.WoofUntil = time.Now().Add(w.Resolution*time.Second)
-			w.WoofLog = append(w.WoofLog, time.Now())
-			logger.Printf("Authorizing bark at score=%d\n",
-				woofScore)
+		if allowed || (w.Rand.Float32() < w.RandomFactor) {
+			if start != now { w.WoofStart = start }
+			w.WoofUntil = start.Add(duration)
+			w.Escalation.Trigger(now)
+			w.zone = zone.Name
+			w.logWoof(zone, now)
+			w.wakeup()
+			logger.Printf("Authorizing bark at score=%.1f " +
+				"(zone '%s' score=%.1f, level=%s)\n", woofScore,
+				zone.Name, zoneScore, level.Name)
+			w.publish(Event{ Kind: EventAuthorized,
+				Sensor: sensor, Score: int(woofScore),
+				Level: level.Name,
+				Message: "Authorizing bark" })
+			return true, int(woofScore)
 		} else {
+			level = w.Escalation.Current(now)
 			logger.Printf("Too much barking; shutting up for " +
-				"a while (score=%d)\n", woofScore)
+				"a while (score=%.1f, zone '%s' score=%.1f, " +
+				"level=%s)\n", woofScore, zone.Name, zoneScore,
+				level.Name)
+			w.publish(Event{ Kind: EventSuppressed,
+				Sensor: sensor, Score: int(woofScore),
+				Level: level.Name,
+				Message: "Too much barking" })
+			return false, int(woofScore)
 		}
 	} else {
 		// No log yet, so we go no matter what.
-		w.WoofUntil = time.Now().Add(w.Resolution*time.Second)
-		w.WoofLog = append(w.WoofLog, time.Now())
-		logger.Println("Started fresh bark cycle")
+		w.WoofStart = start
+		w.WoofUntil = start.Add(duration)
+		w.Escalation.Trigger(now)
+		w.zone = zone.Name
+		w.logWoof(zone, now)
+		w.wakeup()
+		logger.Printf("Started fresh bark cycle (level=%s)\n",
+			level.Name)
+		w.publish(Event{ Kind: EventAuthorized, Sensor: sensor,
+			Level: level.Name,
+			Message: "Started fresh bark cycle" })
+		return true, 0
 	}
 }
 
+// logScore vacuums the log and totals up how annoying we've been lately.
+// The caller must hold the lock.
+func (w *Woofer) logScore() float64 {
+	// Hoover the log.  Remove anything the scorer no longer cares about.
+	retention := w.Scorer.Retention()
+	now := w.Clock.Now()
+	for len(w.WoofLog) > 0 && now.Sub(w.WoofLog[0]) > retention {
+		w.WoofLog = w.WoofLog[1:]
+		w.woofZones = w.woofZones[1:]
+	}
+	return w.Scorer.Score(w.WoofLog, now)
+}
+
+// logWoof adds a bark from the zone to the global log and the zone's.  The
+// caller must hold the lock.
+func (w *Woofer) logWoof(zone Zone, now time.Time) {
+	w.WoofLog = append(w.WoofLog, now)
+	w.woofZones = append(w.woofZones, zone.Name)
+	w.zoneLogs[zone.Name] = append(w.zoneLogs[zone.Name], now)
+}
+
 // WoofOff disables the player in response to the client.
 func (w *Woofer) WoofOff() {
+	w.WoofOffFrom(Sensor{})
+}
+
+// WoofOffFrom is WoofOff on behalf of a particular sensor.
+func (w *Woofer) WoofOffFrom(sensor Sensor) {
 	w.Lock()
-	w.WoofUntil=time.Now()
+	w.WoofUntil=w.Clock.Now()
+	w.interrupt("Explicit off")
+	w.changed()
+	w.wakeup()
 	w.Unlock()
 	logger.Println("Explicit disable of bark cycle")
+	w.publish(Event{ Kind: EventOff, Sensor: sensor,
+		Message: "Explicit disable of bark cycle" })
+}
+
+// Status takes a snapshot of the Woofer's state.
+func (w *Woofer) Status() Status {
+	w.Lock()
+	defer w.Unlock()
+	now := w.Clock.Now()
+	ret := Status{}
+	ret.Score = int(w.logScore())
+	ret.Level = w.Escalation.Current(now).Name
+	ret.Zones = w.zoneStatus()
+	ret.Armed = w.Armed
+	if w.SnoozeUntil.After(now) { ret.SnoozeUntil = w.SnoozeUntil }
+	ret.Profile = w.Profile
+	ret.Barking = w.WoofUntil.After(now)
+	ret.Quiet = w.WoofSchedule.InSchedules(now)
+	ret.WoofUntil = w.WoofUntil
+	ret.LogSize = len(w.WoofLog)
+	if len(w.WoofLog) != 0 {
+		ret.LastBark = w.WoofLog[len(w.WoofLog)-1]
+	}
+	ret.Parameters = w.parameters()
+	ret.Schedule = w.WoofSchedule.Dump()
+	ret.Trimmed = make(map[string]time.Duration)
+	for _, sound := range w.WoofSamples.Samples {
+		ret.Sounds = append(ret.Sounds, sound.Name())
+		if trimmed := sound.Trimmed(); trimmed > 0 {
+			ret.Trimmed[sound.Name()] = trimmed
+		}
+	}
+	if w.WoofSamples.Cache != nil {
+		ret.Cache = w.WoofSamples.Cache.Stats()
+	}
+	ret.Volume = *w.Volume
+	ret.SpeakerDown = w.speakerDown
+	ret.CurrentVolume = int(math.Round(float64(w.Volume.Master) *
+		w.Volume.At(now) / 100))
+	return ret
+}
+
+// Parameters returns the current bark parameters.
+func (w *Woofer) Parameters() Parameters {
+	w.Lock()
+	defer w.Unlock()
+	return w.parameters()
+}
+
+// parameters is Parameters for callers already holding the lock.
+func (w *Woofer) parameters() Parameters {
+	return Parameters{ int(w.Resolution), w.Horizon, w.Score,
+		int(w.RandomFactor*100.0 + 0.5), w.Scoring }
+}
+
+// SetParameters swaps in a new set of bark parameters.
+func (w *Woofer) SetParameters(p Parameters) error {
+	if p.Resolution <= 0 {
+		return errors.New(fmt.Sprintf("Invalid resolution: %d",
+			p.Resolution))
+	}
+	if p.Horizon <= 0 {
+		return errors.New(fmt.Sprintf("Invalid horizon: %d",
+			p.Horizon))
+	}
+	if p.Factor < 0 || p.Factor > 100 {
+		return errors.New(fmt.Sprintf("Invalid factor: %d", p.Factor))
+	}
+	if p.Scoring == "" { p.Scoring = "linear" }
+	scorer, err := NewScorer(p.Scoring, p.Horizon, p.Score)
+	if err != nil { return err }
+	w.Lock()
+	w.Resolution = time.Duration(p.Resolution)
+	w.Horizon = p.Horizon
+	w.Score = p.Score
+	w.RandomFactor = float32(p.Factor) / 100.0
+	w.Scoring = p.Scoring
+	w.Scorer = scorer
+	if w.statePath != "" {
+		w.overrides.Parameters = &p
+		w.changed()
+	}
+	w.Unlock()
+	msg := fmt.Sprintf("Parameters now resolution=%d horizon=%d " +
+		"score=%d factor=%d scoring=%s", p.Resolution, p.Horizon,
+		p.Score, p.Factor, scorer.String())
+	logger.Println(msg)
+	w.publish(Event{ Kind: EventParameters, Message: msg })
+	return nil
+}
+
+// SetProfile switches to one of the Profiles by name, replacing the scoring,
+// random factor, escalation, reaction delay, pacing and preferred sounds.
+func (w *Woofer) SetProfile(name string) error {
+	w.Lock()
+	p, ok := w.Profiles[name]
+	horizon, score := w.Horizon, w.Score
+	w.Unlock()
+	if !ok {
+		return errors.New(fmt.Sprintf("No such profile: %s", name))
+	}
+	err := p.Validate()
+	if err != nil { return err }
+	scorer, err := NewScorer(p.Scoring, horizon, score)
+	if err != nil { return err }
+	escalation, err := NewEscalation(p.Levels, p.Escalation)
+	if err != nil { return err }
+	w.Lock()
+	w.Scoring = p.Scoring
+	w.Scorer = scorer
+	w.RandomFactor = float32(p.Factor) / 100.0
+	w.Escalation = escalation
+	w.ReactionDelay = time.Duration(p.Delay * float64(time.Second))
+	w.PauseMin = time.Duration(p.PauseMin * float64(time.Second))
+	w.PauseMax = time.Duration(p.PauseMax * float64(time.Second))
+	w.PreferredSounds = p.Sounds
+	w.Profile = name
+	if w.statePath != "" {
+		// The profile replaces the overridden scoring and factor.
+		w.overrides.Profile = name
+		if w.overrides.Parameters != nil {
+			w.overrides.Parameters.Scoring = p.Scoring
+			w.overrides.Parameters.Factor = p.Factor
+		}
+		w.changed()
+	}
+	w.Unlock()
+	logger.Printf("Now a %s dog\n", name)
+	w.publish(Event{ Kind: EventProfile, Message: name })
+	return nil
+}
+
+// SetZones swaps in a new zone setup, budget (BudgetBoth, BudgetZone or
+// BudgetGlobal) and default zone scoring spec.
+func (w *Woofer) SetZones(zones Zones, budget, scoring string) error {
+	switch budget {
+		case BudgetBoth, BudgetZone, BudgetGlobal:
+		default:
+			return errors.New(fmt.Sprintf("Invalid zone budget: %s",
+				budget))
+	}
+	w.Lock()
+	horizon, score := w.Horizon, w.Score
+	w.Unlock()
+	prepared := make(Zones, len(zones))
+	for name, zone := range zones {
+		err := zone.prepare()
+		if err == nil && zone.Scoring != "" {
+			// Check it against the real horizon and score too.
+			_, err = NewScorer(zone.Scoring, horizon, score)
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad zone %s: %s", name,
+				err.Error()))
+		}
+		prepared[name] = zone
+	}
+	if scoring != "" {
+		_, err := NewScorer(scoring, horizon, score)
+		if err != nil { return err }
+	}
+	w.Lock()
+	w.Zones = prepared
+	w.ZoneBudget = budget
+	w.ZoneScoring = scoring
+	w.Unlock()
+	logger.Printf("Zones now %d configured, budget=%s\n", len(zones),
+		budget)
+	return nil
+}
+
+// Arm starts paying attention to triggers again, ending any snooze.
+func (w *Woofer) Arm() {
+	w.Lock()
+	w.Armed = true
+	w.SnoozeUntil = time.Time{}
+	w.changed()
+	w.Unlock()
+	logger.Println("Armed")
+	w.publish(Event{ Kind: EventArm, Message: "Armed" })
+}
+
+// Disarm ignores triggers until Arm and stops any barking.
+func (w *Woofer) Disarm() {
+	w.Lock()
+	w.Armed = false
+	w.WoofUntil = w.Clock.Now()
+	w.interrupt("Disarmed")
+	w.changed()
+	w.wakeup()
+	w.Unlock()
+	logger.Println("Disarmed")
+	w.publish(Event{ Kind: EventArm, Message: "Disarmed" })
+}
+
+// Snooze ignores triggers for a while and stops any barking.
+func (w *Woofer) Snooze(d time.Duration) {
+	w.Lock()
+	now := w.Clock.Now()
+	w.SnoozeUntil = now.Add(d)
+	w.WoofUntil = now
+	w.interrupt("Snoozing")
+	w.changed()
+	w.wakeup()
+	msg := fmt.Sprintf("Snoozing until %s",
+		w.SnoozeUntil.Format(time.RFC3339))
+	w.Unlock()
+	logger.Println(msg)
+	w.publish(Event{ Kind: EventArm, Message: msg })
+}
+
+// SetSchedule swaps in a new quiet schedule.
+func (w *Woofer) SetSchedule(schedule *Schedules) {
+	w.Lock()
+	w.WoofSchedule = schedule
+	if schedule.InSchedules(w.Clock.Now()) { w.interrupt("Quiet time") }
+	if w.statePath != "" {
+		w.overrides.Schedule = schedule
+		w.changed()
+	}
+	w.wakeup()
+	w.Unlock()
+	logger.Println("Schedule replaced")
+	w.publish(Event{ Kind: EventSchedule,
+		Message: schedule.Dump() })
+}
+
+// SetVolume swaps in a new volume setup.  It takes effect from the next
+// bark.
+func (w *Woofer) SetVolume(v Volume) error {
+	err := v.Validate()
+	if err != nil { return err }
+	w.Lock()
+	w.Volume = &v
+	if w.statePath != "" {
+		w.overrides.Volume = &v
+		w.changed()
+	}
+	w.Unlock()
+	msg := fmt.Sprintf("Volume now %s", v.String())
+	logger.Println(msg)
+	w.publish(Event{ Kind: EventVolume, Message: msg })
+	return nil
+}
+
+// PlaySound plays a sample by name (or a random one if name is empty) in
+// the background, bypassing the bark logic entirely.  It returns the name of
+// the sample that will play, which waits its turn behind whatever's playing
+// (a whole bark cycle, when they're sequenced).  Like a bark, it stops early
+// on an explicit off.
+func (w *Woofer) PlaySound(name string) (string, error) {
+	var sound *Sound
+	if name == "" {
+		samples := w.WoofSamples.Samples
+		if len(samples) == 0 {
+			return "", errors.New("No sounds available")
+		}
+		sound = samples[w.Rand.Intn(len(samples))]
+	} else {
+		sound = w.WoofSamples.Find(name)
+		if sound == nil {
+			return "", errors.New(fmt.Sprintf("No such sound: %s",
+				name))
+		}
+	}
+	w.Lock()
+	gain := w.Volume.Gain(w.Clock.Now(), sound.Category())
+	gen := w.interrupts
+	w.Unlock()
+	go func() {
+		w.publish(Event{ Kind: EventPlay,
+			Message: sound.Name() })
+		err := w.play(context.Background(),
+			[]Voice{ { Sound: sound, Gain: gain } }, gen)
+		if err != nil {
+			logger.Println(err)
+			w.publish(Event{ Kind: EventError,
+				Message: err.Error() })
+		}
+	}()
+	return sound.Name(), nil
 }
@@ -0,0 +1,160 @@
+// Test routines for the Woofer business logic, run on a manual clock.
+
+package woofie
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// fixedRand always returns the same whim.
+type fixedRand float32
+
+func (r fixedRand) Float32() float32 { return float32(r) }
+func (r fixedRand) Intn(n int) int { return 0 }
+
+// testWoofer builds a soundless Woofer on a manual clock.
+func testWoofer(clock Clock, rng Rand, factor int) *Woofer {
+	sounds := &Sounds{ rand: rng }
+	return NewWoofer(sounds, &Schedules{}, log.New(ioutil.Discard, "", 0),
+		15, 30, 150, factor, WithClock(clock), WithRand(rng))
+}
+
+// TestWooferFatigue triggers once a minute and checks exactly which minutes
+// get a bark under the default linear scoring.
+func TestWooferFatigue(t *testing.T) {
+	start := time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+
+	// Barks at minutes 0-5 score 0, 29, 57, 84, 110 and 135, so all pass.
+	// Minute 6 scores 159 and minute 7 scores 153, so the dog is tired.
+	// By minute 8 the score has dropped to 147.
+	expected := []bool{ true, true, true, true, true, true, false, false,
+		true }
+	for minute, want := range expected {
+		got, score := woofer.WoofOnFrom(Sensor{})
+		if got != want {
+			t.Errorf("Minute %d: expected %t, got %t (score=%d)",
+				minute, want, got, score)
+		}
+		if got {
+			until := clock.Now().Add(15*time.Second)
+			if !woofer.Status().WoofUntil.Equal(until) {
+				t.Errorf("Minute %d: expected to bark until %s",
+					minute, until)
+			}
+		}
+		clock.Advance(time.Minute)
+	}
+
+	// Two quiet hours later the log is empty again.
+	clock.Advance(2*time.Hour)
+	if status := woofer.Status(); status.LogSize != 0 {
+		t.Error("Expected an empty log, got ", status.LogSize)
+	}
+}
+
+// TestWooferRandomFactor checks that a low enough whim barks through
+// fatigue.
+func TestWooferRandomFactor(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.01), 5)
+	for minute := 0; minute < 60; minute++ {
+		got, score := woofer.WoofOnFrom(Sensor{})
+		if !got {
+			t.Errorf("Minute %d: fickle dog shut up (score=%d)",
+				minute, score)
+		}
+		clock.Advance(time.Minute)
+	}
+}
+
+// TestManualClock checks that sleepers wake up when the clock passes them.
+func TestManualClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	ch := clock.After(time.Minute)
+	clock.Advance(59*time.Second)
+	select {
+		case <-ch:
+			t.Error("Woke up early")
+		default:
+	}
+	clock.Advance(time.Second)
+	select {
+		case <-ch:
+		default:
+			t.Error("Didn't wake up")
+	}
+}
+
+// TestPlayerWakeup checks that the player reacts to a trigger without the
+// clock moving at all, and stops when told to.
+func TestPlayerWakeup(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	events := woofer.Events.Subscribe(16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := woofer.Player(ctx)
+
+	// There are no sounds, so playing fails straight away.
+	woofer.WoofOn()
+	timeout := time.After(5*time.Second)
+	for played := false; !played; {
+		select {
+			case e := <-events:
+				played = e.Kind == EventError
+			case <-timeout:
+				t.Fatal("Player never woke up")
+		}
+	}
+	cancel()
+	select {
+		case <-done:
+		case <-timeout:
+			t.Fatal("Player never stopped")
+	}
+}
+
+// TestInterruptQueued checks a bark waiting its turn behind another is
+// called off by an interruption that comes in the meantime.
+func TestInterruptQueued(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	out := &recordSink{}
+	woofer.WoofSamples.Sink = out
+	events := woofer.Events.Subscribe(16)
+	voices := []Voice{ { Sound: cachedSound(1), Gain: 1 } }
+
+	// Something else is playing, so the bark has to queue.
+	woofer.playLock.Lock()
+	done := make(chan error)
+	go func() { done <- woofer.play(context.Background(), voices, 0) }()
+	woofer.WoofOff()
+	woofer.playLock.Unlock()
+	err := <-done
+	if err != nil { t.Fatal(err) }
+	if len(out.pcm) != 0 {
+		t.Error("Expected nothing played, got ", len(out.pcm), " samples")
+	}
+	interrupted := false
+	for len(events) > 0 {
+		e := <-events
+		interrupted = interrupted || e.Kind == EventInterrupted
+	}
+	if !interrupted { t.Error("Expected an interruption event") }
+
+	// Barks decided on after the interruption still play.
+	woofer.Lock()
+	gen := woofer.interrupts
+	woofer.Unlock()
+	err = woofer.play(context.Background(), voices, gen)
+	if err != nil { t.Fatal(err) }
+	if len(out.pcm) != 1000 {
+		t.Error("Expected the bark played, got ", len(out.pcm), " samples")
+	}
+}
@@ -12,29 +12,71 @@ import (
 	"github.com/gordonklaus/portaudio"
 	"github.com/droundy/goopt"
 	"github.com/wjblack/woofie"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"log/syslog"
 	"os"
+	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // All the various commandline params.  Should be fairly self-documented :-)
 
-var woofDir = goopt.String([]string{"--woofdir"}, ".",
-	"directory with FLAC files inside")
+var woofDir = goopt.String([]string{"--woofdir"}, "",
+	"directory with samples (FLAC, WAV, Ogg, MP3) inside (default .)")
 var schedule = goopt.String([]string{"--schedule"}, "1-5=09:00-17:00",
 	"schedule to disable playback")
 var resolution = goopt.Int([]string{"--resolution"}, 15,
 	"how long to bark before checking again")
-var horizon = goopt.Int([]string{"--horizon"}, 30,
-	"how many minutes to look back in the log")
-var score = goopt.Int([]string{"--score"}, 150,
-	"max points before we shut up for a while")
+var horizon = goopt.Int([]string{"--horizon"},
+	woofie.DefaultHorizon, "how many minutes to look back in the log")
+var score = goopt.Int([]string{"--score"},
+	woofie.DefaultScore, "max points before we shut up for a while")
 var factor = goopt.Int([]string{"--factor"}, 5,
 	"% chance that we might ignore the log")
+var scoring = goopt.String([]string{"--scoring"}, "linear",
+	"fatigue scoring (linear/exponential/bucket/window[:key=val,...])")
+var levels = goopt.String([]string{"--levels"}, woofie.DefaultLevels,
+	"escalation levels as name[:sounds[:volume%[:secs]]],...")
+var escalation = goopt.String([]string{"--escalation"}, "window=60,decay=300",
+	"secs between triggers to escalate, secs of quiet to calm a level")
+var zoneBudget = goopt.Alternatives([]string{"--zonebudget"},
+	[]string{"both", "zone", "global"},
+	"whose fatigue can shut us up: both zone and global, zone or global")
+var zoneScoring = goopt.String([]string{"--zonescoring"}, "",
+	"fatigue scoring per zone (default: same as --scoring)")
+var sensitivity = goopt.String([]string{"--sensitivity"}, "",
+	"zone sensitivity multipliers as zone=mult,... (0 ignores a zone)")
+var voices = goopt.Int([]string{"--voices"}, 1,
+	"most dogs barking at once")
+var voiceOffset = goopt.Int([]string{"--voiceoffset"}, 500,
+	"most ms the other dogs start after the first")
+var voiceGain = goopt.Int([]string{"--voicegain"}, 60,
+	"quietest the other dogs get, as a % of the first")
+var variation = goopt.String([]string{"--variation"}, "",
+	"how much to vary each bark, e.g. pitch=1,tempo=10,gain=3")
+var effects = goopt.String([]string{"--effects"}, "",
+	"effects preset (behind-door, garage, open-yard), e.g. garage:reverb=0.5")
+var volume = goopt.Int([]string{"--volume"}, 100,
+	"master volume in %")
+var volumeCurve = goopt.String([]string{"--volumecurve"}, "",
+	"volume % over the day, e.g. 18=100,0=60,7:30=80")
+var setVolume = goopt.String([]string{"--setvolume"}, "",
+	"volume % per sound set, e.g. growl=50,frenzy=120")
+var sequence = goopt.String([]string{"--sequence"}, woofie.SequenceOff,
+	"string barks together: off, gapless or crossfade")
+var crossfade = goopt.Int([]string{"--crossfade"}, 300,
+	"ms barks overlap by with --sequence=crossfade, less the pause")
+var pauses = goopt.String([]string{"--pauses"}, "",
+	"pause distribution: uniform, normal[:mean=s,sd=s] or exponential[:mean=s]")
+var fade = goopt.Int([]string{"--fade"}, 50,
+	"ms to fade out a bark that gets cut off")
+var profile = goopt.String([]string{"--profile"}, "",
+	"dog personality (default/guard/nervous/lazy/puppy or from --config)")
 var port = goopt.Int([]string{"--port"}, 40080,
 	"port to serve on")
 var path = goopt.String([]string{"--path"}, "/",
@@ -43,13 +85,132 @@ var pass = goopt.String([]string{"--pass"}, "bow wow",
 	"preshared password (UDP only)")
 var logDest = goopt.String([]string{"--log"}, "stderr",
 	"log to stderr/syslog/filename")
-var mode = goopt.Alternatives([]string{"--mode"}, []string{"http", "udp"},
-	"which network trigger to use")
+var mode = goopt.Alternatives([]string{"--mode"},
+	[]string{"http", "udp", "grpc"}, "which network trigger to use")
+var grpcPort = goopt.Int([]string{"--grpcport"}, 0,
+	"also serve gRPC on this port (0 to disable)")
+var tlsCert = goopt.String([]string{"--tlscert"}, "",
+	"TLS certificate file (gRPC only)")
+var tlsKey = goopt.String([]string{"--tlskey"}, "",
+	"TLS key file (gRPC only)")
+var tlsCA = goopt.String([]string{"--tlsca"}, "",
+	"CA file to verify client certificates (gRPC only)")
+var sinkSpec = goopt.String([]string{"--sink"}, "portaudio",
+	"where to play: portaudio, null, wav:PATH, exec:COMMAND, rtp:ADDRS, " +
+	"rtp-opus:ADDRS or http:ADDR")
+var rtpHops = goopt.Int([]string{"--rtphops"}, 1,
+	"how many routers RTP multicast can cross (1 for the local network)")
+var rtpInterface = goopt.String([]string{"--rtpif"}, "",
+	"network interface to send RTP multicast on (\"\" for the default)")
+var device = goopt.String([]string{"--device"}, "",
+	"output device by index or part of its name (see --list-devices)")
+var missingDevice = goopt.Alternatives([]string{"--missingdevice"},
+	[]string{"fail", "default"},
+	"if --device goes missing: fail, or use the default output")
+var listDevices = goopt.Flag([]string{"--list-devices"}, nil,
+	"list the output devices and exit", "")
+var watchdog = goopt.Int([]string{"--watchdog"}, 2000,
+	"ms a portaudio write may overrun its audio before the speaker is down")
+var alert = goopt.String([]string{"--alert"}, "",
+	"command to run when the speaker goes down or comes back")
+var bufferFrames = goopt.Int([]string{"--buffer"}, 1024,
+	"audio buffer size in frames")
+var latency = goopt.Int([]string{"--latency"}, 0,
+	"audio output latency in ms (0 for the device default, or 200 for " +
+	"the network sinks)")
+var outRate = goopt.Int([]string{"--rate"}, 0,
+	"sample rate to convert everything to (0 to play each at its own)")
+var outChannels = goopt.Int([]string{"--channels"}, 0,
+	"channels to convert everything to (0 to play each as it is)")
+var resample = goopt.Alternatives([]string{"--resample"},
+	[]string{"cubic", "nearest", "linear", "sinc"},
+	"resampling quality")
+var normalize = goopt.Int([]string{"--normalize"}, 0,
+	"dBFS RMS to bring every sample to, e.g. -20 (0 to play as recorded)")
+var ceiling = goopt.Int([]string{"--ceiling"}, -1,
+	"dBFS the limiter holds normalized peaks to")
+var loudnessCache = goopt.String([]string{"--loudnesscache"}, "",
+	"file to keep the sample loudness analysis in between runs")
+var trim = goopt.Int([]string{"--trim"}, 0,
+	"dBFS below which to trim silence off the samples, e.g. -50 (0 to not)")
+var cacheSize = goopt.Int([]string{"--cache"}, 0,
+	"MB of decoded samples to keep in memory (0 to always read the disk)")
 var alsaHack = goopt.Flag([]string{"--alsahack"}, nil, "silence ALSA warnings",
 	"")
+var stateFile = goopt.String([]string{"--state"}, "",
+	"file to keep the bark log and runtime changes in across restarts")
+var configFile = goopt.String([]string{"--config"}, "",
+	"JSON file with defaults for any of these options")
+var history = goopt.String([]string{"--history"}, "-",
+	"trigger history CSV or woofie log, - for stdin (simulate only)")
+var simSets = goopt.Strings([]string{"--set"}, "'key=val ...'",
+	"parameter set to compare, repeatable (simulate only)")
+var seed = goopt.Int([]string{"--seed"}, 1,
+	"random seed (simulate only)")
+
+// options maps option names to their values for the config file.
+var options = map[string]interface{}{
+	"woofdir": woofDir,
+	"schedule": schedule,
+	"resolution": resolution,
+	"horizon": horizon,
+	"score": score,
+	"factor": factor,
+	"scoring": scoring,
+	"levels": levels,
+	"escalation": escalation,
+	"zonebudget": zoneBudget,
+	"zonescoring": zoneScoring,
+	"sensitivity": sensitivity,
+	"voices": voices,
+	"voiceoffset": voiceOffset,
+	"voicegain": voiceGain,
+	"variation": variation,
+	"effects": effects,
+	"volume": volume,
+	"volumecurve": volumeCurve,
+	"setvolume": setVolume,
+	"sequence": sequence,
+	"crossfade": crossfade,
+	"pauses": pauses,
+	"fade": fade,
+	"profile": profile,
+	"port": port,
+	"path": path,
+	"pass": pass,
+	"log": logDest,
+	"mode": mode,
+	"grpcport": grpcPort,
+	"tlscert": tlsCert,
+	"tlskey": tlsKey,
+	"tlsca": tlsCA,
+	"sink": sinkSpec,
+	"rtphops": rtpHops,
+	"rtpif": rtpInterface,
+	"device": device,
+	"missingdevice": missingDevice,
+	"watchdog": watchdog,
+	"alert": alert,
+	"buffer": bufferFrames,
+	"latency": latency,
+	"rate": outRate,
+	"channels": outChannels,
+	"resample": resample,
+	"normalize": normalize,
+	"trim": trim,
+	"ceiling": ceiling,
+	"loudnesscache": loudnessCache,
+	"cache": cacheSize,
+	"alsahack": alsaHack,
+	"state": stateFile,
+	"history": history,
+	"seed": seed,
+}
 
 // logger is the place to log everything.
 var logger *log.Logger
+// config is the --config file, if any.
+var config *woofie.Config
 // woofer is the shared Woofer object that does the actual business logic and
 // playing of sounds.
 var woofer *woofie.Woofer
@@ -88,6 +249,36 @@ func initlog() {
 	log.SetOutput(ioutil.Discard)
 }
 
+// loadConfig digs --config out of the command line ahead of the real parse
+// and loads it, so the file's values become the defaults the command line
+// then overrides.
+func loadConfig(args []string) error {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--config=") {
+			*configFile = strings.TrimPrefix(arg, "--config=")
+		} else if arg == "--config" && i+1 < len(args) {
+			*configFile = args[i+1]
+		}
+	}
+	if *configFile == "" { return nil }
+	var err error
+	config, err = woofie.LoadConfig(*configFile)
+	if err != nil { return err }
+	return config.ApplyOptions(options)
+}
+
+// loadZones builds the zone setup from the config file and --sensitivity.
+func loadZones() (woofie.Zones, error) {
+	zones := woofie.Zones{}
+	if config != nil {
+		err := zones.Load(config.Zones)
+		if err != nil { return nil, err }
+	}
+	err := zones.ParseSensitivities(*sensitivity)
+	if err != nil { return nil, err }
+	return zones, nil
+}
+
 // main is the main routine, parsing the command line and firing up the
 // webserver.
 func main() {
@@ -95,17 +286,48 @@ func main() {
 	// Parse the command line.
 	goopt.Description = func() string {
 		return "Server to play audio files in a directory " +
-			"triggered by HTTP."
+			"triggered by HTTP.  Run 'woofie simulate' to " +
+			"replay a trigger history instead."
 	}
 	goopt.Version = "1.0"
 	goopt.Summary = "triggered audio player"
+
+	// "woofie simulate ..." replays history instead of serving.
+	simulating := len(os.Args) > 1 && os.Args[1] == "simulate"
+	if simulating {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	err := loadConfig(os.Args[1:])
+	if err != nil { panic(err.Error()) }
 	goopt.Parse(nil)
+	if *listDevices {
+		portaudio.Initialize()
+		err = woofie.ListDevices(os.Stdout)
+		portaudio.Terminate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if simulating {
+		err = simulate(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Fire up the logger
 	initlog()
 	if !strings.HasSuffix(*path, "/") {
 		*path = fmt.Sprintf("%s/", *path)
 	}
+	// Sound sets only come from a woofdir somebody asked for, not from
+	// whatever happens to be under the current directory.
+	sets := *woofDir != ""
+	if !sets { *woofDir = "." }
 	logger.Printf("Serving woofs from %s on port %d using trigger %s\n",
 		*woofDir, *port, *mode)
 
@@ -114,18 +336,130 @@ func main() {
 		os.Stderr.Close()
 		logger.Println("ALSA warnings on stderr disabled")
 	}
-	portaudio.Initialize()
-	defer portaudio.Terminate()
+	sink, err := woofie.NewSink(*sinkSpec, *bufferFrames,
+		time.Duration(*latency)*time.Millisecond)
+	if err != nil { panic(err.Error()) }
+	if pa, ok := sink.(*woofie.PortaudioSink); ok {
+		portaudio.Initialize()
+		defer portaudio.Terminate()
+		pa.Device = *device
+		pa.Fallback = *missingDevice == "default"
+		err = pa.Check()
+		if err != nil { panic(err.Error()) }
+	}
+	if cs, ok := sink.(*woofie.ConvertSink); ok {
+		if rtp, ok := cs.Out.(*woofie.RTPSink); ok {
+			err = rtp.Multicast(*rtpHops, *rtpInterface)
+			if err != nil { panic(err.Error()) }
+		}
+	}
+	// Files and networks don't get unplugged, and can't be aborted
+	var wd *woofie.Watchdog
+	if _, ok := sink.(*woofie.PortaudioSink); ok && *watchdog > 0 {
+		wd = woofie.NewWatchdog(sink,
+			time.Duration(*watchdog)*time.Millisecond)
+		wd.Alert = *alert
+		sink = wd
+	}
+	if *outRate != 0 || *outChannels != 0 {
+		sink, err = woofie.NewConvertSink(sink, *outRate, *outChannels,
+			*resample)
+		if err != nil { panic(err.Error()) }
+	}
 
 	// Load up the soundfiles
-	sounds, err := woofie.NewSounds(*woofDir)
+	soundOpts := []woofie.Option{ woofie.WithSink(sink) }
+	if !sets { soundOpts = append(soundOpts, woofie.WithoutSets()) }
+	if *cacheSize > 0 {
+		soundOpts = append(soundOpts, woofie.WithCache(
+			woofie.NewCache(int64(*cacheSize) << 20)))
+	}
+	if *normalize != 0 {
+		soundOpts = append(soundOpts, woofie.WithNormalizer(
+			&woofie.Normalizer{ Target: float64(*normalize),
+				Ceiling: float64(*ceiling),
+				CachePath: *loudnessCache }))
+	}
+	if *trim != 0 {
+		soundOpts = append(soundOpts, woofie.WithTrim(float64(*trim)))
+	}
+	sounds, err := woofie.NewSounds(*woofDir, soundOpts...)
+	if err != nil { panic(err.Error()) }
+	if len(sounds.Samples) == 0 { panic("No sounds in woofdir!") }
+	err = sounds.Preload()
 	if err != nil { panic(err.Error()) }
-	if len(*sounds) == 0 { panic("No sounds in woofdir!") }
 	schedules, err := woofie.NewSchedules(*schedule)
 	if err != nil { panic(err.Error()) }
 	woofer = woofie.NewWoofer(sounds, schedules, logger,
 		*resolution, *horizon, *score, *factor)
-	woofer.Player()
+	params := woofer.Parameters()
+	params.Scoring = *scoring
+	err = woofer.SetParameters(params)
+	if err != nil { panic(err.Error()) }
+	woofer.FadeOut = time.Duration(*fade)*time.Millisecond
+	woofer.Watchdog = wd
+	woofer.Variation, err = woofie.NewVariation(*variation)
+	if err != nil { panic(err.Error()) }
+	woofer.Effects, err = woofie.NewEffects(*effects)
+	if err != nil { panic(err.Error()) }
+	woofer.Sequencer, err = woofie.NewSequencer(*sequence,
+		time.Duration(*crossfade)*time.Millisecond, *pauses)
+	if err != nil { panic(err.Error()) }
+	woofer.Volume, err = woofie.NewVolume(*volume, *volumeCurve, *setVolume)
+	if err != nil { panic(err.Error()) }
+	woofer.Escalation, err = woofie.NewEscalation(*levels, *escalation)
+	if err != nil { panic(err.Error()) }
+	if config != nil {
+		err = woofer.Profiles.Load(config.Profiles)
+		if err != nil { panic(err.Error()) }
+	}
+	zones, err := loadZones()
+	if err != nil { panic(err.Error()) }
+	err = woofer.SetZones(zones, *zoneBudget, *zoneScoring)
+	if err != nil { panic(err.Error()) }
+
+	// Everything that needs mixing is mixed in the output format, and
+	// more than one dog, or dogs in different places, need stereo at least.
+	mixChannels := *outChannels
+	if mixChannels == 0 && (*voices > 1 || zones.Placed()) {
+		mixChannels = 2
+	}
+	woofer.Mixer, err = woofie.NewMixer(*outRate, mixChannels, *resample)
+	if err != nil { panic(err.Error()) }
+	for name, zone := range zones {
+		err = woofer.Mixer.Validate(zone.Placement())
+		if err != nil { panic(name + ": " + err.Error()) }
+	}
+	woofer.Voices = *voices
+	woofer.VoiceOffset = time.Duration(*voiceOffset)*time.Millisecond
+	woofer.VoiceGain = float32(*voiceGain) / 100.0
+	if *profile != "" {
+		err = woofer.SetProfile(*profile)
+		if err != nil { panic(err.Error()) }
+	}
+	if *stateFile != "" {
+		err = woofer.Restore(*stateFile)
+		if err != nil { panic(err.Error()) }
+		err = woofer.Persist(*stateFile)
+		if err != nil { panic(err.Error()) }
+	}
+
+	// Stop cleanly on a signal: let the player finish up and save the
+	// state one last time.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+		syscall.SIGTERM)
+	defer stop()
+	done := woofer.Player(ctx)
+	go func() {
+		<-done
+		logger.Println("Shutting down")
+		err := woofer.Close()
+		if err != nil { logger.Println(err) }
+		err = sink.Close()
+		if err != nil { logger.Println(err) }
+		if *sinkSpec == "portaudio" { portaudio.Terminate() }
+		os.Exit(0)
+	}()
 
 	logger.Println("Woofie ready for operation...")
 
@@ -140,10 +474,23 @@ func main() {
 			trig, err := woofie.NewUdpWoofTrigger(*pass, *port)
 			if err != nil { logger.Panic(err) }
 			trigger = woofie.WoofTrigger(trig)
+		case "grpc":
+			trig, err := woofie.NewGrpcWoofTrigger(*port, *tlsCert,
+				*tlsKey, *tlsCA)
+			if err != nil { logger.Panic(err) }
+			trigger = woofie.WoofTrigger(trig)
 		default:
 			logger.Panic(fmt.Sprintf("Invalid mode %s", *mode))
 	}
 
+	// The gRPC API can also ride alongside the main trigger.
+	if *grpcPort != 0 && *mode != "grpc" {
+		trig, err := woofie.NewGrpcWoofTrigger(*grpcPort, *tlsCert,
+			*tlsKey, *tlsCA)
+		if err != nil { logger.Panic(err) }
+		go trig.MainLoop(logger, woofer)
+	}
+
 	// Run the trigger's event loop
 	err = trigger.MainLoop(logger, woofer)
 	if err != nil { panic(err) }
@@ -0,0 +1,66 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements the "woofie simulate" subcommand, which replays a
+// trigger history through the bark logic for one or more parameter sets.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package main
+
+import (
+	"github.com/wjblack/woofie"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// simulate reads the --history, runs it past the command-line parameters
+// plus each --set, and writes the comparison to out.
+func simulate(out io.Writer) error {
+	in := os.Stdin
+	if *history != "-" {
+		f, err := os.Open(*history)
+		if err != nil { return err }
+		defer f.Close()
+		in = f
+	}
+	triggers, err := woofie.ReadTriggers(in)
+	if err != nil { return err }
+
+	// The command line is the baseline; each --set tweaks it.
+	profiles := woofie.DefaultProfiles()
+	if config != nil {
+		err = profiles.Load(config.Profiles)
+		if err != nil { return err }
+	}
+	zones, err := loadZones()
+	if err != nil { return err }
+	base := woofie.SimParams{ Name: "current", Schedule: *schedule,
+		ZoneBudget: *zoneBudget, Zones: zones,
+		ZoneScoring: *zoneScoring, Levels: *levels,
+		Escalation: *escalation, Profile: *profile, Profiles: profiles,
+		Parameters: woofie.Parameters{ Resolution: *resolution,
+			Horizon: *horizon, Score: *score, Factor: *factor,
+			Scoring: *scoring } }
+	sets := []woofie.SimParams{ base }
+	for i, spec := range *simSets {
+		base.Name = fmt.Sprintf("set%d", i+1)
+		params, err := woofie.ParseSimParams(spec, base)
+		if err != nil { return err }
+		sets = append(sets, params)
+	}
+
+	results := make([]*woofie.SimResult, 0)
+	for _, params := range sets {
+		result, err := woofie.Simulate(triggers, params, int64(*seed))
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: %s", params.Name,
+				err.Error()))
+		}
+		results = append(results, result)
+	}
+	woofie.FormatSimulation(out, triggers, results)
+	return nil
+}
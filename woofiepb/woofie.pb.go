@@ -0,0 +1,1679 @@
+// Woofie gRPC control and event API.  The Go bindings in this directory are
+// generated from this file; regenerate them with:
+//    protoc --go_out=. --go_opt=paths=source_relative \
+//        --go-grpc_out=. --go-grpc_opt=paths=source_relative woofie.proto
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: woofie.proto
+
+package woofiepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Sensor identifies whatever noticed the motion.
+type Sensor struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is a unique name for the sensor (e.g. "front-door").
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// zone is the area the sensor watches (e.g. "porch").
+	Zone string `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	// kind is the sensor type (e.g. "pir", "reed").
+	Kind string `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	// labels is any extra free-form metadata.
+	Labels        map[string]string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sensor) Reset() {
+	*x = Sensor{}
+	mi := &file_woofie_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sensor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sensor) ProtoMessage() {}
+
+func (x *Sensor) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sensor.ProtoReflect.Descriptor instead.
+func (*Sensor) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sensor) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Sensor) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *Sensor) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Sensor) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type TriggerRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// on starts the bark cycle if true and stops it if false.
+	On            bool    `protobuf:"varint,1,opt,name=on,proto3" json:"on,omitempty"`
+	Sensor        *Sensor `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerRequest) Reset() {
+	*x = TriggerRequest{}
+	mi := &file_woofie_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerRequest) ProtoMessage() {}
+
+func (x *TriggerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerRequest.ProtoReflect.Descriptor instead.
+func (*TriggerRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TriggerRequest) GetOn() bool {
+	if x != nil {
+		return x.On
+	}
+	return false
+}
+
+func (x *TriggerRequest) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+type TriggerReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// authorized is true if an on request actually started barking.
+	Authorized bool `protobuf:"varint,1,opt,name=authorized,proto3" json:"authorized,omitempty"`
+	// score is the fatigue score at the time of the decision.
+	Score         int32   `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	Status        *Status `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerReply) Reset() {
+	*x = TriggerReply{}
+	mi := &file_woofie_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerReply) ProtoMessage() {}
+
+func (x *TriggerReply) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerReply.ProtoReflect.Descriptor instead.
+func (*TriggerReply) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TriggerReply) GetAuthorized() bool {
+	if x != nil {
+		return x.Authorized
+	}
+	return false
+}
+
+func (x *TriggerReply) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *TriggerReply) GetStatus() *Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_woofie_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{3}
+}
+
+type Status struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// barking is true while the bark cycle is running.
+	Barking bool `protobuf:"varint,1,opt,name=barking,proto3" json:"barking,omitempty"`
+	// quiet is true while the schedule keeps the dog quiet.
+	Quiet     bool                   `protobuf:"varint,2,opt,name=quiet,proto3" json:"quiet,omitempty"`
+	WoofUntil *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=woof_until,json=woofUntil,proto3" json:"woof_until,omitempty"`
+	LastBark  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_bark,json=lastBark,proto3" json:"last_bark,omitempty"`
+	// log_size is the number of barks still in the log.
+	LogSize int32 `protobuf:"varint,5,opt,name=log_size,json=logSize,proto3" json:"log_size,omitempty"`
+	// score is the current fatigue score.
+	Score      int32       `protobuf:"varint,6,opt,name=score,proto3" json:"score,omitempty"`
+	Parameters *Parameters `protobuf:"bytes,7,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	// schedule is the human-readable quiet schedule.
+	Schedule string `protobuf:"bytes,8,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// sounds lists the names of the available samples.
+	Sounds []string `protobuf:"bytes,9,rep,name=sounds,proto3" json:"sounds,omitempty"`
+	// level is the current escalation level (e.g. "growl").
+	Level string `protobuf:"bytes,10,opt,name=level,proto3" json:"level,omitempty"`
+	// profile is the personality in use, if any.
+	Profile string `protobuf:"bytes,11,opt,name=profile,proto3" json:"profile,omitempty"`
+	// zones is the fatigue of each zone that barked lately.
+	Zones []*ZoneStatus `protobuf:"bytes,12,rep,name=zones,proto3" json:"zones,omitempty"`
+	// armed is false while the dog ignores triggers.
+	Armed bool `protobuf:"varint,13,opt,name=armed,proto3" json:"armed,omitempty"`
+	// snooze_until is when the current snooze runs out, if snoozing.
+	SnoozeUntil *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=snooze_until,json=snoozeUntil,proto3" json:"snooze_until,omitempty"`
+	// cache is how the decoded sample cache is doing, if there is one.
+	Cache  *CacheStats `protobuf:"bytes,15,opt,name=cache,proto3" json:"cache,omitempty"`
+	Volume *Volume     `protobuf:"bytes,16,opt,name=volume,proto3" json:"volume,omitempty"`
+	// trimmed is how many secs of silence were trimmed off each sample
+	// that had any.
+	Trimmed map[string]float64 `protobuf:"bytes,17,rep,name=trimmed,proto3" json:"trimmed,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	// speaker_down is true while the speaker isn't working.
+	SpeakerDown   bool `protobuf:"varint,18,opt,name=speaker_down,json=speakerDown,proto3" json:"speaker_down,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_woofie_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Status) GetBarking() bool {
+	if x != nil {
+		return x.Barking
+	}
+	return false
+}
+
+func (x *Status) GetQuiet() bool {
+	if x != nil {
+		return x.Quiet
+	}
+	return false
+}
+
+func (x *Status) GetWoofUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WoofUntil
+	}
+	return nil
+}
+
+func (x *Status) GetLastBark() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastBark
+	}
+	return nil
+}
+
+func (x *Status) GetLogSize() int32 {
+	if x != nil {
+		return x.LogSize
+	}
+	return 0
+}
+
+func (x *Status) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Status) GetParameters() *Parameters {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *Status) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+func (x *Status) GetSounds() []string {
+	if x != nil {
+		return x.Sounds
+	}
+	return nil
+}
+
+func (x *Status) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *Status) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *Status) GetZones() []*ZoneStatus {
+	if x != nil {
+		return x.Zones
+	}
+	return nil
+}
+
+func (x *Status) GetArmed() bool {
+	if x != nil {
+		return x.Armed
+	}
+	return false
+}
+
+func (x *Status) GetSnoozeUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SnoozeUntil
+	}
+	return nil
+}
+
+func (x *Status) GetCache() *CacheStats {
+	if x != nil {
+		return x.Cache
+	}
+	return nil
+}
+
+func (x *Status) GetVolume() *Volume {
+	if x != nil {
+		return x.Volume
+	}
+	return nil
+}
+
+func (x *Status) GetTrimmed() map[string]float64 {
+	if x != nil {
+		return x.Trimmed
+	}
+	return nil
+}
+
+func (x *Status) GetSpeakerDown() bool {
+	if x != nil {
+		return x.SpeakerDown
+	}
+	return false
+}
+
+type CacheStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// entries is the number of samples in the cache.
+	Entries int32 `protobuf:"varint,1,opt,name=entries,proto3" json:"entries,omitempty"`
+	// bytes is the memory they take up, out of limit.
+	Bytes  int64 `protobuf:"varint,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Limit  int64 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Hits   int64 `protobuf:"varint,4,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses int64 `protobuf:"varint,5,opt,name=misses,proto3" json:"misses,omitempty"`
+	// evictions counts samples thrown out to make room.
+	Evictions     int64 `protobuf:"varint,6,opt,name=evictions,proto3" json:"evictions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CacheStats) Reset() {
+	*x = CacheStats{}
+	mi := &file_woofie_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CacheStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheStats) ProtoMessage() {}
+
+func (x *CacheStats) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheStats.ProtoReflect.Descriptor instead.
+func (*CacheStats) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CacheStats) GetEntries() int32 {
+	if x != nil {
+		return x.Entries
+	}
+	return 0
+}
+
+func (x *CacheStats) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *CacheStats) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *CacheStats) GetHits() int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *CacheStats) GetMisses() int64 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *CacheStats) GetEvictions() int64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+type ZoneStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name is the zone (or sensor ID for sensors without a zone).
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// score is the zone's fatigue score, scaled by its sensitivity.
+	Score int32 `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	// log_size is the number of the zone's barks still in its log.
+	LogSize       int32                  `protobuf:"varint,3,opt,name=log_size,json=logSize,proto3" json:"log_size,omitempty"`
+	LastBark      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_bark,json=lastBark,proto3" json:"last_bark,omitempty"`
+	Sensitivity   float64                `protobuf:"fixed64,5,opt,name=sensitivity,proto3" json:"sensitivity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ZoneStatus) Reset() {
+	*x = ZoneStatus{}
+	mi := &file_woofie_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ZoneStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ZoneStatus) ProtoMessage() {}
+
+func (x *ZoneStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ZoneStatus.ProtoReflect.Descriptor instead.
+func (*ZoneStatus) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ZoneStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ZoneStatus) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *ZoneStatus) GetLogSize() int32 {
+	if x != nil {
+		return x.LogSize
+	}
+	return 0
+}
+
+func (x *ZoneStatus) GetLastBark() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastBark
+	}
+	return nil
+}
+
+func (x *ZoneStatus) GetSensitivity() float64 {
+	if x != nil {
+		return x.Sensitivity
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// kinds limits the stream to the given event kinds; empty means all.
+	Kinds         []string `protobuf:"bytes,1,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_woofie_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchRequest) GetKinds() []string {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Time  *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	// kind is e.g. "on", "off", "authorized", "suppressed", "play".
+	Kind    string  `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Message string  `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Sensor  *Sensor `protobuf:"bytes,4,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Score   int32   `protobuf:"varint,5,opt,name=score,proto3" json:"score,omitempty"`
+	// level is the escalation level at the time, if relevant.
+	Level         string `protobuf:"bytes,6,opt,name=level,proto3" json:"level,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_woofie_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Event) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+func (x *Event) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Event) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+type Parameters struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Resolution *int32                 `protobuf:"varint,1,opt,name=resolution,proto3,oneof" json:"resolution,omitempty"`
+	Horizon    *int32                 `protobuf:"varint,2,opt,name=horizon,proto3,oneof" json:"horizon,omitempty"`
+	Score      *int32                 `protobuf:"varint,3,opt,name=score,proto3,oneof" json:"score,omitempty"`
+	Factor     *int32                 `protobuf:"varint,4,opt,name=factor,proto3,oneof" json:"factor,omitempty"`
+	// scoring is the fatigue-scoring spec, as for --scoring.
+	Scoring       *string `protobuf:"bytes,5,opt,name=scoring,proto3,oneof" json:"scoring,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Parameters) Reset() {
+	*x = Parameters{}
+	mi := &file_woofie_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Parameters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Parameters) ProtoMessage() {}
+
+func (x *Parameters) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Parameters.ProtoReflect.Descriptor instead.
+func (*Parameters) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Parameters) GetResolution() int32 {
+	if x != nil && x.Resolution != nil {
+		return *x.Resolution
+	}
+	return 0
+}
+
+func (x *Parameters) GetHorizon() int32 {
+	if x != nil && x.Horizon != nil {
+		return *x.Horizon
+	}
+	return 0
+}
+
+func (x *Parameters) GetScore() int32 {
+	if x != nil && x.Score != nil {
+		return *x.Score
+	}
+	return 0
+}
+
+func (x *Parameters) GetFactor() int32 {
+	if x != nil && x.Factor != nil {
+		return *x.Factor
+	}
+	return 0
+}
+
+func (x *Parameters) GetScoring() string {
+	if x != nil && x.Scoring != nil {
+		return *x.Scoring
+	}
+	return ""
+}
+
+type ScheduleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schedule      string                 `protobuf:"bytes,1,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScheduleRequest) Reset() {
+	*x = ScheduleRequest{}
+	mi := &file_woofie_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduleRequest) ProtoMessage() {}
+
+func (x *ScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduleRequest.ProtoReflect.Descriptor instead.
+func (*ScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ScheduleRequest) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+type ScheduleReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// schedule is the parsed schedule as Schedules.Dump prints it.
+	Schedule      string `protobuf:"bytes,1,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScheduleReply) Reset() {
+	*x = ScheduleReply{}
+	mi := &file_woofie_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduleReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduleReply) ProtoMessage() {}
+
+func (x *ScheduleReply) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduleReply.ProtoReflect.Descriptor instead.
+func (*ScheduleReply) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ScheduleReply) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+type PlaySoundRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name is the sample file name; empty picks one at random.
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaySoundRequest) Reset() {
+	*x = PlaySoundRequest{}
+	mi := &file_woofie_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaySoundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaySoundRequest) ProtoMessage() {}
+
+func (x *PlaySoundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaySoundRequest.ProtoReflect.Descriptor instead.
+func (*PlaySoundRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PlaySoundRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type PlaySoundReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaySoundReply) Reset() {
+	*x = PlaySoundReply{}
+	mi := &file_woofie_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaySoundReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaySoundReply) ProtoMessage() {}
+
+func (x *PlaySoundReply) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaySoundReply.ProtoReflect.Descriptor instead.
+func (*PlaySoundReply) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PlaySoundReply) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type SetProfileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetProfileRequest) Reset() {
+	*x = SetProfileRequest{}
+	mi := &file_woofie_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetProfileRequest) ProtoMessage() {}
+
+func (x *SetProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetProfileRequest.ProtoReflect.Descriptor instead.
+func (*SetProfileRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SetProfileRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListProfilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProfilesRequest) Reset() {
+	*x = ListProfilesRequest{}
+	mi := &file_woofie_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProfilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProfilesRequest) ProtoMessage() {}
+
+func (x *ListProfilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProfilesRequest.ProtoReflect.Descriptor instead.
+func (*ListProfilesRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{15}
+}
+
+type ProfileList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Profiles      []*Profile             `protobuf:"bytes,1,rep,name=profiles,proto3" json:"profiles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProfileList) Reset() {
+	*x = ProfileList{}
+	mi := &file_woofie_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProfileList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProfileList) ProtoMessage() {}
+
+func (x *ProfileList) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProfileList.ProtoReflect.Descriptor instead.
+func (*ProfileList) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ProfileList) GetProfiles() []*Profile {
+	if x != nil {
+		return x.Profiles
+	}
+	return nil
+}
+
+// Profile is a dog personality; see the README for what each field means.
+type Profile struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Scoring string                 `protobuf:"bytes,2,opt,name=scoring,proto3" json:"scoring,omitempty"`
+	Factor  int32                  `protobuf:"varint,3,opt,name=factor,proto3" json:"factor,omitempty"`
+	// delay, pause_min and pause_max are in seconds.
+	Delay         float64  `protobuf:"fixed64,4,opt,name=delay,proto3" json:"delay,omitempty"`
+	PauseMin      float64  `protobuf:"fixed64,5,opt,name=pause_min,json=pauseMin,proto3" json:"pause_min,omitempty"`
+	PauseMax      float64  `protobuf:"fixed64,6,opt,name=pause_max,json=pauseMax,proto3" json:"pause_max,omitempty"`
+	Levels        string   `protobuf:"bytes,7,opt,name=levels,proto3" json:"levels,omitempty"`
+	Escalation    string   `protobuf:"bytes,8,opt,name=escalation,proto3" json:"escalation,omitempty"`
+	Sounds        []string `protobuf:"bytes,9,rep,name=sounds,proto3" json:"sounds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Profile) Reset() {
+	*x = Profile{}
+	mi := &file_woofie_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Profile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Profile) ProtoMessage() {}
+
+func (x *Profile) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Profile.ProtoReflect.Descriptor instead.
+func (*Profile) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Profile) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Profile) GetScoring() string {
+	if x != nil {
+		return x.Scoring
+	}
+	return ""
+}
+
+func (x *Profile) GetFactor() int32 {
+	if x != nil {
+		return x.Factor
+	}
+	return 0
+}
+
+func (x *Profile) GetDelay() float64 {
+	if x != nil {
+		return x.Delay
+	}
+	return 0
+}
+
+func (x *Profile) GetPauseMin() float64 {
+	if x != nil {
+		return x.PauseMin
+	}
+	return 0
+}
+
+func (x *Profile) GetPauseMax() float64 {
+	if x != nil {
+		return x.PauseMax
+	}
+	return 0
+}
+
+func (x *Profile) GetLevels() string {
+	if x != nil {
+		return x.Levels
+	}
+	return ""
+}
+
+func (x *Profile) GetEscalation() string {
+	if x != nil {
+		return x.Escalation
+	}
+	return ""
+}
+
+func (x *Profile) GetSounds() []string {
+	if x != nil {
+		return x.Sounds
+	}
+	return nil
+}
+
+type Volume struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// master is the overall volume in percent.
+	Master int32 `protobuf:"varint,1,opt,name=master,proto3" json:"master,omitempty"`
+	// curve is the volume over the day, as for --volumecurve.
+	Curve string `protobuf:"bytes,2,opt,name=curve,proto3" json:"curve,omitempty"`
+	// sets is the volume of each sound set in percent.
+	Sets map[string]int32 `protobuf:"bytes,3,rep,name=sets,proto3" json:"sets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// current is the master volume times the curve right now.
+	Current       int32 `protobuf:"varint,4,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Volume) Reset() {
+	*x = Volume{}
+	mi := &file_woofie_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Volume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Volume) ProtoMessage() {}
+
+func (x *Volume) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Volume.ProtoReflect.Descriptor instead.
+func (*Volume) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Volume) GetMaster() int32 {
+	if x != nil {
+		return x.Master
+	}
+	return 0
+}
+
+func (x *Volume) GetCurve() string {
+	if x != nil {
+		return x.Curve
+	}
+	return ""
+}
+
+func (x *Volume) GetSets() map[string]int32 {
+	if x != nil {
+		return x.Sets
+	}
+	return nil
+}
+
+func (x *Volume) GetCurrent() int32 {
+	if x != nil {
+		return x.Current
+	}
+	return 0
+}
+
+type VolumeRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Master *int32                 `protobuf:"varint,1,opt,name=master,proto3,oneof" json:"master,omitempty"`
+	// curve replaces the curve (as for --volumecurve, "" for none).
+	Curve *string `protobuf:"bytes,2,opt,name=curve,proto3,oneof" json:"curve,omitempty"`
+	// sets replaces the sound set volumes (as for --setvolume).
+	Sets          *string `protobuf:"bytes,3,opt,name=sets,proto3,oneof" json:"sets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VolumeRequest) Reset() {
+	*x = VolumeRequest{}
+	mi := &file_woofie_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VolumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VolumeRequest) ProtoMessage() {}
+
+func (x *VolumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VolumeRequest.ProtoReflect.Descriptor instead.
+func (*VolumeRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *VolumeRequest) GetMaster() int32 {
+	if x != nil && x.Master != nil {
+		return *x.Master
+	}
+	return 0
+}
+
+func (x *VolumeRequest) GetCurve() string {
+	if x != nil && x.Curve != nil {
+		return *x.Curve
+	}
+	return ""
+}
+
+func (x *VolumeRequest) GetSets() string {
+	if x != nil && x.Sets != nil {
+		return *x.Sets
+	}
+	return ""
+}
+
+type ArmRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// armed arms the dog if true and disarms it if false.
+	Armed bool `protobuf:"varint,1,opt,name=armed,proto3" json:"armed,omitempty"`
+	// snooze_minutes, if set, snoozes the dog for that long instead.
+	SnoozeMinutes int32 `protobuf:"varint,2,opt,name=snooze_minutes,json=snoozeMinutes,proto3" json:"snooze_minutes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArmRequest) Reset() {
+	*x = ArmRequest{}
+	mi := &file_woofie_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArmRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArmRequest) ProtoMessage() {}
+
+func (x *ArmRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_woofie_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArmRequest.ProtoReflect.Descriptor instead.
+func (*ArmRequest) Descriptor() ([]byte, []int) {
+	return file_woofie_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ArmRequest) GetArmed() bool {
+	if x != nil {
+		return x.Armed
+	}
+	return false
+}
+
+func (x *ArmRequest) GetSnoozeMinutes() int32 {
+	if x != nil {
+		return x.SnoozeMinutes
+	}
+	return 0
+}
+
+var File_woofie_proto protoreflect.FileDescriptor
+
+const file_woofie_proto_rawDesc = "" +
+	"\n" +
+	"\fwoofie.proto\x12\x06woofie\x1a\x1fgoogle/protobuf/timestamp.proto\"\xaf\x01\n" +
+	"\x06Sensor\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x122\n" +
+	"\x06labels\x18\x04 \x03(\v2\x1a.woofie.Sensor.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"H\n" +
+	"\x0eTriggerRequest\x12\x0e\n" +
+	"\x02on\x18\x01 \x01(\bR\x02on\x12&\n" +
+	"\x06sensor\x18\x02 \x01(\v2\x0e.woofie.SensorR\x06sensor\"l\n" +
+	"\fTriggerReply\x12\x1e\n" +
+	"\n" +
+	"authorized\x18\x01 \x01(\bR\n" +
+	"authorized\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x05R\x05score\x12&\n" +
+	"\x06status\x18\x03 \x01(\v2\x0e.woofie.StatusR\x06status\"\x0f\n" +
+	"\rStatusRequest\"\xdc\x05\n" +
+	"\x06Status\x12\x18\n" +
+	"\abarking\x18\x01 \x01(\bR\abarking\x12\x14\n" +
+	"\x05quiet\x18\x02 \x01(\bR\x05quiet\x129\n" +
+	"\n" +
+	"woof_until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\twoofUntil\x127\n" +
+	"\tlast_bark\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\blastBark\x12\x19\n" +
+	"\blog_size\x18\x05 \x01(\x05R\alogSize\x12\x14\n" +
+	"\x05score\x18\x06 \x01(\x05R\x05score\x122\n" +
+	"\n" +
+	"parameters\x18\a \x01(\v2\x12.woofie.ParametersR\n" +
+	"parameters\x12\x1a\n" +
+	"\bschedule\x18\b \x01(\tR\bschedule\x12\x16\n" +
+	"\x06sounds\x18\t \x03(\tR\x06sounds\x12\x14\n" +
+	"\x05level\x18\n" +
+	" \x01(\tR\x05level\x12\x18\n" +
+	"\aprofile\x18\v \x01(\tR\aprofile\x12(\n" +
+	"\x05zones\x18\f \x03(\v2\x12.woofie.ZoneStatusR\x05zones\x12\x14\n" +
+	"\x05armed\x18\r \x01(\bR\x05armed\x12=\n" +
+	"\fsnooze_until\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\vsnoozeUntil\x12(\n" +
+	"\x05cache\x18\x0f \x01(\v2\x12.woofie.CacheStatsR\x05cache\x12&\n" +
+	"\x06volume\x18\x10 \x01(\v2\x0e.woofie.VolumeR\x06volume\x125\n" +
+	"\atrimmed\x18\x11 \x03(\v2\x1b.woofie.Status.TrimmedEntryR\atrimmed\x12!\n" +
+	"\fspeaker_down\x18\x12 \x01(\bR\vspeakerDown\x1a:\n" +
+	"\fTrimmedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\x9c\x01\n" +
+	"\n" +
+	"CacheStats\x12\x18\n" +
+	"\aentries\x18\x01 \x01(\x05R\aentries\x12\x14\n" +
+	"\x05bytes\x18\x02 \x01(\x03R\x05bytes\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x03R\x05limit\x12\x12\n" +
+	"\x04hits\x18\x04 \x01(\x03R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x05 \x01(\x03R\x06misses\x12\x1c\n" +
+	"\tevictions\x18\x06 \x01(\x03R\tevictions\"\xac\x01\n" +
+	"\n" +
+	"ZoneStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x05R\x05score\x12\x19\n" +
+	"\blog_size\x18\x03 \x01(\x05R\alogSize\x127\n" +
+	"\tlast_bark\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\blastBark\x12 \n" +
+	"\vsensitivity\x18\x05 \x01(\x01R\vsensitivity\"$\n" +
+	"\fWatchRequest\x12\x14\n" +
+	"\x05kinds\x18\x01 \x03(\tR\x05kinds\"\xb9\x01\n" +
+	"\x05Event\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12&\n" +
+	"\x06sensor\x18\x04 \x01(\v2\x0e.woofie.SensorR\x06sensor\x12\x14\n" +
+	"\x05score\x18\x05 \x01(\x05R\x05score\x12\x14\n" +
+	"\x05level\x18\x06 \x01(\tR\x05level\"\xe3\x01\n" +
+	"\n" +
+	"Parameters\x12#\n" +
+	"\n" +
+	"resolution\x18\x01 \x01(\x05H\x00R\n" +
+	"resolution\x88\x01\x01\x12\x1d\n" +
+	"\ahorizon\x18\x02 \x01(\x05H\x01R\ahorizon\x88\x01\x01\x12\x19\n" +
+	"\x05score\x18\x03 \x01(\x05H\x02R\x05score\x88\x01\x01\x12\x1b\n" +
+	"\x06factor\x18\x04 \x01(\x05H\x03R\x06factor\x88\x01\x01\x12\x1d\n" +
+	"\ascoring\x18\x05 \x01(\tH\x04R\ascoring\x88\x01\x01B\r\n" +
+	"\v_resolutionB\n" +
+	"\n" +
+	"\b_horizonB\b\n" +
+	"\x06_scoreB\t\n" +
+	"\a_factorB\n" +
+	"\n" +
+	"\b_scoring\"-\n" +
+	"\x0fScheduleRequest\x12\x1a\n" +
+	"\bschedule\x18\x01 \x01(\tR\bschedule\"+\n" +
+	"\rScheduleReply\x12\x1a\n" +
+	"\bschedule\x18\x01 \x01(\tR\bschedule\"&\n" +
+	"\x10PlaySoundRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"$\n" +
+	"\x0ePlaySoundReply\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"'\n" +
+	"\x11SetProfileRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x15\n" +
+	"\x13ListProfilesRequest\":\n" +
+	"\vProfileList\x12+\n" +
+	"\bprofiles\x18\x01 \x03(\v2\x0f.woofie.ProfileR\bprofiles\"\xef\x01\n" +
+	"\aProfile\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\ascoring\x18\x02 \x01(\tR\ascoring\x12\x16\n" +
+	"\x06factor\x18\x03 \x01(\x05R\x06factor\x12\x14\n" +
+	"\x05delay\x18\x04 \x01(\x01R\x05delay\x12\x1b\n" +
+	"\tpause_min\x18\x05 \x01(\x01R\bpauseMin\x12\x1b\n" +
+	"\tpause_max\x18\x06 \x01(\x01R\bpauseMax\x12\x16\n" +
+	"\x06levels\x18\a \x01(\tR\x06levels\x12\x1e\n" +
+	"\n" +
+	"escalation\x18\b \x01(\tR\n" +
+	"escalation\x12\x16\n" +
+	"\x06sounds\x18\t \x03(\tR\x06sounds\"\xb7\x01\n" +
+	"\x06Volume\x12\x16\n" +
+	"\x06master\x18\x01 \x01(\x05R\x06master\x12\x14\n" +
+	"\x05curve\x18\x02 \x01(\tR\x05curve\x12,\n" +
+	"\x04sets\x18\x03 \x03(\v2\x18.woofie.Volume.SetsEntryR\x04sets\x12\x18\n" +
+	"\acurrent\x18\x04 \x01(\x05R\acurrent\x1a7\n" +
+	"\tSetsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"~\n" +
+	"\rVolumeRequest\x12\x1b\n" +
+	"\x06master\x18\x01 \x01(\x05H\x00R\x06master\x88\x01\x01\x12\x19\n" +
+	"\x05curve\x18\x02 \x01(\tH\x01R\x05curve\x88\x01\x01\x12\x17\n" +
+	"\x04sets\x18\x03 \x01(\tH\x02R\x04sets\x88\x01\x01B\t\n" +
+	"\a_masterB\b\n" +
+	"\x06_curveB\a\n" +
+	"\x05_sets\"I\n" +
+	"\n" +
+	"ArmRequest\x12\x14\n" +
+	"\x05armed\x18\x01 \x01(\bR\x05armed\x12%\n" +
+	"\x0esnooze_minutes\x18\x02 \x01(\x05R\rsnoozeMinutes2\xc5\x04\n" +
+	"\x06Woofie\x127\n" +
+	"\aTrigger\x12\x16.woofie.TriggerRequest\x1a\x14.woofie.TriggerReply\x122\n" +
+	"\tGetStatus\x12\x15.woofie.StatusRequest\x1a\x0e.woofie.Status\x124\n" +
+	"\vWatchEvents\x12\x14.woofie.WatchRequest\x1a\r.woofie.Event0\x01\x12:\n" +
+	"\x10UpdateParameters\x12\x12.woofie.Parameters\x1a\x12.woofie.Parameters\x12=\n" +
+	"\vSetSchedule\x12\x17.woofie.ScheduleRequest\x1a\x15.woofie.ScheduleReply\x12=\n" +
+	"\tPlaySound\x12\x18.woofie.PlaySoundRequest\x1a\x16.woofie.PlaySoundReply\x128\n" +
+	"\n" +
+	"SetProfile\x12\x19.woofie.SetProfileRequest\x1a\x0f.woofie.Profile\x12@\n" +
+	"\fListProfiles\x12\x1b.woofie.ListProfilesRequest\x1a\x13.woofie.ProfileList\x12.\n" +
+	"\bSetArmed\x12\x12.woofie.ArmRequest\x1a\x0e.woofie.Status\x122\n" +
+	"\tSetVolume\x12\x15.woofie.VolumeRequest\x1a\x0e.woofie.VolumeB$Z\"github.com/wjblack/woofie/woofiepbb\x06proto3"
+
+var (
+	file_woofie_proto_rawDescOnce sync.Once
+	file_woofie_proto_rawDescData []byte
+)
+
+func file_woofie_proto_rawDescGZIP() []byte {
+	file_woofie_proto_rawDescOnce.Do(func() {
+		file_woofie_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_woofie_proto_rawDesc), len(file_woofie_proto_rawDesc)))
+	})
+	return file_woofie_proto_rawDescData
+}
+
+var file_woofie_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_woofie_proto_goTypes = []any{
+	(*Sensor)(nil),                // 0: woofie.Sensor
+	(*TriggerRequest)(nil),        // 1: woofie.TriggerRequest
+	(*TriggerReply)(nil),          // 2: woofie.TriggerReply
+	(*StatusRequest)(nil),         // 3: woofie.StatusRequest
+	(*Status)(nil),                // 4: woofie.Status
+	(*CacheStats)(nil),            // 5: woofie.CacheStats
+	(*ZoneStatus)(nil),            // 6: woofie.ZoneStatus
+	(*WatchRequest)(nil),          // 7: woofie.WatchRequest
+	(*Event)(nil),                 // 8: woofie.Event
+	(*Parameters)(nil),            // 9: woofie.Parameters
+	(*ScheduleRequest)(nil),       // 10: woofie.ScheduleRequest
+	(*ScheduleReply)(nil),         // 11: woofie.ScheduleReply
+	(*PlaySoundRequest)(nil),      // 12: woofie.PlaySoundRequest
+	(*PlaySoundReply)(nil),        // 13: woofie.PlaySoundReply
+	(*SetProfileRequest)(nil),     // 14: woofie.SetProfileRequest
+	(*ListProfilesRequest)(nil),   // 15: woofie.ListProfilesRequest
+	(*ProfileList)(nil),           // 16: woofie.ProfileList
+	(*Profile)(nil),               // 17: woofie.Profile
+	(*Volume)(nil),                // 18: woofie.Volume
+	(*VolumeRequest)(nil),         // 19: woofie.VolumeRequest
+	(*ArmRequest)(nil),            // 20: woofie.ArmRequest
+	nil,                           // 21: woofie.Sensor.LabelsEntry
+	nil,                           // 22: woofie.Status.TrimmedEntry
+	nil,                           // 23: woofie.Volume.SetsEntry
+	(*timestamppb.Timestamp)(nil), // 24: google.protobuf.Timestamp
+}
+var file_woofie_proto_depIdxs = []int32{
+	21, // 0: woofie.Sensor.labels:type_name -> woofie.Sensor.LabelsEntry
+	0,  // 1: woofie.TriggerRequest.sensor:type_name -> woofie.Sensor
+	4,  // 2: woofie.TriggerReply.status:type_name -> woofie.Status
+	24, // 3: woofie.Status.woof_until:type_name -> google.protobuf.Timestamp
+	24, // 4: woofie.Status.last_bark:type_name -> google.protobuf.Timestamp
+	9,  // 5: woofie.Status.parameters:type_name -> woofie.Parameters
+	6,  // 6: woofie.Status.zones:type_name -> woofie.ZoneStatus
+	24, // 7: woofie.Status.snooze_until:type_name -> google.protobuf.Timestamp
+	5,  // 8: woofie.Status.cache:type_name -> woofie.CacheStats
+	18, // 9: woofie.Status.volume:type_name -> woofie.Volume
+	22, // 10: woofie.Status.trimmed:type_name -> woofie.Status.TrimmedEntry
+	24, // 11: woofie.ZoneStatus.last_bark:type_name -> google.protobuf.Timestamp
+	24, // 12: woofie.Event.time:type_name -> google.protobuf.Timestamp
+	0,  // 13: woofie.Event.sensor:type_name -> woofie.Sensor
+	17, // 14: woofie.ProfileList.profiles:type_name -> woofie.Profile
+	23, // 15: woofie.Volume.sets:type_name -> woofie.Volume.SetsEntry
+	1,  // 16: woofie.Woofie.Trigger:input_type -> woofie.TriggerRequest
+	3,  // 17: woofie.Woofie.GetStatus:input_type -> woofie.StatusRequest
+	7,  // 18: woofie.Woofie.WatchEvents:input_type -> woofie.WatchRequest
+	9,  // 19: woofie.Woofie.UpdateParameters:input_type -> woofie.Parameters
+	10, // 20: woofie.Woofie.SetSchedule:input_type -> woofie.ScheduleRequest
+	12, // 21: woofie.Woofie.PlaySound:input_type -> woofie.PlaySoundRequest
+	14, // 22: woofie.Woofie.SetProfile:input_type -> woofie.SetProfileRequest
+	15, // 23: woofie.Woofie.ListProfiles:input_type -> woofie.ListProfilesRequest
+	20, // 24: woofie.Woofie.SetArmed:input_type -> woofie.ArmRequest
+	19, // 25: woofie.Woofie.SetVolume:input_type -> woofie.VolumeRequest
+	2,  // 26: woofie.Woofie.Trigger:output_type -> woofie.TriggerReply
+	4,  // 27: woofie.Woofie.GetStatus:output_type -> woofie.Status
+	8,  // 28: woofie.Woofie.WatchEvents:output_type -> woofie.Event
+	9,  // 29: woofie.Woofie.UpdateParameters:output_type -> woofie.Parameters
+	11, // 30: woofie.Woofie.SetSchedule:output_type -> woofie.ScheduleReply
+	13, // 31: woofie.Woofie.PlaySound:output_type -> woofie.PlaySoundReply
+	17, // 32: woofie.Woofie.SetProfile:output_type -> woofie.Profile
+	16, // 33: woofie.Woofie.ListProfiles:output_type -> woofie.ProfileList
+	4,  // 34: woofie.Woofie.SetArmed:output_type -> woofie.Status
+	18, // 35: woofie.Woofie.SetVolume:output_type -> woofie.Volume
+	26, // [26:36] is the sub-list for method output_type
+	16, // [16:26] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_woofie_proto_init() }
+func file_woofie_proto_init() {
+	if File_woofie_proto != nil {
+		return
+	}
+	file_woofie_proto_msgTypes[9].OneofWrappers = []any{}
+	file_woofie_proto_msgTypes[19].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_woofie_proto_rawDesc), len(file_woofie_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   24,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_woofie_proto_goTypes,
+		DependencyIndexes: file_woofie_proto_depIdxs,
+		MessageInfos:      file_woofie_proto_msgTypes,
+	}.Build()
+	File_woofie_proto = out.File
+	file_woofie_proto_goTypes = nil
+	file_woofie_proto_depIdxs = nil
+}
@@ -0,0 +1,247 @@
+// Network-triggered randomized sound player, simulating how a dog would bark at
+// a door.
+
+// This file implements zones, which let the dog get tired of one flapping
+// sensor without ignoring the others.
+
+// (C)2017 by BJ Black <bj@wjblack.com>, WTFPL licensed--see COPYING
+
+package woofie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The ways zone and global fatigue budgets can combine.
+const (
+	// BudgetGlobal only checks the global budget (one tired dog).
+	BudgetGlobal = "global"
+	// BudgetZone only checks the triggering zone's budget.
+	BudgetZone = "zone"
+	// BudgetBoth needs both the zone's and the global budget, with no
+	// other zone able to use up more than half of the global one.
+	BudgetBoth = "both"
+)
+
+// ZoneName is the zone a sensor's triggers count against: its zone if it
+// has one, otherwise the sensor itself.  Anonymous triggers share "".
+func (s Sensor) ZoneName() string {
+	if s.Zone != "" { return s.Zone }
+	return s.ID
+}
+
+// Zone is the setup for one zone.
+type Zone struct {
+	// Name is what the zone is called.
+	Name string `json:"-"`
+	// Sensitivity scales how seriously the dog takes the zone: the zone's
+	// score is divided by it, so 2 barks twice as long before tiring of
+	// the zone, 0.5 tires twice as fast, and 0 ignores it completely.
+	Sensitivity float64 `json:"sensitivity"`
+	// Scoring is the zone's fatigue-scoring spec ("" for the default).
+	Scoring string `json:"scoring"`
+	// Pan is where the zone's barks sound from in stereo, from -1 (left)
+	// to 1 (right).
+	Pan float64 `json:"pan"`
+	// Channel is the output channel (counting from 1) of the zone's
+	// speaker on a multichannel card, 0 to pan instead.
+	Channel int `json:"channel"`
+	// Effects is the effects spec for the zone's barks ("" for the
+	// global one).
+	Effects string `json:"effects"`
+	// effects is Effects parsed, filled in by prepare.
+	effects *Effects
+}
+
+// Placement is where the zone's barks sound from.
+func (z Zone) Placement() Placement {
+	return Placement{ z.Pan, z.Channel }
+}
+
+// Placed reports whether any zone sounds from somewhere other than the
+// middle, which takes the mixer.
+func (zs Zones) Placed() bool {
+	for _, zone := range zs {
+		if zone.Placement() != (Placement{}) { return true }
+	}
+	return false
+}
+
+// Zones is the set of configured zones by name.  Zones not in it get
+// sensitivity 1 and the default scoring.
+type Zones map[string]Zone
+
+// Get returns the setup for a zone, configured or not.
+func (zs Zones) Get(name string) Zone {
+	zone, ok := zs[name]
+	if !ok { zone = Zone{ Sensitivity: 1.0 } }
+	zone.Name = name
+	return zone
+}
+
+// Load adds zones from the config file, e.g.
+//    "zones": { "garage": { "sensitivity": 0.5, "scoring": "window:max=2",
+//        "pan": -1, "effects": "garage" } }
+func (zs Zones) Load(raw map[string]json.RawMessage) error {
+	for name, setup := range raw {
+		zone := Zone{ Name: name, Sensitivity: 1.0 }
+		decoder := json.NewDecoder(bytes.NewReader(setup))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&zone)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad zone %s: %s", name,
+				err.Error()))
+		}
+		err = zone.Validate()
+		if err != nil {
+			return errors.New(fmt.Sprintf("Bad zone %s: %s", name,
+				err.Error()))
+		}
+		zs[name] = zone
+	}
+	return nil
+}
+
+// ParseSensitivities sets zone sensitivities from a spec like
+// "garage=0.5,porch=2" (the --sensitivity option).
+func (zs Zones) ParseSensitivities(spec string) error {
+	settings, err := parseSettings(spec)
+	if err != nil { return err }
+	for name, sensitivity := range settings {
+		zone := zs.Get(name)
+		zone.Sensitivity = sensitivity
+		err = zone.Validate()
+		if err != nil { return err }
+		zs[name] = zone
+	}
+	return nil
+}
+
+// Validate checks the zone's setup.
+func (z Zone) Validate() error {
+	if z.Sensitivity < 0 {
+		return errors.New(fmt.Sprintf("Invalid sensitivity: %g",
+			z.Sensitivity))
+	}
+	if z.Scoring != "" {
+		_, err := NewScorer(z.Scoring, DefaultHorizon,
+			DefaultScore)
+		if err != nil { return err }
+	}
+	if z.Pan < -1 || z.Pan > 1 {
+		return errors.New(fmt.Sprintf("Invalid pan: %g", z.Pan))
+	}
+	if z.Channel < 0 {
+		return errors.New(fmt.Sprintf("Invalid channel: %d", z.Channel))
+	}
+	_, err := NewEffects(z.Effects)
+	if err != nil { return err }
+	return nil
+}
+
+// prepare checks the zone's setup and parses its effects, so barking
+// doesn't have to.
+func (z *Zone) prepare() error {
+	err := z.Validate()
+	if err != nil { return err }
+	z.effects, err = NewEffects(z.Effects)
+	return err
+}
+
+// ZoneStatus is a snapshot of one zone's fatigue.
+type ZoneStatus struct {
+	// Name is the zone.
+	Name string
+	// Score is the zone's score (already scaled by its sensitivity).
+	Score int
+	// LogSize is the number of the zone's barks still in its log.
+	LogSize int
+	// LastBark is the zone's most recent authorized bark.
+	LastBark time.Time
+	// Sensitivity is the zone's sensitivity.
+	Sensitivity float64
+}
+
+// zoneScore vacuums a zone's log and scores it, returning the scorer used
+// and the score scaled by the zone's sensitivity.  The caller must hold the
+// lock, and the zone must not have zero sensitivity.
+func (w *Woofer) zoneScore(zone Zone) (Scorer, float64) {
+	scorer := w.Scorer
+	spec := zone.Scoring
+	if spec == "" { spec = w.ZoneScoring }
+	if spec != "" {
+		// Specs were checked on the way in, so this can't fail.
+		zoneScorer, err := NewScorer(spec, w.Horizon, w.Score)
+		if err == nil { scorer = zoneScorer }
+	}
+	now := w.Clock.Now()
+	log := w.zoneLogs[zone.Name]
+	for len(log) > 0 && now.Sub(log[0]) > scorer.Retention() {
+		log = log[1:]
+	}
+	if len(log) == 0 {
+		delete(w.zoneLogs, zone.Name)
+	} else {
+		w.zoneLogs[zone.Name] = log
+	}
+	return scorer, scorer.Score(log, now) / zone.Sensitivity
+}
+
+// sharedScore is the global score as the zone sees it under BudgetBoth:
+// each other zone's barks only count up to half the global budget (its most
+// recent ones), so one flapping zone can't use up the barks meant for the
+// rest.  The caller must hold the lock, and have hoovered the log.
+func (w *Woofer) sharedScore(zone Zone) float64 {
+	now := w.Clock.Now()
+	byZone := make(map[string][]int)
+	for i, name := range w.woofZones {
+		byZone[name] = append(byZone[name], i)
+	}
+	skip := make([]bool, len(w.WoofLog))
+	for name, barks := range byZone {
+		if name == zone.Name { continue }
+		log := make([]time.Time, len(barks))
+		for i, bark := range barks {
+			log[i] = w.WoofLog[bark]
+		}
+		over := 0
+		for over < len(log) && w.Scorer.Used(
+				w.Scorer.Score(log[over:], now)) > 0.5 {
+			over++
+		}
+		for _, bark := range barks[:over] {
+			skip[bark] = true
+		}
+	}
+	log := make([]time.Time, 0, len(w.WoofLog))
+	for i, t := range w.WoofLog {
+		if !skip[i] { log = append(log, t) }
+	}
+	return w.Scorer.Score(log, now)
+}
+
+// zoneStatus snapshots every zone with barks in its log.  The caller must
+// hold the lock.
+func (w *Woofer) zoneStatus() []ZoneStatus {
+	names := make([]string, 0)
+	for name := range w.zoneLogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]ZoneStatus, 0)
+	for _, name := range names {
+		zone := w.Zones.Get(name)
+		if zone.Sensitivity == 0 { continue }
+		_, score := w.zoneScore(zone)
+		log := w.zoneLogs[name]
+		if len(log) == 0 { continue }
+		ret = append(ret, ZoneStatus{ name, int(score), len(log),
+			log[len(log)-1], zone.Sensitivity })
+	}
+	return ret
+}
@@ -0,0 +1,189 @@
+// Test routines for per-zone fatigue.
+
+package woofie
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// flapGarage triggers the garage once a minute until it's tired, then tries
+// the front door, returning whether the door got a bark.
+func flapGarage(t *testing.T, budget string, zones Zones) bool {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	err := woofer.SetZones(zones, budget, "")
+	if err != nil { t.Fatal(err) }
+	garage := Sensor{ ID: "garage-pir", Zone: "garage" }
+	for minute := 0; minute < 7; minute++ {
+		woofer.WoofOnFrom(garage)
+		clock.Advance(time.Minute)
+	}
+	got, _ := woofer.WoofOnFrom(garage)
+	if got {
+		t.Errorf("%s: expected the garage to be tired", budget)
+	}
+	got, _ = woofer.WoofOnFrom(Sensor{ ID: "front-door" })
+	return got
+}
+
+// TestZoneBudgets checks that a flapping zone only tires the dog out for
+// everything when the global budget counts by itself.
+func TestZoneBudgets(t *testing.T) {
+	if !flapGarage(t, BudgetZone, Zones{}) {
+		t.Error("Zone budget: front door should still get a bark")
+	}
+	if !flapGarage(t, BudgetBoth, Zones{}) {
+		t.Error("Both budgets: front door should still get a bark")
+	}
+	if flapGarage(t, BudgetGlobal, Zones{}) {
+		t.Error("Global budget: front door should be shut out")
+	}
+}
+
+// TestZoneShare checks that under both budgets a flapping zone only takes
+// half the global budget from a quiet one, which still gets fewer barks than
+// a fresh dog would, and that every bark still shows up in the log.
+func TestZoneShare(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	garage := Sensor{ ID: "garage-pir", Zone: "garage" }
+	for minute := 0; minute < 7; minute++ {
+		woofer.WoofOnFrom(garage)
+		clock.Advance(time.Minute)
+	}
+	barks := 0
+	door := Sensor{ ID: "front-door" }
+	for minute := 0; minute < 7; minute++ {
+		got, score := woofer.WoofOnFrom(door)
+		if got { barks++ }
+		if minute == 0 && (!got || score >= 75) {
+			t.Errorf("Expected a bark at under half the limit, " +
+				"got %v at %d", got, score)
+		}
+		clock.Advance(time.Minute)
+	}
+	// A fresh dog would get 6 (see TestWooferFatigue).
+	if barks != 4 {
+		t.Error("Expected the door to get 4 barks, got ", barks)
+	}
+	if status := woofer.Status(); status.LogSize != 6 + barks {
+		t.Error("Expected every bark in the log, got ", status.LogSize)
+	}
+}
+
+// TestZoneSensitivity checks that sensitivity scales a zone's fatigue and
+// that zero sensitivity ignores it.
+func TestZoneSensitivity(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	zones := Zones{}
+	err := zones.ParseSensitivities("garage=2,porch=0")
+	if err != nil { t.Fatal(err) }
+	err = woofer.SetZones(zones, BudgetZone, "")
+	if err != nil { t.Fatal(err) }
+
+	// Half as tiring, so ten minutes of flapping is still fine.
+	garage := Sensor{ Zone: "garage" }
+	for minute := 0; minute < 10; minute++ {
+		got, score := woofer.WoofOnFrom(garage)
+		if !got {
+			t.Errorf("Minute %d: garage shut up (score=%d)", minute,
+				score)
+		}
+		clock.Advance(time.Minute)
+	}
+	if got, _ := woofer.WoofOnFrom(Sensor{ Zone: "porch" }); got {
+		t.Error("Expected the porch to be ignored")
+	}
+	status := woofer.Status()
+	if len(status.Zones) != 1 || status.Zones[0].Name != "garage" ||
+			status.Zones[0].LogSize != 10 {
+		t.Error("Unexpected zone status: ", status.Zones)
+	}
+}
+
+// TestZonesLoad checks config file zones, defaults and validation.
+func TestZonesLoad(t *testing.T) {
+	zones := Zones{}
+	err := zones.Load(map[string]json.RawMessage{
+		"garage": json.RawMessage(`{"scoring": "window:max=2"}`),
+		"porch": json.RawMessage(`{"sensitivity": 3}`) })
+	if err != nil { t.Fatal(err) }
+	if zones.Get("garage").Sensitivity != 1.0 {
+		t.Error("Expected default sensitivity for garage")
+	}
+	if zones.Get("porch").Sensitivity != 3.0 {
+		t.Error("Expected porch sensitivity 3")
+	}
+	if zone := zones.Get("attic"); zone.Name != "attic" ||
+			zone.Sensitivity != 1.0 {
+		t.Error("Unexpected unconfigured zone: ", zone)
+	}
+	bad := []string{ `{"sensitivity": -1}`, `{"scoring": "psychic"}`,
+		`{"volume": 11}` }
+	for _, setup := range bad {
+		err = zones.Load(map[string]json.RawMessage{
+			"bad": json.RawMessage(setup) })
+		if err == nil { t.Error("Expected error for ", setup) }
+	}
+}
+
+// TestZoneEffects checks a zone's effects get parsed once when the zones are
+// set, with a bad spec refused there, and end up on its barks.
+func TestZoneEffects(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0, time.UTC))
+	woofer := testWoofer(clock, fixedRand(0.99), 5)
+	woofer.WoofSamples.Samples = []*Sound{ cachedSound(1) }
+	err := woofer.SetZones(Zones{ "garage": { Sensitivity: 1,
+		Effects: "attic" } }, BudgetZone, "")
+	if err == nil { t.Error("Expected an error for a bad effects spec") }
+	err = woofer.SetZones(Zones{ "garage": { Sensitivity: 1,
+		Effects: "garage" } }, BudgetZone, "")
+	if err != nil { t.Fatal(err) }
+	voices, err := woofer.voices(nil, 1.0, woofer.Zones.Get("garage"))
+	if err != nil { t.Fatal(err) }
+	if voices[0].Effects == nil || voices[0].Effects.Preset != "garage" {
+		t.Error("Expected the garage's effects, got ", voices[0].Effects)
+	}
+	voices, err = woofer.voices(nil, 1.0, woofer.Zones.Get("porch"))
+	if err != nil { t.Fatal(err) }
+	if voices[0].Effects != woofer.Effects {
+		t.Error("Expected the global effects, got ", voices[0].Effects)
+	}
+}
+
+// TestZoneShareScorers checks the flapping zone's share is capped by how
+// much of the budget each kind of scorer says it's used, even when both
+// zones bark at the same moment.
+func TestZoneShareScorers(t *testing.T) {
+	for _, scoring := range []string{ "linear", "window:max=4",
+			"exponential:halflife=10,limit=100",
+			"bucket:capacity=6,rate=12" } {
+		clock := NewManualClock(time.Date(2017, 1, 20, 22, 0, 0, 0,
+			time.UTC))
+		woofer := testWoofer(clock, fixedRand(0.99), 5)
+		p := woofer.Parameters()
+		p.Scoring = scoring
+		err := woofer.SetParameters(p)
+		if err != nil { t.Fatal(err) }
+		garage := Sensor{ ID: "garage-pir", Zone: "garage" }
+		for i := 0; i < 20; i++ {
+			woofer.WoofOnFrom(garage)
+		}
+		got, _ := woofer.WoofOnFrom(Sensor{ ID: "front-door" })
+		if !got { t.Errorf("%s: front door shut out", scoring) }
+
+		// The door's own bark counts in full, the garage's up to half.
+		woofer.Lock()
+		scorer := woofer.Scorer
+		door := scorer.Used(woofer.sharedScore(woofer.Zones.Get(
+			"front-door")))
+		woofer.Unlock()
+		if door > 0.75 {
+			t.Errorf("%s: door sees %.2f of the budget used", scoring,
+				door)
+		}
+	}
+}